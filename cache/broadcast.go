@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// InvalidationChannel carries the keys deleted by any replica, space separated.
+const InvalidationChannel = "contacts:invalidate"
+
+const (
+	minResubscribeBackoff = 100 * time.Millisecond
+	maxResubscribeBackoff = 30 * time.Second
+	// healthCheckInterval is how long Listen waits for a message before it pings the subscription,
+	// and then for the reply before it takes the connection for dead.
+	healthCheckInterval = 30 * time.Second
+)
+
+// Broadcast keeps in-process caches coherent across replicas: every Delete is applied
+// locally and published on InvalidationChannel, and Listen applies the deletes published by the others.
+// While Listen isn't subscribed it would miss them, so the cache is bypassed and starts empty once
+// the subscription is back.
+type Broadcast struct {
+	local      *LRU
+	client     *redis.Client
+	subscribed atomic.Bool
+}
+
+func NewBroadcast(local *LRU, client *redis.Client) *Broadcast {
+	return &Broadcast{local: local, client: client}
+}
+
+func (b *Broadcast) Get(ctx context.Context, key string) (string, error) {
+	if !b.subscribed.Load() {
+		return "", ErrMiss
+	}
+
+	return b.local.Get(ctx, key)
+}
+
+func (b *Broadcast) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	if !b.subscribed.Load() {
+		return nil
+	}
+
+	return b.local.Set(ctx, key, value, ttl)
+}
+
+func (b *Broadcast) Delete(ctx context.Context, keys ...string) error {
+	if err := b.local.Delete(ctx, keys...); err != nil {
+		return err
+	}
+
+	if len(keys) == 0 {
+		return nil
+	}
+
+	return b.client.Publish(ctx, InvalidationChannel, strings.Join(keys, " ")).Err()
+}
+
+// Listen applies invalidations published by other replicas until ctx is done, subscribing again
+// with a growing backoff whenever the subscription fails.
+// Our own deletes come back too, deleting them twice is harmless.
+func (b *Broadcast) Listen(ctx context.Context) {
+	backoff := minResubscribeBackoff
+	for {
+		subscribed, err := b.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if subscribed {
+			backoff = minResubscribeBackoff
+		}
+
+		log.Printf("Broadcast.Listen: not subscribed to invalidations, bypassing the cache and retrying in %s: %v", backoff, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxResubscribeBackoff)
+	}
+}
+
+// listen runs one subscription until it fails, reporting whether it got as far as subscribing.
+func (b *Broadcast) listen(ctx context.Context) (bool, error) {
+	sub := b.client.Subscribe(ctx, InvalidationChannel)
+	defer sub.Close()
+
+	if _, err := sub.Receive(ctx); err != nil {
+		return false, err
+	}
+
+	// Deletes published before now may have been missed.
+	b.local.Purge()
+	b.subscribed.Store(true)
+	defer b.subscribed.Store(false)
+
+	pinged := false
+	for {
+		msg, err := sub.ReceiveTimeout(ctx, healthCheckInterval)
+		var netErr net.Error
+		switch {
+		case ctx.Err() != nil:
+			return true, nil
+		case errors.As(err, &netErr) && netErr.Timeout() && !pinged:
+			if err := sub.Ping(ctx); err != nil {
+				return true, err
+			}
+			pinged = true
+			continue
+		case err != nil:
+			return true, err
+		}
+
+		pinged = false
+		if msg, ok := msg.(*redis.Message); ok {
+			if err := b.local.Delete(ctx, strings.Fields(msg.Payload)...); err != nil {
+				log.Printf("Broadcast.Listen: failed to apply invalidation %q: %v", msg.Payload, err)
+			}
+		}
+	}
+}
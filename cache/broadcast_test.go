@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBroadcast_DeletesOnEveryReplica(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	addr := miniredis.RunT(t).Addr()
+
+	replicas := make([]*Broadcast, 2)
+	for i := range replicas {
+		client := redis.NewClient(&redis.Options{Addr: addr})
+		defer client.Close()
+		replicas[i] = NewBroadcast(NewLRU(10), client)
+		go replicas[i].Listen(ctx)
+	}
+	require.Eventually(t, func() bool {
+		subscribers, _ := replicas[0].client.PubSubNumSub(ctx, InvalidationChannel).Result()
+		return replicas[0].subscribed.Load() && replicas[1].subscribed.Load() && subscribers[InvalidationChannel] == 2
+	}, time.Second, 10*time.Millisecond)
+
+	for _, replica := range replicas {
+		require.NoError(t, replica.Set(ctx, "contact:v1:1", "cached", 0))
+	}
+
+	require.NoError(t, replicas[0].Delete(ctx, "contact:v1:1"))
+
+	assert.Eventually(t, func() bool {
+		_, err := replicas[1].Get(ctx, "contact:v1:1")
+		return err == ErrMiss
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestBroadcast_ResubscribesAfterRedisRestarts(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server := miniredis.RunT(t)
+
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	replica := NewBroadcast(NewLRU(10), client)
+	go replica.Listen(ctx)
+	subscribed := func() bool {
+		subscribers, _ := client.PubSubNumSub(ctx, InvalidationChannel).Result()
+		return replica.subscribed.Load() && subscribers[InvalidationChannel] == 1
+	}
+	require.Eventually(t, subscribed, time.Second, 10*time.Millisecond)
+	require.NoError(t, replica.Set(ctx, "contact:v1:1", "cached", 0))
+
+	server.Close()
+	require.Eventually(t, func() bool { return !replica.subscribed.Load() }, time.Second, 10*time.Millisecond)
+	_, err := replica.Get(ctx, "contact:v1:1")
+	assert.Equal(t, ErrMiss, err, "the cache is bypassed while invalidations can be missed")
+	require.NoError(t, replica.Set(ctx, "contact:v1:2", "cached", 0))
+	_, err = replica.Get(ctx, "contact:v1:2")
+	assert.Equal(t, ErrMiss, err)
+
+	require.NoError(t, server.Restart())
+	require.Eventually(t, subscribed, 5*time.Second, 10*time.Millisecond)
+	_, err = replica.Get(ctx, "contact:v1:1")
+	assert.Equal(t, ErrMiss, err, "entries cached before the subscription was lost are purged")
+
+	require.NoError(t, replica.Set(ctx, "contact:v1:3", "cached", 0))
+	require.NoError(t, client.Publish(ctx, InvalidationChannel, "contact:v1:3").Err())
+	assert.Eventually(t, func() bool {
+		_, err := replica.Get(ctx, "contact:v1:3")
+		return err == ErrMiss
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRU_EvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU(2)
+
+	require.NoError(t, c.Set(ctx, "a", "1", 0))
+	require.NoError(t, c.Set(ctx, "b", "2", 0))
+	_, err := c.Get(ctx, "a")
+	require.NoError(t, err)
+	require.NoError(t, c.Set(ctx, "c", "3", 0))
+
+	_, err = c.Get(ctx, "b")
+	assert.Equal(t, ErrMiss, err)
+	value, err := c.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, "1", value)
+}
+
+func TestLRU_ExpiresEntries(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	c := NewLRU(2)
+	c.now = func() time.Time { return now }
+
+	require.NoError(t, c.Set(ctx, "a", "1", time.Minute))
+	_, err := c.Get(ctx, "a")
+	require.NoError(t, err)
+
+	now = now.Add(time.Minute)
+	_, err = c.Get(ctx, "a")
+	assert.Equal(t, ErrMiss, err)
+}
@@ -2,52 +2,156 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"database/sql"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"time"
 
 	"github.com/go-redis/redis/v8"
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
 
+	"github.com/ShaynaSegal45/phonebook-api/cache"
+	"github.com/ShaynaSegal45/phonebook-api/config"
 	"github.com/ShaynaSegal45/phonebook-api/contactsmanaging"
+	"github.com/ShaynaSegal45/phonebook-api/cursor"
+	"github.com/ShaynaSegal45/phonebook-api/phone"
 	sqldb "github.com/ShaynaSegal45/phonebook-api/sql"
 )
 
 func main() {
-	db := initializeDatabase()
-	rdb := initRedisClient()
+	sqlConfigPath := flag.String("sql-config", "config/sql.json", "path to the SQL adapter configuration")
+	cacheConfigPath := flag.String("cache-config", "config/cache.json", "path to the cache configuration")
+	autoMigrate := flag.Bool("auto-migrate", true, "apply pending schema migrations on startup")
+	suggestRefresh := flag.Duration("suggest-refresh", time.Minute, "how often to reload the suggestions written by other replicas, 0 never")
+	cursorSecret := flag.String("cursor-secret", os.Getenv("PHONEBOOK_CURSOR_SECRET"), "secret signing the pagination cursors, shared by every replica; required")
+	randomCursorSecret := flag.Bool("random-cursor-secret", false, "for development: sign the cursors with a random key when -cursor-secret is empty")
+	trustedProxies := flag.String("trusted-proxies", os.Getenv("PHONEBOOK_TRUSTED_PROXIES"), "comma-separated addresses or CIDR ranges of the proxies whose X-Forwarded-User names the actor of a write")
+	phoneRegion := flag.String("phone-region", "IL", "ISO 3166-1 alpha-2 region of phone numbers written without a country code")
+	flag.Parse()
+
+	if !phone.ValidRegion(*phoneRegion) {
+		log.Fatalf("unsupported phone region %q\n", *phoneRegion)
+	}
+	proxies, err := contactsmanaging.ParseTrustedProxies(*trustedProxies)
+	if err != nil {
+		log.Fatalf("could not parse -trusted-proxies: %v\n", err)
+	}
+	db, dialect := initializeDatabase(*sqlConfigPath)
 	defer db.Close()
-	defer rdb.Close()
 
-	repo := sqldb.NewContactsRepo(db, rdb)
-	service := contactsmanaging.NewService(repo)
-	router := contactsmanaging.NewHTTPHandler(service)
+	if flag.Arg(0) == "migrate" {
+		if err := runMigrate(db, dialect.Name(), flag.Args()[1:]); err != nil {
+			log.Fatalf("migrate: %v\n", err)
+		}
+		return
+	}
+
+	// Only the server signs cursors, migrate runs without the secret.
+	if *cursorSecret == "" && !*randomCursorSecret {
+		log.Fatalln("no -cursor-secret or PHONEBOOK_CURSOR_SECRET set, pass -random-cursor-secret to run without one in development")
+	}
+
+	if *autoMigrate {
+		if err := runMigrate(db, dialect.Name(), []string{"up"}); err != nil {
+			log.Fatalf("could not migrate database: %v\n", err)
+		}
+	}
+
+	contactsCache, cacheTTL, closeCache := initializeCache(*cacheConfigPath)
+	defer closeCache()
+
+	repo := sqldb.NewContactsRepo(db, dialect, contactsCache, cacheTTL)
+	// Before the upgrades below write: it drops the FTS5 triggers a binary without FTS5 can't run.
+	if enabled, err := repo.EnableFullTextIndex(context.Background()); err != nil {
+		log.Printf("could not build the full-text index: %v\n", err)
+	} else if enabled {
+		log.Println("full-text search uses the FTS5 index")
+	}
+	if upgraded, err := repo.UpgradeLegacyAddresses(context.Background()); err != nil {
+		log.Printf("could not upgrade legacy addresses: %v\n", err)
+	} else if upgraded > 0 {
+		log.Printf("upgraded %d legacy addresses\n", upgraded)
+	}
+	if upgraded, err := repo.UpgradeLegacyPhones(context.Background(), *phoneRegion); err != nil {
+		log.Printf("could not upgrade legacy phones: %v\n", err)
+	} else if upgraded > 0 {
+		log.Printf("upgraded %d legacy phones\n", upgraded)
+	}
+	// Cursor pages only see the contacts with sort keys.
+	if upgraded, err := repo.UpgradeLegacySearchKeys(context.Background()); err != nil {
+		log.Fatalf("could not upgrade legacy search keys: %v\n", err)
+	} else if upgraded > 0 {
+		log.Printf("computed the search keys of %d legacy contacts\n", upgraded)
+	}
+	service := contactsmanaging.NewService(repo, repo, *phoneRegion)
+	if err := service.RefreshSuggestions(context.Background()); err != nil {
+		log.Printf("could not load suggestions: %v\n", err)
+	}
+	if *suggestRefresh > 0 {
+		go refreshSuggestions(service, *suggestRefresh)
+	}
+	router := contactsmanaging.NewHTTPHandler(service, cursor.NewCodec(cursorSigningKey(*cursorSecret)), proxies)
 
 	startServer(router)
 }
 
-func initializeDatabase() *sql.DB {
-	db, err := sql.Open("sqlite3", "./contacts.db")
+func initializeDatabase(configPath string) (*sql.DB, sqldb.Dialect) {
+	cfg, err := config.LoadSQL(configPath)
 	if err != nil {
-		log.Fatalf("could not connect to database: %v\n", err)
+		log.Fatalf("could not load sql config: %v\n", err)
 	}
 
-	createTableQuery := `
-	CREATE TABLE IF NOT EXISTS contacts (
-		id TEXT PRIMARY KEY,
-		firstname TEXT,
-		lastname TEXT,
-		address TEXT,
-		phone TEXT
-	);`
+	dialect, err := sqldb.DialectFor(cfg.Adapter)
+	if err != nil {
+		log.Fatalf("could not select sql dialect: %v\n", err)
+	}
 
-	_, err = db.Exec(createTableQuery)
+	dsn, err := cfg.DSN()
 	if err != nil {
-		log.Fatalf("could not create contacts table: %v\n", err)
+		log.Fatalf("could not build dsn: %v\n", err)
+	}
+
+	db, err := sql.Open(cfg.Adapter, dsn)
+	if err != nil {
+		log.Fatalf("could not connect to database: %v\n", err)
+	}
+	db.SetMaxOpenConns(cfg.Pool)
+	db.SetMaxIdleConns(cfg.Pool)
+
+	if err := db.Ping(); err != nil {
+		log.Fatalf("could not reach %s database: %v\n", cfg.Adapter, err)
 	}
 
-	return db
+	return db, dialect
+}
+
+// cursorSigningKey falls back to a random key, which only this process knows: its cursors stop
+// working on restart and on the other replicas. main only allows it with -random-cursor-secret.
+func cursorSigningKey(secret string) []byte {
+	if secret != "" {
+		return []byte(secret)
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		log.Fatalf("could not generate a cursor secret: %v\n", err)
+	}
+	log.Println("-random-cursor-secret set, pagination cursors only work on this replica until it restarts")
+	return key
+}
+
+func refreshSuggestions(service contactsmanaging.Service, every time.Duration) {
+	for range time.Tick(every) {
+		if err := service.RefreshSuggestions(context.Background()); err != nil {
+			log.Printf("could not refresh suggestions: %v\n", err)
+		}
+	}
 }
 
 func startServer(router http.Handler) {
@@ -57,11 +161,67 @@ func startServer(router http.Handler) {
 	}
 }
 
-func initRedisClient() *redis.Client {
+// initializeCache builds the cache selected in the config. The returned func releases its connections.
+func initializeCache(configPath string) (cache.Cache, sqldb.CacheTTL, func()) {
+	cfg, err := config.LoadCache(configPath)
+	if err != nil {
+		log.Fatalf("could not load cache config: %v\n", err)
+	}
+
+	c, closeCache := newCache(cfg)
+	return c, cacheTTL(cfg), closeCache
+}
+
+func newCache(cfg config.Cache) (cache.Cache, func()) {
+	switch cfg.Adapter {
+	case config.CacheRedis:
+		rdb := initRedisClient(cfg)
+		return cache.NewRedis(rdb), func() { rdb.Close() }
+
+	case config.CacheLRU:
+		lru := cache.NewLRU(cfg.Size)
+		if cfg.RedisAddr() == "" {
+			return lru, func() {}
+		}
+
+		rdb := initRedisClient(cfg)
+		broadcast := cache.NewBroadcast(lru, rdb)
+		ctx, cancel := context.WithCancel(context.Background())
+		go broadcast.Listen(ctx)
+		return broadcast, func() {
+			cancel()
+			rdb.Close()
+		}
+
+	case config.CacheNone:
+		return cache.NewNoop(), func() {}
+
+	default:
+		log.Fatalf("unsupported cache adapter %q\n", cfg.Adapter)
+		return nil, nil
+	}
+}
+
+func cacheTTL(cfg config.Cache) sqldb.CacheTTL {
+	ttl := sqldb.DefaultCacheTTL
+	if cfg.TTL > 0 {
+		ttl.Contact = time.Duration(cfg.TTL) * time.Second
+	}
+	if cfg.NegativeTTL > 0 {
+		ttl.NotFound = time.Duration(cfg.NegativeTTL) * time.Second
+	}
+	if cfg.SearchTTL > 0 {
+		ttl.Search = time.Duration(cfg.SearchTTL) * time.Second
+	}
+
+	return ttl
+}
+
+func initRedisClient(cfg config.Cache) *redis.Client {
 	rdb := redis.NewClient(&redis.Options{
-		Addr:     "localhost:6379",
-		Password: "",
-		DB:       0,
+		Addr:     cfg.RedisAddr(),
+		Password: cfg.Password,
+		DB:       cfg.DB,
 	})
 
 	pong, err := rdb.Ping(context.Background()).Result()
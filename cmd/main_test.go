@@ -0,0 +1,32 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateStatus_WithoutCursorSecret(t *testing.T) {
+	// main exits through log.Fatal, so it runs in a child process: this test binary, run again.
+	if configPath := os.Getenv("PHONEBOOK_TEST_SQL_CONFIG"); configPath != "" {
+		os.Args = []string{"main", "-sql-config", configPath, "migrate", "status"}
+		main()
+		return
+	}
+
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "sql.json")
+	config := `{"adapter": "sqlite3", "pool": 1, "database": "` + filepath.Join(dir, "contacts.db") + `"}`
+	require.NoError(t, os.WriteFile(configPath, []byte(config), 0o600))
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestMigrateStatus_WithoutCursorSecret$")
+	cmd.Env = append(os.Environ(), "PHONEBOOK_TEST_SQL_CONFIG="+configPath, "PHONEBOOK_CURSOR_SECRET=")
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+	assert.Contains(t, string(out), "pending")
+	assert.NotContains(t, string(out), "cursor-secret")
+}
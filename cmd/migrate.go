@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/ShaynaSegal45/phonebook-api/migrations"
+)
+
+const migrateUsage = "usage: migrate up | down [steps] | status"
+
+func runMigrate(db *sql.DB, dialect string, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf(migrateUsage)
+	}
+
+	ctx := context.Background()
+	migrator := migrations.New(db, dialect)
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			log.Printf("migrated up to version %d: %s", m.Version, m.Description)
+		}
+		return err
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n <= 0 {
+				return fmt.Errorf("invalid steps %q: %s", args[1], migrateUsage)
+			}
+			steps = n
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		for _, m := range reverted {
+			log.Printf("migrated down from version %d: %s", m.Version, m.Description)
+		}
+		return err
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt
+			}
+			fmt.Printf("%4d  %-40s %s\n", s.Version, s.Description, state)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown command %q: %s", args[0], migrateUsage)
+	}
+}
@@ -0,0 +1,80 @@
+// Package collation normalizes names for searching and sorting. Fold makes "José", "JOSE" and
+// "jose" the same search key; SortKey orders names the way a locale's alphabet does, instead of
+// by their bytes.
+package collation
+
+import (
+	"encoding/hex"
+	"fmt"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Root is the locale of the default order, the Unicode collation algorithm without tailoring.
+const Root = "und"
+
+// Locales are the locales a sort key is kept for; Root comes first.
+var Locales = []string{Root, "de", "en", "es", "fr", "he", "it", "sv"}
+
+var matcher = language.NewMatcher(tags())
+
+func tags() []language.Tag {
+	tags := make([]language.Tag, len(Locales))
+	for i, locale := range Locales {
+		tags[i] = language.Make(locale)
+	}
+	return tags
+}
+
+// Fold returns the search key of a text: NFC-normalized, case-folded and without diacritics,
+// including Hebrew vowel points.
+func Fold(s string) string {
+	folded, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), cases.Fold().String(s))
+	if err != nil {
+		return s
+	}
+	return folded
+}
+
+// Match returns the supported locale closest to a BCP 47 tag such as "he-IL", or Root when none is
+// close. It fails when the tag doesn't parse.
+func Match(locale string) (string, error) {
+	if locale == "" {
+		return Root, nil
+	}
+
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return "", fmt.Errorf("collation.Match: invalid locale %q", locale)
+	}
+
+	_, i, confidence := matcher.Match(tag)
+	if confidence < language.High {
+		return Root, nil
+	}
+	return Locales[i], nil
+}
+
+// SortKeyLength bounds the length of a sort key, so the keys of long names still fit a column and
+// its index. Names whose keys only differ past it tie, the caller orders them by name and id.
+const SortKeyLength = 512
+
+// SortKey orders contacts by last name, then first name, in a supported locale. Keys compare byte by
+// byte, they are hex-encoded so any column type and collation keeps their order.
+func SortKey(locale, lastName, firstName string) string {
+	c := collate.New(language.Make(locale))
+	var buf collate.Buffer
+	key := append([]byte{}, c.KeyFromString(&buf, lastName)...)
+	key = append(key, 0)
+	key = append(key, c.KeyFromString(&buf, firstName)...)
+	if len(key) > SortKeyLength/2 {
+		key = key[:SortKeyLength/2]
+	}
+	return hex.EncodeToString(key)
+}
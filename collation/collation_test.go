@@ -0,0 +1,70 @@
+package collation
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFold(t *testing.T) {
+	tests := map[string]string{
+		"José":         "jose",
+		"JOSE":         "jose",
+		"José":        "jose",
+		"Straße":       "strasse",
+		"Zoë Ångström": "zoe angstrom",
+		"שָׁלוֹם":      "שלום",
+		"050-1234567":  "050-1234567",
+	}
+
+	for input, expected := range tests {
+		t.Run(input, func(t *testing.T) {
+			assert.Equal(t, expected, Fold(input))
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := map[string]string{
+		"":      Root,
+		"he-IL": "he",
+		"sv":    "sv",
+		"en-GB": "en",
+		"ja":    Root,
+	}
+	for locale, expected := range tests {
+		t.Run(locale, func(t *testing.T) {
+			matched, err := Match(locale)
+			require.NoError(t, err)
+			assert.Equal(t, expected, matched)
+		})
+	}
+
+	_, err := Match("not a locale!")
+	assert.Error(t, err)
+}
+
+func TestSortKey(t *testing.T) {
+	sorted := func(locale string, lastNames ...string) []string {
+		sort.Slice(lastNames, func(i, j int) bool {
+			return SortKey(locale, lastNames[i], "") < SortKey(locale, lastNames[j], "")
+		})
+		return lastNames
+	}
+
+	assert.Equal(t, []string{"abel", "Åberg", "Zed"}, sorted(Root, "Zed", "Åberg", "abel"))
+	assert.Equal(t, []string{"abel", "Zed", "Åberg"}, sorted("sv", "Zed", "Åberg", "abel"))
+	assert.Equal(t, []string{"Lev", "Levi", "levin"}, sorted(Root, "levin", "Levi", "Lev"))
+	assert.Equal(t, []string{"אברהם", "כהן", "לוי"}, sorted("he", "לוי", "אברהם", "כהן"))
+
+	assert.Less(t, SortKey(Root, "Segal", "Dan"), SortKey(Root, "Segal", "shayna"))
+	assert.Less(t, SortKey(Root, "Lev", "Zed"), SortKey(Root, "Levi", "Adam"))
+
+	long := strings.Repeat("אבג", 85)
+	assert.Len(t, SortKey("he", long, long), SortKeyLength)
+	assert.Less(t, SortKey(Root, "b"+long, long), SortKey(Root, "c"+long, long))
+	assert.Equal(t, SortKey(Root, long+"b", long), SortKey(Root, long+"c", long))
+}
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	AdapterSQLite   = "sqlite3"
+	AdapterMySQL    = "mysql"
+	AdapterPostgres = "postgres"
+
+	CacheRedis = "redis"
+	CacheLRU   = "lru"
+	CacheNone  = "none"
+
+	defaultPool    = 5
+	defaultLRUSize = 10000
+)
+
+type SQL struct {
+	Adapter  string `json:"adapter"`
+	Encoding string `json:"encoding"`
+	Pool     int    `json:"pool"`
+	Port     int    `json:"port"`
+	Host     string `json:"host"`
+	Database string `json:"database"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	SSLMode  string `json:"sslmode"`
+}
+
+func LoadSQL(path string) (SQL, error) {
+	var cfg SQL
+	if err := load(path, &cfg); err != nil {
+		return SQL{}, err
+	}
+
+	if cfg.Pool <= 0 {
+		cfg.Pool = defaultPool
+	}
+
+	return cfg, nil
+}
+
+// Cache selects the cache implementation. Host and Port point at Redis: for the redis adapter it is the cache itself,
+// for the lru adapter it is optional and used to broadcast invalidations between replicas.
+// The TTLs are in seconds, zero keeps the repository default.
+type Cache struct {
+	Adapter     string `json:"adapter"`
+	Host        string `json:"host"`
+	Port        int    `json:"port"`
+	Password    string `json:"password"`
+	DB          int    `json:"db"`
+	Size        int    `json:"size"`
+	TTL         int    `json:"ttl"`
+	NegativeTTL int    `json:"negativeTtl"`
+	SearchTTL   int    `json:"searchTtl"`
+}
+
+func LoadCache(path string) (Cache, error) {
+	var cfg Cache
+	if err := load(path, &cfg); err != nil {
+		return Cache{}, err
+	}
+
+	if cfg.Size <= 0 {
+		cfg.Size = defaultLRUSize
+	}
+
+	return cfg, nil
+}
+
+func (c Cache) RedisAddr() string {
+	if c.Host == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("%s:%d", c.Host, c.Port)
+}
+
+// DSN builds the data source name expected by the database/sql driver registered for the adapter.
+func (c SQL) DSN() (string, error) {
+	switch c.Adapter {
+	case AdapterSQLite:
+		return c.Database, nil
+	case AdapterMySQL:
+		// The driver formats the DSN, so credentials with '/', '?' or '@' stay intact.
+		dsn := mysql.NewConfig()
+		dsn.User = c.Username
+		dsn.Passwd = c.Password
+		dsn.Net = "tcp"
+		dsn.Addr = fmt.Sprintf("%s:%d", c.Host, c.Port)
+		dsn.DBName = c.Database
+		dsn.ParseTime = true
+		if c.Encoding != "" {
+			dsn.Params = map[string]string{"charset": c.Encoding}
+		}
+		return dsn.FormatDSN(), nil
+	case AdapterPostgres:
+		sslMode := c.SSLMode
+		if sslMode == "" {
+			sslMode = "disable"
+		}
+		dsn := url.URL{
+			Scheme:   "postgres",
+			User:     url.UserPassword(c.Username, c.Password),
+			Host:     fmt.Sprintf("%s:%d", c.Host, c.Port),
+			Path:     c.Database,
+			RawQuery: url.Values{"sslmode": {sslMode}}.Encode(),
+		}
+		return dsn.String(), nil
+	default:
+		return "", fmt.Errorf("config.SQL.DSN: unsupported adapter %q", c.Adapter)
+	}
+}
+
+func load(path string, cfg interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config.load: failed to read %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("config.load: failed to parse %s: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQL_DSN(t *testing.T) {
+	cfg := SQL{Adapter: AdapterMySQL, Encoding: "utf8mb4", Host: "db", Port: 3306, Database: "phonebook",
+		Username: "app", Password: "p/ss?w@rd:1"}
+	dsn, err := cfg.DSN()
+	require.NoError(t, err)
+
+	parsed, err := mysql.ParseDSN(dsn)
+	require.NoError(t, err)
+	assert.Equal(t, "app", parsed.User)
+	assert.Equal(t, "p/ss?w@rd:1", parsed.Passwd)
+	assert.Equal(t, "db:3306", parsed.Addr)
+	assert.Equal(t, "phonebook", parsed.DBName)
+	assert.True(t, parsed.ParseTime)
+	assert.Equal(t, "utf8mb4", parsed.Params["charset"])
+
+	cfg.Adapter = AdapterPostgres
+	dsn, err = cfg.DSN()
+	require.NoError(t, err)
+	assert.Equal(t, "postgres://app:p%2Fss%3Fw%40rd%3A1@db:3306/phonebook?sslmode=disable", dsn)
+}
@@ -0,0 +1,113 @@
+package contact
+
+import (
+	"regexp"
+	"strings"
+)
+
+// postalCodePattern matches the postal code forms we see most: US ZIP and ZIP+4, the 5-7 digit codes
+// used across Europe and Israel, Canadian "A1A 1A1" and UK "SW1A 1AA".
+var postalCodePattern = regexp.MustCompile(`(?i)\b(\d{5}-\d{4}|\d{4,7}|[a-z]\d[a-z] ?\d[a-z]\d|[a-z]{1,2}\d[a-z\d]? ?\d[a-z]{2})\b`)
+
+// countryCodes maps lower-cased country names and common aliases to ISO 3166-1 alpha-2 codes.
+// Bare two-letter codes are deliberately left out, "CA" at the end of an address is far more
+// often California than Canada.
+var countryCodes = map[string]string{
+	"argentina": "AR", "australia": "AU", "austria": "AT", "belgium": "BE", "brazil": "BR",
+	"canada": "CA", "china": "CN", "denmark": "DK", "finland": "FI", "france": "FR",
+	"germany": "DE", "greece": "GR", "india": "IN", "ireland": "IE", "israel": "IL",
+	"italy": "IT", "japan": "JP", "mexico": "MX", "netherlands": "NL", "the netherlands": "NL",
+	"new zealand": "NZ", "norway": "NO", "poland": "PL", "portugal": "PT", "russia": "RU",
+	"south africa": "ZA", "spain": "ES", "sweden": "SE", "switzerland": "CH", "turkey": "TR",
+	"ukraine": "UA", "united kingdom": "GB", "uk": "GB", "great britain": "GB", "england": "GB",
+	"united states": "US", "united states of america": "US", "usa": "US",
+}
+
+// CountryCode returns the ISO 3166-1 alpha-2 code for a country name, alias or code, or "" when unknown.
+func CountryCode(country string) string {
+	country = strings.TrimSpace(country)
+	if len(country) == 2 && isLetters(country) {
+		return strings.ToUpper(country)
+	}
+
+	return countryCodes[strings.ToLower(strings.Trim(country, "."))]
+}
+
+// HasStructure reports whether any structured field is set.
+func (a Address) HasStructure() bool {
+	return a.Street != "" || a.City != "" || a.Region != "" || a.PostalCode != "" || a.CountryCode != ""
+}
+
+// Format renders the structured fields as a single line: "street, city, region postal code, country code".
+func (a Address) Format() string {
+	var parts []string
+	for _, part := range []string{a.Street, a.City, strings.TrimSpace(a.Region + " " + a.PostalCode), a.CountryCode} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// ParseAddress upgrades a free-text address of the form "street, city[, region] [postal code][, country]"
+// into its structured fields. It is a best effort: anything it can't place stays in Street.
+func ParseAddress(text string) Address {
+	a := Address{Address: strings.TrimSpace(text)}
+
+	var parts []string
+	for _, part := range strings.Split(text, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		return a
+	}
+
+	if len(parts) > 1 {
+		if code := countryCodes[strings.ToLower(strings.Trim(parts[len(parts)-1], "."))]; code != "" {
+			a.CountryCode = code
+			parts = parts[:len(parts)-1]
+		}
+	}
+
+	// The postal code sits in the last parts, never in the street: "12 Herzl St" is a house number.
+	for i := len(parts) - 1; i > 0 && a.PostalCode == ""; i-- {
+		if loc := postalCodePattern.FindStringIndex(parts[i]); loc != nil {
+			a.PostalCode = strings.ToUpper(parts[i][loc[0]:loc[1]])
+			parts[i] = strings.TrimSpace(parts[i][:loc[0]] + parts[i][loc[1]:])
+		}
+	}
+	if a.PostalCode != "" {
+		var remaining []string
+		for _, part := range parts {
+			if part != "" {
+				remaining = append(remaining, part)
+			}
+		}
+		parts = remaining
+	}
+
+	switch len(parts) {
+	case 0:
+	case 1:
+		a.Street = parts[0]
+	case 2:
+		a.Street, a.City = parts[0], parts[1]
+	default:
+		a.Street, a.City, a.Region = parts[0], parts[1], strings.Join(parts[2:], ", ")
+	}
+
+	return a
+}
+
+func isLetters(s string) bool {
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') {
+			return false
+		}
+	}
+
+	return true
+}
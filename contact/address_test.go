@@ -0,0 +1,56 @@
+package contact
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected Address
+	}{
+		{
+			text:     "12 Herzl St, Haifa",
+			expected: Address{Street: "12 Herzl St", City: "Haifa"},
+		},
+		{
+			text:     "12 Herzl St, Haifa 3303112, Israel",
+			expected: Address{Street: "12 Herzl St", City: "Haifa", PostalCode: "3303112", CountryCode: "IL"},
+		},
+		{
+			text:     "123 Main St, Anytown, CA 12345, USA",
+			expected: Address{Street: "123 Main St", City: "Anytown", Region: "CA", PostalCode: "12345", CountryCode: "US"},
+		},
+		{
+			text:     "10 Downing St, London, SW1A 2AA, United Kingdom",
+			expected: Address{Street: "10 Downing St", City: "London", PostalCode: "SW1A 2AA", CountryCode: "GB"},
+		},
+		{
+			text:     "somewhere in the north",
+			expected: Address{Street: "somewhere in the north"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			expected := tt.expected
+			expected.Address = tt.text
+			assert.Equal(t, expected, ParseAddress(tt.text))
+		})
+	}
+}
+
+func TestNormalize_DerivesAddressFields(t *testing.T) {
+	c := Contact{Addresses: []Address{
+		{Address: "12 Herzl St, Haifa, Israel"},
+		{Label: LabelWork, Street: "1 Main St", City: "Springfield", CountryCode: "us"},
+	}}
+
+	c.Normalize()
+
+	assert.Equal(t, Address{Label: LabelOther, Address: "12 Herzl St, Haifa, Israel", Street: "12 Herzl St", City: "Haifa", CountryCode: "IL", Primary: true}, c.Addresses[0])
+	assert.Equal(t, Address{Label: LabelWork, Address: "1 Main St, Springfield, US", Street: "1 Main St", City: "Springfield", CountryCode: "US"}, c.Addresses[1])
+	assert.Equal(t, "12 Herzl St, Haifa, Israel", c.Address)
+}
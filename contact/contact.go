@@ -1,15 +1,247 @@
 package contact
 
+import (
+	"time"
+
+	"github.com/ShaynaSegal45/phonebook-api/query"
+)
+
+// Contact holds labelled collections of phones, emails and addresses. Phone and Address mirror the
+// primary phone and address for clients of the single-value API. Groups lists the names of the groups
+// the contact is in, it is read-only: membership is managed through the groups.
 type Contact struct {
-	ID        string `json:"id"`
-	FirstName string `json:"firstName"`
-	LastName  string `json:"lastName"`
-	Phone     string `json:"phone"`
-	Address   string `json:"address"`
+	ID        string    `json:"id"`
+	FirstName string    `json:"firstName"`
+	LastName  string    `json:"lastName"`
+	Phone     string    `json:"phone"`
+	Address   string    `json:"address"`
+	Phones    []Phone   `json:"phones"`
+	Emails    []Email   `json:"emails"`
+	Addresses []Address `json:"addresses"`
+	Groups    []string  `json:"groups"`
+	// Version counts the writes to the contact, its groups included. It is sent as the ETag rather
+	// than in the body; on a write it is the version the writer expects to replace, 0 for any.
+	Version int64 `json:"-"`
+	// CreatedAt and UpdatedAt are stamped by the service on every write, in UTC, with the actor
+	// that made it in CreatedBy and UpdatedBy. Clients can't set them.
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	CreatedBy string    `json:"createdBy"`
+	UpdatedBy string    `json:"updatedBy"`
+	// Highlights are the fields matching a search's fullText, with the matches in <mark> tags.
+	// Only searches asking for them fill them in.
+	Highlights map[string]string `json:"highlights,omitempty"`
+	// Score rates a fuzzy search hit from 0 to 1.
+	Score float64 `json:"score,omitempty"`
+}
+
+// Phone keeps the number as the client formatted it for display, E164 is its canonical form.
+type Phone struct {
+	Label   string `json:"label"`
+	Number  string `json:"number"`
+	E164    string `json:"e164"`
+	Primary bool   `json:"primary"`
+}
+
+type Email struct {
+	Label   string `json:"label"`
+	Email   string `json:"email"`
+	Primary bool   `json:"primary"`
+}
+
+// Address keeps the single-line address next to its structured fields. Either one is enough on write,
+// Normalize derives the other.
+type Address struct {
+	Label       string `json:"label"`
+	Address     string `json:"address"`
+	Street      string `json:"street"`
+	City        string `json:"city"`
+	Region      string `json:"region"`
+	PostalCode  string `json:"postalCode"`
+	CountryCode string `json:"countryCode"`
+	Primary     bool   `json:"primary"`
 }
 
+// Filters narrow a search. City, Region and PostalCode match any of a contact's addresses
+// case-insensitively, Country is an ISO 3166-1 alpha-2 code. PhoneDigits are the digits of a
+// phone-like FullText as they appear in an E.164 number, so formatting doesn't matter to the search.
+// AnyTags matches contacts in at least one of the groups, AllTags contacts in every one of them.
+// Query is a parsed search query, ANDed with the rest. Match set to MatchFuzzy also finds names
+// spelled like the FullText and orders the hits by their Score. Locale is the collation.Locales
+// entry the names are ordered by, the root order when empty. Sort replaces the default order, by
+// rank and then name, and Fields narrows the contacts returned to some of their JSON fields.
+// After and Before page through the name order from a Cursor instead of an Offset. UpdatedSince
+// keeps the contacts written at or after it and CreatedBefore the ones created before it.
 type Filters struct {
-	FullText string
-	Limit    int
-	Offset   int
+	FullText      string
+	Query         *query.Node
+	PhoneDigits   string
+	City          string
+	Region        string
+	PostalCode    string
+	Country       string
+	AnyTags       []string
+	AllTags       []string
+	Highlight     bool
+	Match         string
+	Locale        string
+	Sort          []SortField
+	Fields        []string
+	After         *Cursor
+	Before        *Cursor
+	UpdatedSince  time.Time
+	CreatedBefore time.Time
+	Limit         int
+	Offset        int
+}
+
+// Change stamps a write that isn't a contact's own, such as a group change its members record:
+// when it happened and who made it.
+type Change struct {
+	At time.Time
+	By string
+}
+
+// Cursor is a contact's position in the name order of a search. The zero Cursor comes before
+// every contact.
+type Cursor struct {
+	LastName  string
+	FirstName string
+	ID        string
+}
+
+// MatchFuzzy is the Filters.Match of a search for near matches of a name.
+const MatchFuzzy = "fuzzy"
+
+const (
+	LabelMobile = "mobile"
+	LabelWork   = "work"
+	LabelHome   = "home"
+	LabelFax    = "fax"
+	LabelOther  = "other"
+)
+
+var (
+	phoneLabels   = map[string]bool{LabelMobile: true, LabelWork: true, LabelHome: true, LabelFax: true}
+	emailLabels   = map[string]bool{LabelHome: true, LabelWork: true, LabelOther: true}
+	addressLabels = map[string]bool{LabelHome: true, LabelWork: true, LabelOther: true}
+)
+
+func IsPhoneLabel(label string) bool   { return phoneLabels[label] }
+func IsEmailLabel(label string) bool   { return emailLabels[label] }
+func IsAddressLabel(label string) bool { return addressLabels[label] }
+
+// Normalize fills in default labels, makes sure every non-empty collection has exactly one primary
+// entry (the first one when none is marked), and mirrors the primaries into Phone and Address.
+// A contact that only has the single-value Phone or Address gets them as its primary entries.
+func (c *Contact) Normalize() {
+	if len(c.Phones) == 0 && c.Phone != "" {
+		c.Phones = []Phone{{Label: LabelMobile, Number: c.Phone, Primary: true}}
+	}
+	if len(c.Addresses) == 0 && c.Address != "" {
+		c.Addresses = []Address{{Label: LabelHome, Address: c.Address, Primary: true}}
+	}
+
+	for i := range c.Phones {
+		if c.Phones[i].Label == "" {
+			c.Phones[i].Label = LabelMobile
+		}
+	}
+	for i := range c.Emails {
+		if c.Emails[i].Label == "" {
+			c.Emails[i].Label = LabelOther
+		}
+	}
+	for i := range c.Addresses {
+		a := &c.Addresses[i]
+		if a.Label == "" {
+			a.Label = LabelOther
+		}
+		if !a.HasStructure() && a.Address != "" {
+			parsed := ParseAddress(a.Address)
+			parsed.Label, parsed.Primary = a.Label, a.Primary
+			*a = parsed
+		}
+		if code := CountryCode(a.CountryCode); code != "" {
+			a.CountryCode = code
+		}
+		if a.Address == "" {
+			a.Address = a.Format()
+		}
+	}
+
+	if i := primaryIndex(len(c.Phones), func(i int) bool { return c.Phones[i].Primary }); i >= 0 {
+		for j := range c.Phones {
+			c.Phones[j].Primary = j == i
+		}
+		c.Phone = c.Phones[i].Number
+	} else if c.Phones != nil {
+		c.Phone = ""
+	}
+
+	if i := primaryIndex(len(c.Emails), func(i int) bool { return c.Emails[i].Primary }); i >= 0 {
+		for j := range c.Emails {
+			c.Emails[j].Primary = j == i
+		}
+	}
+
+	if i := primaryIndex(len(c.Addresses), func(i int) bool { return c.Addresses[i].Primary }); i >= 0 {
+		for j := range c.Addresses {
+			c.Addresses[j].Primary = j == i
+		}
+		c.Address = c.Addresses[i].Address
+	} else if c.Addresses != nil {
+		c.Address = ""
+	}
+}
+
+// SetPrimaryPhone returns phones with the primary number replaced, adding a mobile phone when there are none.
+// An empty number removes the primary phone.
+func SetPrimaryPhone(phones []Phone, number string) []Phone {
+	updated := append([]Phone{}, phones...)
+	if i := primaryIndex(len(updated), func(i int) bool { return updated[i].Primary }); i >= 0 {
+		if number == "" {
+			return append(updated[:i], updated[i+1:]...)
+		}
+		updated[i].Number = number
+		return updated
+	}
+
+	if number == "" {
+		return updated
+	}
+	return append(updated, Phone{Label: LabelMobile, Number: number, Primary: true})
+}
+
+// SetPrimaryAddress returns addresses with the primary address replaced, adding a home address when there are none.
+// An empty address removes the primary address.
+func SetPrimaryAddress(addresses []Address, address string) []Address {
+	updated := append([]Address{}, addresses...)
+	if i := primaryIndex(len(updated), func(i int) bool { return updated[i].Primary }); i >= 0 {
+		if address == "" {
+			return append(updated[:i], updated[i+1:]...)
+		}
+		updated[i].Address = address
+		return updated
+	}
+
+	if address == "" {
+		return updated
+	}
+	return append(updated, Address{Label: LabelHome, Address: address, Primary: true})
+}
+
+// primaryIndex returns the first entry marked primary, or 0 when none is, or -1 for an empty collection.
+func primaryIndex(n int, isPrimary func(i int) bool) int {
+	for i := 0; i < n; i++ {
+		if isPrimary(i) {
+			return i
+		}
+	}
+
+	if n > 0 {
+		return 0
+	}
+
+	return -1
 }
@@ -0,0 +1,88 @@
+package contact
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SortField orders a search by one field of the contacts, descending when Desc is set.
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+const (
+	SortID        = "id"
+	SortFirstName = "firstName"
+	SortLastName  = "lastName"
+	SortCreatedAt = "createdAt"
+	SortUpdatedAt = "updatedAt"
+)
+
+var sortFields = map[string]bool{SortID: true, SortFirstName: true, SortLastName: true, SortCreatedAt: true, SortUpdatedAt: true}
+
+// ParseSort reads a comma-separated list of sortable fields, each prefixed with '-' to sort it in
+// descending order, such as `-lastName,firstName`.
+func ParseSort(value string) ([]SortField, error) {
+	var sort []SortField
+	seen := map[string]bool{}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		s := SortField{Field: strings.TrimPrefix(item, "-"), Desc: strings.HasPrefix(item, "-")}
+		if !sortFields[s.Field] {
+			return nil, fmt.Errorf("unsupported sort field %q", s.Field)
+		}
+		if seen[s.Field] {
+			return nil, fmt.Errorf("sort field %q repeats", s.Field)
+		}
+		seen[s.Field] = true
+		sort = append(sort, s)
+	}
+
+	return sort, nil
+}
+
+// Fields are the JSON fields of a Contact a search can be narrowed to.
+var Fields = []string{"id", "firstName", "lastName", "phone", "address", "phones", "emails", "addresses", "groups",
+	"createdAt", "updatedAt", "createdBy", "updatedBy", "highlights", "score"}
+
+// ParseFields reads a comma-separated sparse fieldset such as `id,firstName,phone`. The id is
+// always part of it.
+func ParseFields(value string) ([]string, error) {
+	var fields []string
+	seen := map[string]bool{}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" || seen[item] {
+			continue
+		}
+		if !HasField(Fields, item) {
+			return nil, fmt.Errorf("unknown field %q", item)
+		}
+		seen[item] = true
+		fields = append(fields, item)
+	}
+	if len(fields) > 0 && !seen["id"] {
+		fields = append([]string{"id"}, fields...)
+	}
+
+	return fields, nil
+}
+
+// HasField tells whether a search narrowed to fields returns the given one; every field is
+// returned when fields is empty.
+func HasField(fields []string, name string) bool {
+	if len(fields) == 0 {
+		return true
+	}
+	for _, field := range fields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package contact
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSort(t *testing.T) {
+	sort, err := ParseSort(" -lastName, firstName,")
+	require.NoError(t, err)
+	assert.Equal(t, []SortField{{Field: SortLastName, Desc: true}, {Field: SortFirstName}}, sort)
+
+	sort, err = ParseSort("-updatedAt,createdAt")
+	require.NoError(t, err)
+	assert.Equal(t, []SortField{{Field: SortUpdatedAt, Desc: true}, {Field: SortCreatedAt}}, sort)
+
+	sort, err = ParseSort("")
+	require.NoError(t, err)
+	assert.Empty(t, sort)
+
+	for _, value := range []string{"phone", "-", "firstName,-firstName"} {
+		_, err := ParseSort(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestParseFields(t *testing.T) {
+	fields, err := ParseFields("firstName,phone,firstName")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id", "firstName", "phone"}, fields)
+
+	fields, err = ParseFields("")
+	require.NoError(t, err)
+	assert.Empty(t, fields)
+
+	_, err = ParseFields("id,password")
+	assert.Error(t, err)
+
+	assert.True(t, HasField(nil, "phones"))
+	assert.False(t, HasField([]string{"id"}, "phones"))
+}
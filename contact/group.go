@@ -0,0 +1,71 @@
+package contact
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+
+	"github.com/ShaynaSegal45/phonebook-api/query"
+)
+
+const maxGroupNameLength = 64
+
+// Group tags contacts, a contact can be in any number of groups. Names are unique and
+// case-insensitive, they are stored lower-cased and double as the tag filters of a search.
+// A smart group has a Query instead of members, in the search language of GET /contacts: its contacts
+// are whoever matches it at the time.
+type Group struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Query string `json:"query,omitempty"`
+}
+
+func (g Group) IsSmart() bool {
+	return g.Query != ""
+}
+
+// Filters returns the search that lists the group's contacts.
+func (g Group) Filters() (Filters, error) {
+	if g.IsSmart() {
+		q, err := ParseGroupQuery(g.Query)
+		if err != nil {
+			return Filters{}, err
+		}
+		return Filters{Query: q}, nil
+	}
+
+	return Filters{AllTags: []string{g.Name}}, nil
+}
+
+// ParseGroupQuery parses the query of a smart group, which unlike a search must match something.
+func ParseGroupQuery(q string) (*query.Node, error) {
+	n, err := query.Parse(q)
+	if err != nil {
+		return nil, err
+	}
+	if n == nil {
+		return nil, errors.New("empty query")
+	}
+
+	return n, nil
+}
+
+// NormalizeGroupName trims and lower-cases a group name.
+func NormalizeGroupName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// IsGroupName reports whether a normalized name is made of letters, digits, '-' and '_' only.
+func IsGroupName(name string) bool {
+	if name == "" || len(name) > maxGroupNameLength {
+		return false
+	}
+
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,32 @@
+package contact
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ShaynaSegal45/phonebook-api/query"
+)
+
+func TestGroup_Filters(t *testing.T) {
+	f, err := Group{Name: "vip"}.Filters()
+	require.NoError(t, err)
+	assert.Equal(t, Filters{AllTags: []string{"vip"}}, f)
+
+	f, err = Group{Name: "haifa", Query: `city:Haifa tag:customer`}.Filters()
+	require.NoError(t, err)
+	want, err := query.Parse(`city:Haifa tag:customer`)
+	require.NoError(t, err)
+	assert.Equal(t, Filters{Query: want}, f)
+}
+
+func TestParseGroupQuery_Invalid(t *testing.T) {
+	_, err := ParseGroupQuery(" ")
+	assert.EqualError(t, err, "empty query")
+
+	_, err = ParseGroupQuery(`city:Haifa AND (tag:vip`)
+	var syntaxErr *query.SyntaxError
+	require.ErrorAs(t, err, &syntaxErr)
+	assert.Equal(t, 23, syntaxErr.Pos)
+}
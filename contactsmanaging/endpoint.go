@@ -2,46 +2,90 @@ package contactsmanaging
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/mail"
 	"net/url"
+	"strings"
 
 	"github.com/ShaynaSegal45/phonebook-api/contact"
+	"github.com/ShaynaSegal45/phonebook-api/cursor"
+	"github.com/ShaynaSegal45/phonebook-api/errors"
+	"github.com/ShaynaSegal45/phonebook-api/patch"
 )
 
+// maxPatchAttempts bounds how often a PATCH without If-Match is reapplied when another write
+// replaced the contact between reading and writing it.
+const maxPatchAttempts = 3
+
+// Pagination links the pages around the current one. Base is the absolute URL of the request, and
+// its query the parameters every link keeps.
 type Pagination struct {
-	next        *paginationValues
-	prev        *paginationValues
-	count       int
-	queryParams map[string]string
+	base  url.URL
+	query url.Values
+	next  *paginationValues
+	prev  *paginationValues
+	first *paginationValues
+	last  *paginationValues
+	count int
 }
 
+// paginationValues point at a page by its offset, or by the after or before token of a cursor
+// when cursor is set. An empty token points at the first page, or the last one when before is set.
 type paginationValues struct {
 	limit  int64
 	offset int64
+	cursor bool
+	before bool
+	token  string
 }
 
 type SearchContactsResponse struct {
 	Contacts           []contact.Contact `json:"contacts"`
 	Pagination         Pagination        `json:"pagination"`
 	TotalContactsCount int               `json:"count"`
+	// Fields narrows the contacts to some of their JSON fields, all of them when empty.
+	Fields []string `json:"-"`
 }
 
 type Endpoints struct {
-	AddContactEndpoint    http.HandlerFunc
-	GetContactsEndpoint   http.HandlerFunc
-	GetContactEndpoint    http.HandlerFunc
-	UpdateContactEndpoint http.HandlerFunc
-	DeleteContactEndpoint http.HandlerFunc
+	AddContactEndpoint         http.HandlerFunc
+	GetContactsEndpoint        http.HandlerFunc
+	GetContactEndpoint         http.HandlerFunc
+	GetContactsByPhoneEndpoint http.HandlerFunc
+	SuggestContactsEndpoint    http.HandlerFunc
+	UpdateContactEndpoint      http.HandlerFunc
+	PatchContactEndpoint       http.HandlerFunc
+	DeleteContactEndpoint      http.HandlerFunc
+	AddGroupEndpoint           http.HandlerFunc
+	GetGroupsEndpoint          http.HandlerFunc
+	GetGroupEndpoint           http.HandlerFunc
+	UpdateGroupEndpoint        http.HandlerFunc
+	DeleteGroupEndpoint        http.HandlerFunc
+	GetGroupContactsEndpoint   http.HandlerFunc
+	AddGroupMemberEndpoint     http.HandlerFunc
+	RemoveGroupMemberEndpoint  http.HandlerFunc
 }
 
-func MakeEndpoints(s Service) Endpoints {
+func MakeEndpoints(s Service, cursors *cursor.Codec) Endpoints {
 	return Endpoints{
-		AddContactEndpoint:    makeAddContactEndpoint(s),
-		GetContactsEndpoint:   makeGetContactsEndpoint(s),
-		GetContactEndpoint:    makeGetContactEndpoint(s),
-		UpdateContactEndpoint: makeUpdateContactEndpoint(s),
-		DeleteContactEndpoint: makeDeleteContactEndpoint(s),
+		AddContactEndpoint:         makeAddContactEndpoint(s),
+		GetContactsEndpoint:        makeGetContactsEndpoint(s, cursors),
+		GetContactEndpoint:         makeGetContactEndpoint(s),
+		GetContactsByPhoneEndpoint: makeGetContactsByPhoneEndpoint(s),
+		SuggestContactsEndpoint:    makeSuggestContactsEndpoint(s),
+		UpdateContactEndpoint:      makeUpdateContactEndpoint(s),
+		PatchContactEndpoint:       makePatchContactEndpoint(s),
+		DeleteContactEndpoint:      makeDeleteContactEndpoint(s),
+		AddGroupEndpoint:           makeAddGroupEndpoint(s),
+		GetGroupsEndpoint:          makeGetGroupsEndpoint(s),
+		GetGroupEndpoint:           makeGetGroupEndpoint(s),
+		UpdateGroupEndpoint:        makeUpdateGroupEndpoint(s),
+		DeleteGroupEndpoint:        makeDeleteGroupEndpoint(s),
+		GetGroupContactsEndpoint:   makeGetGroupContactsEndpoint(s),
+		AddGroupMemberEndpoint:     makeAddGroupMemberEndpoint(s),
+		RemoveGroupMemberEndpoint:  makeRemoveGroupMemberEndpoint(s),
 	}
 }
 
@@ -63,7 +107,7 @@ func makeAddContactEndpoint(s Service) http.HandlerFunc {
 			return
 		}
 
-		id, err := s.AddContact(context.Background(), req.toContact())
+		id, err := s.AddContact(writeContext(r), req.toContact())
 		if err != nil {
 			http.Error(w, err.Error(), err.StatusCode)
 			return
@@ -86,17 +130,35 @@ func makeGetContactEndpoint(s Service) http.HandlerFunc {
 			return
 		}
 
-		contact, err := s.GetContact(context.Background(), req.ID)
+		get := s.GetContact
+		if req.IfNoneMatch != "" {
+			// A stale cached version would answer 304 for a contact that changed.
+			get = s.GetCurrentContact
+		}
+		contact, err := get(context.Background(), req.ID)
 		if err != nil {
 			http.Error(w, err.Error(), err.StatusCode)
 			return
 		}
+		w.Header().Set("ETag", etag(contact.Version))
+		if req.IfNoneMatch != "" && matchesETag(req.IfNoneMatch, contact.Version, true) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
 		response := GetContactResponse{
 			ID:        contact.ID,
 			FirstName: contact.FirstName,
 			LastName:  contact.LastName,
 			Phone:     contact.Phone,
 			Address:   contact.Address,
+			Phones:    contact.Phones,
+			Emails:    contact.Emails,
+			Addresses: contact.Addresses,
+			Groups:    contact.Groups,
+			CreatedAt: contact.CreatedAt,
+			UpdatedAt: contact.UpdatedAt,
+			CreatedBy: contact.CreatedBy,
+			UpdatedBy: contact.UpdatedBy,
 		}
 
 		encodeGetContactResponse(w, response)
@@ -121,14 +183,116 @@ func makeUpdateContactEndpoint(s Service) http.HandlerFunc {
 			return
 		}
 
-		if err := s.UpdateContact(context.Background(), req.toContact()); err != nil {
+		c := req.toContact()
+		expected, err := expectedVersion(s, req.ID, req.IfMatch)
+		if err != nil {
+			http.Error(w, err.Error(), err.StatusCode)
+			return
+		}
+		c.Version = expected
+
+		version, err := s.UpdateContact(writeContext(r), c)
+		if err != nil {
 			http.Error(w, err.Error(), err.StatusCode)
 			return
 		}
+		w.Header().Set("ETag", etag(version))
 		encodeUpdateContactResponse(w)
 	}
 }
 
+// makePatchContactEndpoint applies the patch to the stored contact, read past the cache, as an
+// UpdateContactRequest would carry it. A patch that doesn't apply to the contact, a failed test operation included, conflicts
+// with its current state.
+func makePatchContactEndpoint(s Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		request, decodeErr := decodePatchContactRequest(r)
+		if decodeErr == errUnsupportedPatchType {
+			http.Error(w, decodeErr.Error(), http.StatusUnsupportedMediaType)
+			return
+		}
+		if decodeErr != nil {
+			http.Error(w, decodeErr.Error(), http.StatusBadRequest)
+			return
+		}
+		req, ok := request.(PatchContactRequest)
+		if !ok {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		for attempt := 1; ; attempt++ {
+			original, err := s.GetCurrentContact(context.Background(), req.ID)
+			if err != nil {
+				http.Error(w, err.Error(), err.StatusCode)
+				return
+			}
+			if req.IfMatch != "" && !matchesETag(req.IfMatch, original.Version, false) {
+				http.Error(w, fmt.Sprintf("contact is no longer at %s", req.IfMatch), http.StatusPreconditionFailed)
+				return
+			}
+			patched, status, patchErr := applyContactPatch(original, req.Patch)
+			if patchErr != nil {
+				http.Error(w, patchErr.Error(), status)
+				return
+			}
+
+			version, err := s.PatchContact(writeContext(r), original, patched.toContact())
+			if err != nil && err.StatusCode == errors.PreconditionFailedError && req.IfMatch == "" && attempt < maxPatchAttempts {
+				continue
+			}
+			if err != nil {
+				http.Error(w, err.Error(), err.StatusCode)
+				return
+			}
+			w.Header().Set("ETag", etag(version))
+			encodeUpdateContactResponse(w)
+			return
+		}
+	}
+}
+
+// applyContactPatch returns the contact the patch makes of original, or the status answering why
+// it can't.
+func applyContactPatch(original contact.Contact, p patch.Patch) (UpdateContactRequest, int, error) {
+	doc, err := json.Marshal(toUpdateContactRequest(original))
+	if err != nil {
+		return UpdateContactRequest{}, http.StatusInternalServerError, err
+	}
+	doc, err = p.Apply(doc)
+	if err != nil {
+		return UpdateContactRequest{}, http.StatusConflict, err
+	}
+	patched, err := decodePatchedContact(original.ID, doc)
+	if err != nil {
+		return UpdateContactRequest{}, http.StatusBadRequest, err
+	}
+	if err := patched.Validate(); err != nil {
+		return UpdateContactRequest{}, http.StatusBadRequest, err
+	}
+
+	return patched, http.StatusOK, nil
+}
+
+// expectedVersion is the version an If-Match header lets a write replace, 0 without the header.
+// It is read past the cache; the write still fails when the contact changes after this check.
+func expectedVersion(s Service, id, ifMatch string) (int64, *errors.Error) {
+	if ifMatch == "" {
+		return 0, nil
+	}
+
+	current, err := s.GetCurrentContact(context.Background(), id)
+	if err != nil {
+		return 0, err.ErrorWrapper(operationName, "expectedVersion")
+	}
+	if !matchesETag(ifMatch, current.Version, false) {
+		mismatch := fmt.Errorf("contact with id %s is no longer at %s", id, ifMatch)
+		return 0, errors.CreateError(operationName, "expectedVersion", mismatch, errors.PreconditionFailedError)
+	}
+
+	return current.Version, nil
+}
+
 func makeDeleteContactEndpoint(s Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		request, decodeErr := decodeDeleteContactRequest(r)
@@ -143,7 +307,12 @@ func makeDeleteContactEndpoint(s Service) http.HandlerFunc {
 			return
 		}
 
-		if err := s.DeleteContact(context.Background(), req.ID); err != nil {
+		version, err := expectedVersion(s, req.ID, req.IfMatch)
+		if err != nil {
+			http.Error(w, err.Error(), err.StatusCode)
+			return
+		}
+		if err := s.DeleteContact(context.Background(), req.ID, version); err != nil {
 			http.Error(w, err.Error(), err.StatusCode)
 			return
 		}
@@ -151,7 +320,47 @@ func makeDeleteContactEndpoint(s Service) http.HandlerFunc {
 	}
 }
 
-func makeGetContactsEndpoint(s Service) http.HandlerFunc {
+func makeGetContactsByPhoneEndpoint(s Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		request, decodeErr := decodeGetContactsByPhoneRequest(r)
+		if decodeErr != nil {
+			http.Error(w, decodeErr.Error(), http.StatusBadRequest)
+			return
+		}
+		req, ok := request.(GetContactsByPhoneRequest)
+		if !ok {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		contacts, err := s.GetContactsByPhone(context.Background(), req.Number)
+		if err != nil {
+			http.Error(w, err.Error(), err.StatusCode)
+			return
+		}
+
+		encodeGetContactsByPhoneResponse(w, contacts)
+	}
+}
+
+func makeSuggestContactsEndpoint(s Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		request, decodeErr := decodeSuggestContactsRequest(r)
+		if decodeErr != nil {
+			http.Error(w, decodeErr.Error(), http.StatusBadRequest)
+			return
+		}
+		req, ok := request.(SuggestContactsRequest)
+		if !ok {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		encodeSuggestContactsResponse(w, s.SuggestContacts(context.Background(), req.Query, req.Limit))
+	}
+}
+
+func makeGetContactsEndpoint(s Service, cursors *cursor.Codec) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		request, decodeErr := decodeSearchContactsRequest(r)
 		if decodeErr != nil {
@@ -164,31 +373,47 @@ func makeGetContactsEndpoint(s Service) http.HandlerFunc {
 			return
 		}
 
-		contacts, err := s.GetContacts(context.Background(), req.toFilters())
+		filters := req.toFilters()
+		if req.Cursor {
+			if decodeErr := decodeCursors(cursors, req, &filters); decodeErr != nil {
+				http.Error(w, decodeErr.Error(), http.StatusBadRequest)
+				return
+			}
+			// One contact past the page tells whether there is another page in that direction.
+			filters.Limit++
+		}
+
+		contacts, err := s.GetContacts(context.Background(), filters)
 		if err != nil {
 			http.Error(w, err.Error(), err.StatusCode)
 			return
 		}
 
-		totalContacts, err := s.CountContacts(context.Background(), req.Text)
+		totalContacts, err := s.CountContacts(context.Background(), filters)
 		if err != nil {
 			http.Error(w, err.Error(), err.StatusCode)
 			return
 		}
 
-		pagination := createPagination(req.Offset, req.Limit, totalContacts, r.URL)
+		var pagination Pagination
+		if req.Cursor {
+			contacts, pagination = createCursorPagination(cursors, req, contacts, totalContacts, r)
+		} else {
+			pagination = createPagination(req.Offset, req.Limit, totalContacts, r)
+		}
 
 		response := SearchContactsResponse{
 			Contacts:           contacts,
 			Pagination:         pagination,
 			TotalContactsCount: totalContacts,
+			Fields:             req.Fields,
 		}
 
 		encodeSearchContactsHandlerResponse(w, response)
 	}
 }
 
-func createPagination(offset, limit, totalContacts int, url *url.URL) Pagination {
+func createPagination(offset, limit, totalContacts int, r *http.Request) Pagination {
 	var next, prev *paginationValues
 
 	if offset+limit < totalContacts {
@@ -205,20 +430,115 @@ func createPagination(offset, limit, totalContacts int, url *url.URL) Pagination
 		}
 	}
 
-	queryParams := url.Query()
-	queryParamsMap := make(map[string]string)
-	for key, values := range queryParams {
-		if len(values) > 0 {
-			queryParamsMap[key] = values[0]
-		}
+	pagination := newPagination(totalContacts, r)
+	pagination.next, pagination.prev = next, prev
+	pagination.first = &paginationValues{limit: int64(limit)}
+	pagination.last = &paginationValues{limit: int64(limit), offset: int64(max(0, totalContacts-1) / limit * limit)}
+	return pagination
+}
+
+func newPagination(totalContacts int, r *http.Request) Pagination {
+	query := r.URL.Query()
+	for _, param := range []string{limitParam, offsetParam, countParam, afterParam, beforeParam} {
+		query.Del(param)
+	}
+
+	return Pagination{base: requestURL(r), query: query, count: totalContacts}
+}
+
+// requestURL is the absolute URL the client asked for, without its query. Behind a proxy, the
+// scheme and host come from the X-Forwarded-Proto and X-Forwarded-Host headers.
+func requestURL(r *http.Request) url.URL {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := firstForwarded(r.Header.Get("X-Forwarded-Proto")); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+
+	host := r.Host
+	if forwardedHost := firstForwarded(r.Header.Get("X-Forwarded-Host")); forwardedHost != "" {
+		host = forwardedHost
+	}
+
+	return url.URL{Scheme: scheme, Host: host, Path: r.URL.Path}
+}
+
+// writeContext is the context of a write, naming its actor from the X-Forwarded-User header that
+// the authenticating proxy in front of the service sets; NewHTTPHandler drops it from the requests
+// of any other source. Without the header the actor is unknown.
+func writeContext(r *http.Request) context.Context {
+	return WithActor(context.Background(), strings.TrimSpace(r.Header.Get("X-Forwarded-User")))
+}
+
+// firstForwarded returns the value the first proxy set, the one the client talked to.
+func firstForwarded(header string) string {
+	value, _, _ := strings.Cut(header, ",")
+	return strings.TrimSpace(value)
+}
+
+// decodeCursors verifies the after or before token of a request and sets its position in the filters.
+// A token only holds in the locale it was issued for.
+func decodeCursors(cursors *cursor.Codec, req SearchContactsRequest, filters *contact.Filters) error {
+	switch {
+	case req.After == "" && req.Before == "" && req.Backward:
+		filters.Before = &contact.Cursor{}
+		return nil
+	case req.After == "" && req.Before == "":
+		filters.After = &contact.Cursor{}
+		return nil
+	}
+
+	token := req.After
+	if token == "" {
+		token = req.Before
+	}
+	locale, position, err := cursors.Decode(token)
+	if err != nil {
+		return fmt.Errorf("decodeCursors: %w", err)
 	}
+	if locale != req.Locale {
+		return fmt.Errorf("decodeCursors: cursor was issued for locale %q", locale)
+	}
+
+	if req.After != "" {
+		filters.After = &position
+	} else {
+		filters.Before = &position
+	}
+	return nil
+}
 
-	return Pagination{
-		next:        next,
-		prev:        prev,
-		count:       totalContacts,
-		queryParams: queryParamsMap,
+// createCursorPagination drops the extra contact read past the page and links the pages on both
+// sides of it: the one after its last contact, and the one before its first.
+func createCursorPagination(cursors *cursor.Codec, req SearchContactsRequest, contacts []contact.Contact, totalContacts int, r *http.Request) ([]contact.Contact, Pagination) {
+	more := len(contacts) > req.Limit
+	if more && req.Backward {
+		contacts = contacts[1:]
+	} else if more {
+		contacts = contacts[:req.Limit]
+	}
+
+	limit := int64(req.Limit)
+	pagination := newPagination(totalContacts, r)
+	pagination.first = &paginationValues{limit: limit, cursor: true}
+	pagination.last = &paginationValues{limit: limit, cursor: true, before: true}
+	if len(contacts) == 0 {
+		return contacts, pagination
+	}
+
+	positionOf := func(c contact.Contact) string {
+		return cursors.Encode(req.Locale, contact.Cursor{LastName: c.LastName, FirstName: c.FirstName, ID: c.ID})
+	}
+	if more && !req.Backward || req.Before != "" {
+		pagination.next = &paginationValues{limit: limit, cursor: true, token: positionOf(contacts[len(contacts)-1])}
 	}
+	if more && req.Backward || req.After != "" {
+		pagination.prev = &paginationValues{limit: limit, cursor: true, before: true, token: positionOf(contacts[0])}
+	}
+
+	return contacts, pagination
 }
 
 func max(a, b int) int {
@@ -234,6 +554,9 @@ func (r CreateContactRequest) toContact() contact.Contact {
 		LastName:  r.LastName,
 		Phone:     r.Phone,
 		Address:   r.Address,
+		Phones:    r.Phones,
+		Emails:    r.Emails,
+		Addresses: r.Addresses,
 	}
 }
 
@@ -244,14 +567,46 @@ func (r UpdateContactRequest) toContact() contact.Contact {
 		LastName:  r.LastName,
 		Phone:     r.Phone,
 		Address:   r.Address,
+		Phones:    r.Phones,
+		Emails:    r.Emails,
+		Addresses: r.Addresses,
+	}
+}
+
+// toUpdateContactRequest is the document a patch applies to. Its collections are never null, so
+// a JSON Patch can append to them.
+func toUpdateContactRequest(c contact.Contact) UpdateContactRequest {
+	return UpdateContactRequest{
+		ID:        c.ID,
+		FirstName: c.FirstName,
+		LastName:  c.LastName,
+		Phone:     c.Phone,
+		Address:   c.Address,
+		Phones:    append([]contact.Phone{}, c.Phones...),
+		Emails:    append([]contact.Email{}, c.Emails...),
+		Addresses: append([]contact.Address{}, c.Addresses...),
 	}
 }
 
 func (r SearchContactsRequest) toFilters() contact.Filters {
 	return contact.Filters{
-		FullText: r.Text,
-		Limit:    r.Limit,
-		Offset:   r.Offset,
+		FullText:      r.Text,
+		Query:         r.Query,
+		City:          r.City,
+		Region:        r.Region,
+		PostalCode:    r.PostalCode,
+		Country:       r.Country,
+		AnyTags:       r.AnyTags,
+		AllTags:       r.AllTags,
+		Highlight:     r.Highlight,
+		Match:         r.Match,
+		Locale:        r.Locale,
+		Sort:          r.Sort,
+		Fields:        r.Fields,
+		UpdatedSince:  r.UpdatedSince,
+		CreatedBefore: r.CreatedBefore,
+		Limit:         r.Limit,
+		Offset:        r.Offset,
 	}
 }
 
@@ -260,6 +615,10 @@ func (r CreateContactRequest) Validate() error {
 		return fmt.Errorf("CreateContactRequest.Validate: must include firstname or lastname")
 	}
 
+	if err := validateDetails(r.Phones, r.Emails, r.Addresses); err != nil {
+		return fmt.Errorf("CreateContactRequest.Validate: %w", err)
+	}
+
 	return nil
 }
 
@@ -267,6 +626,293 @@ func (r UpdateContactRequest) Validate() error {
 	if r.ID == "" {
 		return fmt.Errorf("UpdateContactRequest.Validate: missing id")
 	}
+	if r.FirstName == "" && r.LastName == "" {
+		return fmt.Errorf("UpdateContactRequest.Validate: must include firstname or lastname")
+	}
+
+	if err := validateDetails(r.Phones, r.Emails, r.Addresses); err != nil {
+		return fmt.Errorf("UpdateContactRequest.Validate: %w", err)
+	}
+
+	return nil
+}
+
+// validateDetails checks labels and values of the collections; an empty label defaults later on.
+func validateDetails(phones []contact.Phone, emails []contact.Email, addresses []contact.Address) error {
+	primaries := 0
+	for _, p := range phones {
+		if p.Label != "" && !contact.IsPhoneLabel(p.Label) {
+			return fmt.Errorf("invalid phone label %q", p.Label)
+		}
+		if p.Number == "" {
+			return fmt.Errorf("phone number is required")
+		}
+		if p.Primary {
+			primaries++
+		}
+	}
+	if primaries > 1 {
+		return fmt.Errorf("only one phone can be primary")
+	}
+
+	primaries = 0
+	for _, e := range emails {
+		if e.Label != "" && !contact.IsEmailLabel(e.Label) {
+			return fmt.Errorf("invalid email label %q", e.Label)
+		}
+		if _, err := mail.ParseAddress(e.Email); err != nil {
+			return fmt.Errorf("invalid email %q", e.Email)
+		}
+		if e.Primary {
+			primaries++
+		}
+	}
+	if primaries > 1 {
+		return fmt.Errorf("only one email can be primary")
+	}
+
+	primaries = 0
+	for _, a := range addresses {
+		if a.Label != "" && !contact.IsAddressLabel(a.Label) {
+			return fmt.Errorf("invalid address label %q", a.Label)
+		}
+		if a.Address == "" && !a.HasStructure() {
+			return fmt.Errorf("address or one of its fields is required")
+		}
+		if a.CountryCode != "" && contact.CountryCode(a.CountryCode) == "" {
+			return fmt.Errorf("invalid country code %q", a.CountryCode)
+		}
+		if a.Primary {
+			primaries++
+		}
+	}
+	if primaries > 1 {
+		return fmt.Errorf("only one address can be primary")
+	}
+
+	return nil
+}
+
+func makeAddGroupEndpoint(s Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		request, decodeErr := decodeAddGroupRequest(r)
+		if decodeErr != nil {
+			http.Error(w, decodeErr.Error(), http.StatusBadRequest)
+			return
+		}
+		req, ok := request.(CreateGroupRequest)
+		if !ok {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if validationErr := req.Validate(); validationErr != nil {
+			http.Error(w, validationErr.Error(), http.StatusBadRequest)
+			return
+		}
+
+		id, err := s.AddGroup(context.Background(), contact.Group{Name: req.Name, Query: req.Query})
+		if err != nil {
+			http.Error(w, err.Error(), err.StatusCode)
+			return
+		}
+
+		encodeAddContactResponse(w, id)
+	}
+}
+
+func makeGetGroupsEndpoint(s Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		groups, err := s.GetGroups(context.Background())
+		if err != nil {
+			http.Error(w, err.Error(), err.StatusCode)
+			return
+		}
+
+		encodeGetGroupsResponse(w, groups)
+	}
+}
+
+func makeGetGroupEndpoint(s Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		request, decodeErr := decodeGetGroupRequest(r)
+		if decodeErr != nil {
+			http.Error(w, decodeErr.Error(), http.StatusBadRequest)
+			return
+		}
+		req, ok := request.(GetGroupRequest)
+		if !ok {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		g, err := s.GetGroup(context.Background(), req.ID)
+		if err != nil {
+			http.Error(w, err.Error(), err.StatusCode)
+			return
+		}
+
+		encodeGetGroupResponse(w, g)
+	}
+}
+
+func makeUpdateGroupEndpoint(s Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		request, decodeErr := decodeUpdateGroupRequest(r)
+		if decodeErr != nil {
+			http.Error(w, decodeErr.Error(), http.StatusBadRequest)
+			return
+		}
+		req, ok := request.(UpdateGroupRequest)
+		if !ok {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if validationErr := req.Validate(); validationErr != nil {
+			http.Error(w, validationErr.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := s.UpdateGroup(writeContext(r), contact.Group{ID: req.ID, Name: req.Name, Query: req.Query}); err != nil {
+			http.Error(w, err.Error(), err.StatusCode)
+			return
+		}
+		encodeUpdateContactResponse(w)
+	}
+}
+
+func makeDeleteGroupEndpoint(s Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		request, decodeErr := decodeDeleteGroupRequest(r)
+		if decodeErr != nil {
+			http.Error(w, decodeErr.Error(), http.StatusBadRequest)
+			return
+		}
+		req, ok := request.(DeleteGroupRequest)
+		if !ok {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		if err := s.DeleteGroup(writeContext(r), req.ID); err != nil {
+			http.Error(w, err.Error(), err.StatusCode)
+			return
+		}
+		encodeDeleteContactResponse(w)
+	}
+}
+
+func makeGetGroupContactsEndpoint(s Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		request, decodeErr := decodeGetGroupContactsRequest(r)
+		if decodeErr != nil {
+			http.Error(w, decodeErr.Error(), http.StatusBadRequest)
+			return
+		}
+		req, ok := request.(GetGroupContactsRequest)
+		if !ok {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		filters, err := s.GetGroupFilters(context.Background(), req.ID)
+		if err != nil {
+			http.Error(w, err.Error(), err.StatusCode)
+			return
+		}
+		filters.Limit, filters.Offset = req.Limit, req.Offset
+
+		contacts, err := s.GetContacts(context.Background(), filters)
+		if err != nil {
+			http.Error(w, err.Error(), err.StatusCode)
+			return
+		}
+
+		totalContacts, err := s.CountContacts(context.Background(), filters)
+		if err != nil {
+			http.Error(w, err.Error(), err.StatusCode)
+			return
+		}
+
+		response := SearchContactsResponse{
+			Contacts:           contacts,
+			Pagination:         createPagination(req.Offset, req.Limit, totalContacts, r),
+			TotalContactsCount: totalContacts,
+		}
+
+		encodeSearchContactsHandlerResponse(w, response)
+	}
+}
+
+func makeAddGroupMemberEndpoint(s Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		request, decodeErr := decodeGroupMemberRequest(r)
+		if decodeErr != nil {
+			http.Error(w, decodeErr.Error(), http.StatusBadRequest)
+			return
+		}
+		req, ok := request.(GroupMemberRequest)
+		if !ok {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		if err := s.AddGroupMember(writeContext(r), req.GroupID, req.ContactID); err != nil {
+			http.Error(w, err.Error(), err.StatusCode)
+			return
+		}
+		encodeUpdateContactResponse(w)
+	}
+}
+
+func makeRemoveGroupMemberEndpoint(s Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		request, decodeErr := decodeGroupMemberRequest(r)
+		if decodeErr != nil {
+			http.Error(w, decodeErr.Error(), http.StatusBadRequest)
+			return
+		}
+		req, ok := request.(GroupMemberRequest)
+		if !ok {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		if err := s.RemoveGroupMember(writeContext(r), req.GroupID, req.ContactID); err != nil {
+			http.Error(w, err.Error(), err.StatusCode)
+			return
+		}
+		encodeDeleteContactResponse(w)
+	}
+}
+
+func (r CreateGroupRequest) Validate() error {
+	if !contact.IsGroupName(contact.NormalizeGroupName(r.Name)) {
+		return fmt.Errorf("CreateGroupRequest.Validate: name must be 1-64 letters, digits, '-' or '_'")
+	}
+
+	if r.Query != "" {
+		if _, err := contact.ParseGroupQuery(r.Query); err != nil {
+			return fmt.Errorf("CreateGroupRequest.Validate: invalid query: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (r UpdateGroupRequest) Validate() error {
+	if r.ID == "" {
+		return fmt.Errorf("UpdateGroupRequest.Validate: missing id")
+	}
+
+	if !contact.IsGroupName(contact.NormalizeGroupName(r.Name)) {
+		return fmt.Errorf("UpdateGroupRequest.Validate: name must be 1-64 letters, digits, '-' or '_'")
+	}
+
+	if r.Query != "" {
+		if _, err := contact.ParseGroupQuery(r.Query); err != nil {
+			return fmt.Errorf("UpdateGroupRequest.Validate: invalid query: %w", err)
+		}
+	}
 
 	return nil
 }
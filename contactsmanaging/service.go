@@ -3,11 +3,16 @@ package contactsmanaging
 import (
 	"context"
 	"fmt"
+	"slices"
+	"time"
 
 	"github.com/google/uuid"
 
 	"github.com/ShaynaSegal45/phonebook-api/contact"
 	"github.com/ShaynaSegal45/phonebook-api/errors"
+	"github.com/ShaynaSegal45/phonebook-api/phone"
+	"github.com/ShaynaSegal45/phonebook-api/query"
+	"github.com/ShaynaSegal45/phonebook-api/suggest"
 )
 
 const operationName = "contactsmanaging"
@@ -15,19 +20,59 @@ const operationName = "contactsmanaging"
 type ContactsRepo interface {
 	InsertContact(ctx context.Context, c contact.Contact) *errors.Error
 	GetContact(ctx context.Context, id string) (contact.Contact, *errors.Error)
+	GetCurrentContact(ctx context.Context, id string) (contact.Contact, *errors.Error)
 	SearchContacts(ctx context.Context, f contact.Filters) ([]contact.Contact, *errors.Error)
-	CountContacts(ctx context.Context, query string) (int, *errors.Error)
-	UpdateContact(ctx context.Context, c contact.Contact) *errors.Error
-	DeleteContact(ctx context.Context, id string) *errors.Error
+	CountContacts(ctx context.Context, f contact.Filters) (int, *errors.Error)
+	FindContactsByPhone(ctx context.Context, e164 string) ([]contact.Contact, *errors.Error)
+	UpdateContact(ctx context.Context, c contact.Contact) (int64, *errors.Error)
+	DeleteContact(ctx context.Context, id string, version int64) *errors.Error
 	ContactExists(ctx context.Context, firstName, lastName string) (bool, *errors.Error)
+	ListContactSummaries(ctx context.Context) ([]contact.Contact, *errors.Error)
 }
 
+type GroupsRepo interface {
+	InsertGroup(ctx context.Context, g contact.Group) *errors.Error
+	GetGroup(ctx context.Context, id string) (contact.Group, *errors.Error)
+	ListGroups(ctx context.Context) ([]contact.Group, *errors.Error)
+	GroupExists(ctx context.Context, name string) (bool, *errors.Error)
+	UpdateGroup(ctx context.Context, g contact.Group, change contact.Change) *errors.Error
+	DeleteGroup(ctx context.Context, id string, change contact.Change) *errors.Error
+	AddGroupMember(ctx context.Context, groupID, contactID string, change contact.Change) *errors.Error
+	RemoveGroupMember(ctx context.Context, groupID, contactID string, change contact.Change) *errors.Error
+}
+
+const (
+	defaultSuggestLimit = 8
+	maxSuggestLimit     = 50
+)
+
 type service struct {
-	repo ContactsRepo
+	repo          ContactsRepo
+	groups        GroupsRepo
+	defaultRegion string
+	suggestions   *suggest.Index
+	now           func() time.Time
+}
+
+// NewService reads phone numbers without a country code as numbers of defaultRegion.
+// Its suggestions stay empty until RefreshSuggestions loads them.
+func NewService(repo ContactsRepo, groups GroupsRepo, defaultRegion string) Service {
+	return &service{repo: repo, groups: groups, defaultRegion: defaultRegion, suggestions: suggest.NewIndex(), now: time.Now}
+}
+
+type actorKey struct{}
+
+// WithActor names who makes the writes done with ctx, the createdBy and updatedBy of the contacts
+// they change.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
 }
 
-func NewService(repo ContactsRepo) Service {
-	return &service{repo: repo}
+// change stamps a write made now by the actor of ctx. Times are kept to the millisecond, the
+// precision every database stores.
+func (s *service) change(ctx context.Context) contact.Change {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return contact.Change{At: s.now().UTC().Truncate(time.Millisecond), By: actor}
 }
 
 func (s *service) Ping(ctx context.Context) string {
@@ -47,15 +92,25 @@ func (s *service) AddContact(ctx context.Context, c contact.Contact) (string, *e
 
 	id := generateUniqueID()
 	c.ID = id
+	change := s.change(ctx)
+	c.CreatedAt, c.CreatedBy = change.At, change.By
+	c.UpdatedAt, c.UpdatedBy = change.At, change.By
+	c.Normalize()
+	if err := s.normalizePhones(c.Phones); err != nil {
+		return "", errors.CreateError(operationName, "AddContact", err, errors.BadRequestError)
+	}
 
 	if err := s.repo.InsertContact(ctx, c); err != nil {
 		return "", err.ErrorWrapper(operationName, "AddContact")
 	}
+	s.suggestions.Put(c)
 
 	return id, nil
 }
 
 func (s *service) GetContacts(ctx context.Context, filters contact.Filters) ([]contact.Contact, *errors.Error) {
+	filters.PhoneDigits = phone.SearchDigits(filters.FullText, s.defaultRegion)
+	s.normalizeQuery(filters.Query)
 	contacts, err := s.repo.SearchContacts(ctx, filters)
 	if err != nil {
 		return nil, err.ErrorWrapper(operationName, "GetContacts")
@@ -64,8 +119,10 @@ func (s *service) GetContacts(ctx context.Context, filters contact.Filters) ([]c
 	return contacts, nil
 }
 
-func (s *service) CountContacts(ctx context.Context, query string) (int, *errors.Error) {
-	count, err := s.repo.CountContacts(ctx, query)
+func (s *service) CountContacts(ctx context.Context, filters contact.Filters) (int, *errors.Error) {
+	filters.PhoneDigits = phone.SearchDigits(filters.FullText, s.defaultRegion)
+	s.normalizeQuery(filters.Query)
+	count, err := s.repo.CountContacts(ctx, filters)
 	if err != nil {
 		return 0, err.ErrorWrapper(operationName, "CountContacts")
 	}
@@ -73,6 +130,26 @@ func (s *service) CountContacts(ctx context.Context, query string) (int, *errors
 	return count, nil
 }
 
+// normalizeQuery sets the E.164 form of the phone values in a search query, so they match however
+// they were typed. A phone value that can't be normalized is matched as typed.
+func (s *service) normalizeQuery(q *query.Node) {
+	if q == nil {
+		return
+	}
+
+	q.Walk(func(n *query.Node) {
+		switch {
+		case n.Op != query.OpTerm || n.Phrase:
+		case n.Field == query.FieldPhone && n.Prefix:
+			n.Normalized = phone.E164Prefix(n.Value, s.defaultRegion)
+		case n.Field == query.FieldPhone:
+			n.Normalized, _ = phone.Normalize(n.Value, s.defaultRegion)
+		case n.Field == "":
+			n.Normalized = phone.SearchDigits(n.Value, s.defaultRegion)
+		}
+	})
+}
+
 func (s *service) GetContact(ctx context.Context, id string) (contact.Contact, *errors.Error) {
 	c, err := s.repo.GetContact(ctx, id)
 	if err != nil {
@@ -82,18 +159,265 @@ func (s *service) GetContact(ctx context.Context, id string) (contact.Contact, *
 	return c, nil
 }
 
-func (s *service) UpdateContact(ctx context.Context, updatedContact contact.Contact) *errors.Error {
-	if err := s.repo.UpdateContact(ctx, updatedContact); err != nil {
-		return err.ErrorWrapper(operationName, "UpdateContact")
+// GetCurrentContact is GetContact past the repo's cache, for the conditional requests.
+func (s *service) GetCurrentContact(ctx context.Context, id string) (contact.Contact, *errors.Error) {
+	c, err := s.repo.GetCurrentContact(ctx, id)
+	if err != nil {
+		return contact.Contact{}, err.ErrorWrapper(operationName, "GetCurrentContact")
 	}
 
-	return nil
+	return c, nil
+}
+
+// GetContactsByPhone returns the contacts owning a number, however it is formatted.
+func (s *service) GetContactsByPhone(ctx context.Context, number string) ([]contact.Contact, *errors.Error) {
+	e164, normalizeErr := phone.Normalize(number, s.defaultRegion)
+	if normalizeErr != nil {
+		return nil, errors.CreateError(operationName, "GetContactsByPhone", fmt.Errorf("invalid phone number %q", number), errors.BadRequestError)
+	}
+
+	contacts, err := s.repo.FindContactsByPhone(ctx, e164)
+	if err != nil {
+		return nil, err.ErrorWrapper(operationName, "GetContactsByPhone")
+	}
+
+	if len(contacts) == 0 {
+		notFoundErr := fmt.Errorf("no contact with phone %s", e164)
+		return nil, errors.CreateError(operationName, "GetContactsByPhone", notFoundErr, errors.NotFoundError)
+	}
+
+	return contacts, nil
+}
+
+// UpdateContact replaces a stored contact with updatedContact, fields and collections left out are cleared.
+// A set Version is the version the update expects to replace. It returns the contact's new version.
+func (s *service) UpdateContact(ctx context.Context, updatedContact contact.Contact) (int64, *errors.Error) {
+	return s.replaceContact(ctx, updatedContact, "UpdateContact")
 }
 
-func (s *service) DeleteContact(ctx context.Context, id string) *errors.Error {
-	if err := s.repo.DeleteContact(ctx, id); err != nil {
+// PatchContact stores patched, the original contact with a patch applied, unless another write
+// replaced the original meanwhile. The single-value phone and address mirror the primary entries:
+// when the patch changed only the mirror it replaces the primary entry, and when it changed the
+// collection the mirror follows it.
+func (s *service) PatchContact(ctx context.Context, original, patched contact.Contact) (int64, *errors.Error) {
+	if !slices.Equal(patched.Phones, original.Phones) {
+		patched.Phone = ""
+	} else if patched.Phone != original.Phone {
+		patched.Phones = contact.SetPrimaryPhone(original.Phones, patched.Phone)
+	}
+	if !slices.Equal(patched.Addresses, original.Addresses) {
+		patched.Address = ""
+	} else if patched.Address != original.Address {
+		patched.Addresses = contact.SetPrimaryAddress(original.Addresses, patched.Address)
+	}
+	patched.Version = original.Version
+
+	return s.replaceContact(ctx, patched, "PatchContact")
+}
+
+func (s *service) replaceContact(ctx context.Context, c contact.Contact, functionName string) (int64, *errors.Error) {
+	change := s.change(ctx)
+	c.UpdatedAt, c.UpdatedBy = change.At, change.By
+	c.Normalize()
+	if err := s.normalizePhones(c.Phones); err != nil {
+		return 0, errors.CreateError(operationName, functionName, err, errors.BadRequestError)
+	}
+
+	version, err := s.repo.UpdateContact(ctx, c)
+	if err != nil {
+		return 0, err.ErrorWrapper(operationName, functionName)
+	}
+	s.suggestions.Put(c)
+
+	return version, nil
+}
+
+// DeleteContact deletes a contact, only at the given version when it is set.
+func (s *service) DeleteContact(ctx context.Context, id string, version int64) *errors.Error {
+	if err := s.repo.DeleteContact(ctx, id, version); err != nil {
 		return err.ErrorWrapper(operationName, "DeleteContact")
 	}
+	s.suggestions.Remove(id)
+
+	return nil
+}
+
+// SuggestContacts answers a search box from memory, limit defaults to 8 and is capped at 50.
+func (s *service) SuggestContacts(ctx context.Context, q string, limit int) []suggest.Suggestion {
+	if limit <= 0 {
+		limit = defaultSuggestLimit
+	}
+
+	return s.suggestions.Search(q, min(limit, maxSuggestLimit))
+}
+
+// RefreshSuggestions reloads the suggestions from the repo. Writes through this service update them
+// as they happen, a refresh picks up the writes of the other replicas without losing those made
+// while it loads.
+func (s *service) RefreshSuggestions(ctx context.Context) *errors.Error {
+	var err *errors.Error
+	s.suggestions.Refresh(func() ([]contact.Contact, error) {
+		var contacts []contact.Contact
+		contacts, err = s.repo.ListContactSummaries(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return contacts, nil
+	})
+	if err != nil {
+		return err.ErrorWrapper(operationName, "RefreshSuggestions")
+	}
+
+	return nil
+}
+
+func (s *service) AddGroup(ctx context.Context, g contact.Group) (string, *errors.Error) {
+	g.Name = contact.NormalizeGroupName(g.Name)
+	if err := s.checkGroupNameFree(ctx, g.Name, "AddGroup"); err != nil {
+		return "", err
+	}
+
+	g.ID = generateUniqueID()
+	if err := s.groups.InsertGroup(ctx, g); err != nil {
+		return "", err.ErrorWrapper(operationName, "AddGroup")
+	}
+
+	return g.ID, nil
+}
+
+func (s *service) GetGroups(ctx context.Context) ([]contact.Group, *errors.Error) {
+	groups, err := s.groups.ListGroups(ctx)
+	if err != nil {
+		return nil, err.ErrorWrapper(operationName, "GetGroups")
+	}
+
+	return groups, nil
+}
+
+func (s *service) GetGroup(ctx context.Context, id string) (contact.Group, *errors.Error) {
+	g, err := s.groups.GetGroup(ctx, id)
+	if err != nil {
+		return contact.Group{}, err.ErrorWrapper(operationName, "GetGroup")
+	}
+
+	return g, nil
+}
+
+// UpdateGroup renames a group and replaces the query of a smart group. A static group keeps its members
+// and can't become smart; a smart group sent without a query keeps its own.
+func (s *service) UpdateGroup(ctx context.Context, g contact.Group) *errors.Error {
+	existing, err := s.groups.GetGroup(ctx, g.ID)
+	if err != nil {
+		return err.ErrorWrapper(operationName, "UpdateGroup")
+	}
+
+	if g.IsSmart() && !existing.IsSmart() {
+		conflictErr := fmt.Errorf("group %s has members and can't become a smart group", existing.Name)
+		return errors.CreateError(operationName, "UpdateGroup", conflictErr, errors.ConflictError)
+	}
+	if !g.IsSmart() {
+		g.Query = existing.Query
+	}
+
+	g.Name = contact.NormalizeGroupName(g.Name)
+	if g.Name == existing.Name && g.Query == existing.Query {
+		return nil
+	}
+	if g.Name != existing.Name {
+		if err := s.checkGroupNameFree(ctx, g.Name, "UpdateGroup"); err != nil {
+			return err
+		}
+	}
+
+	if err := s.groups.UpdateGroup(ctx, g, s.change(ctx)); err != nil {
+		return err.ErrorWrapper(operationName, "UpdateGroup")
+	}
+
+	return nil
+}
+
+func (s *service) DeleteGroup(ctx context.Context, id string) *errors.Error {
+	if _, err := s.groups.GetGroup(ctx, id); err != nil {
+		return err.ErrorWrapper(operationName, "DeleteGroup")
+	}
+
+	if err := s.groups.DeleteGroup(ctx, id, s.change(ctx)); err != nil {
+		return err.ErrorWrapper(operationName, "DeleteGroup")
+	}
+
+	return nil
+}
+
+func (s *service) AddGroupMember(ctx context.Context, groupID, contactID string) *errors.Error {
+	g, err := s.groups.GetGroup(ctx, groupID)
+	if err != nil {
+		return err.ErrorWrapper(operationName, "AddGroupMember")
+	}
+	if g.IsSmart() {
+		conflictErr := fmt.Errorf("members of smart group %s are defined by its query", g.Name)
+		return errors.CreateError(operationName, "AddGroupMember", conflictErr, errors.ConflictError)
+	}
+	if _, err := s.repo.GetContact(ctx, contactID); err != nil {
+		return err.ErrorWrapper(operationName, "AddGroupMember")
+	}
+
+	if err := s.groups.AddGroupMember(ctx, groupID, contactID, s.change(ctx)); err != nil {
+		return err.ErrorWrapper(operationName, "AddGroupMember")
+	}
+
+	return nil
+}
+
+func (s *service) RemoveGroupMember(ctx context.Context, groupID, contactID string) *errors.Error {
+	if _, err := s.groups.GetGroup(ctx, groupID); err != nil {
+		return err.ErrorWrapper(operationName, "RemoveGroupMember")
+	}
+
+	if err := s.groups.RemoveGroupMember(ctx, groupID, contactID, s.change(ctx)); err != nil {
+		return err.ErrorWrapper(operationName, "RemoveGroupMember")
+	}
+
+	return nil
+}
+
+// GetGroupFilters returns the search listing a group's contacts, so they go through the same path as GET /contacts.
+func (s *service) GetGroupFilters(ctx context.Context, id string) (contact.Filters, *errors.Error) {
+	g, err := s.groups.GetGroup(ctx, id)
+	if err != nil {
+		return contact.Filters{}, err.ErrorWrapper(operationName, "GetGroupFilters")
+	}
+
+	filters, parseErr := g.Filters()
+	if parseErr != nil {
+		return contact.Filters{}, errors.CreateError(operationName, "GetGroupFilters", parseErr, errors.InternalError)
+	}
+
+	return filters, nil
+}
+
+func (s *service) checkGroupNameFree(ctx context.Context, name, functionName string) *errors.Error {
+	exists, err := s.groups.GroupExists(ctx, name)
+	if err != nil {
+		return err.ErrorWrapper(operationName, functionName)
+	}
+
+	if exists {
+		conflictErr := fmt.Errorf("group with name %s already exists", name)
+		return errors.CreateError(operationName, functionName, conflictErr, errors.ConflictError)
+	}
+
+	return nil
+}
+
+// normalizePhones validates every number and stores its E.164 form next to the display form.
+func (s *service) normalizePhones(phones []contact.Phone) error {
+	for i := range phones {
+		e164, err := phone.Normalize(phones[i].Number, s.defaultRegion)
+		if err != nil {
+			return fmt.Errorf("invalid phone number %q", phones[i].Number)
+		}
+		phones[i].E164 = e164
+	}
 
 	return nil
 }
@@ -4,12 +4,16 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 
 	"github.com/ShaynaSegal45/phonebook-api/contact"
 	"github.com/ShaynaSegal45/phonebook-api/errors"
+	"github.com/ShaynaSegal45/phonebook-api/query"
+	"github.com/ShaynaSegal45/phonebook-api/suggest"
 )
 
 type MockContactsRepo struct {
@@ -33,6 +37,11 @@ func (m *MockContactsRepo) GetContact(ctx context.Context, id string) (contact.C
 	return c, nil
 }
 
+func (m *MockContactsRepo) GetCurrentContact(ctx context.Context, id string) (contact.Contact, *errors.Error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(contact.Contact), args.Get(1).(*errors.Error)
+}
+
 func (m *MockContactsRepo) ContactExists(ctx context.Context, firstName, lastName string) (bool, *errors.Error) {
 	args := m.Called(ctx, firstName, lastName)
 	var err *errors.Error
@@ -43,33 +52,96 @@ func (m *MockContactsRepo) ContactExists(ctx context.Context, firstName, lastNam
 }
 
 func (m *MockContactsRepo) SearchContacts(ctx context.Context, f contact.Filters) ([]contact.Contact, *errors.Error) {
-	args := m.Called(ctx, f.Limit, f.Offset, f.FullText)
+	args := m.Called(ctx, f)
 	return args.Get(0).([]contact.Contact), args.Get(1).(*errors.Error)
 }
 
-func (m *MockContactsRepo) CountContacts(ctx context.Context, query string) (int, *errors.Error) {
-	args := m.Called(ctx, query)
+func (m *MockContactsRepo) CountContacts(ctx context.Context, f contact.Filters) (int, *errors.Error) {
+	args := m.Called(ctx, f)
 	return args.Int(0), args.Get(1).(*errors.Error)
 }
 
-func (m *MockContactsRepo) UpdateContact(ctx context.Context, c contact.Contact) *errors.Error {
+func (m *MockContactsRepo) FindContactsByPhone(ctx context.Context, e164 string) ([]contact.Contact, *errors.Error) {
+	args := m.Called(ctx, e164)
+	return args.Get(0).([]contact.Contact), args.Get(1).(*errors.Error)
+}
+
+func (m *MockContactsRepo) UpdateContact(ctx context.Context, c contact.Contact) (int64, *errors.Error) {
 	args := m.Called(ctx, c)
-	return args.Get(0).(*errors.Error)
+	return args.Get(0).(int64), args.Get(1).(*errors.Error)
 }
 
-func (m *MockContactsRepo) DeleteContact(ctx context.Context, id string) *errors.Error {
-	args := m.Called(ctx, id)
+func (m *MockContactsRepo) DeleteContact(ctx context.Context, id string, version int64) *errors.Error {
+	args := m.Called(ctx, id, version)
 	return args.Get(0).(*errors.Error)
 }
 
+func (m *MockContactsRepo) ListContactSummaries(ctx context.Context) ([]contact.Contact, *errors.Error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]contact.Contact), args.Get(1).(*errors.Error)
+}
+
 func (m *MockContactsRepo) InsertContact(ctx context.Context, c contact.Contact) *errors.Error {
 	args := m.Called(ctx, c)
 	return args.Get(0).(*errors.Error)
 }
 
+type MockGroupsRepo struct {
+	mock.Mock
+}
+
+func (m *MockGroupsRepo) InsertGroup(ctx context.Context, g contact.Group) *errors.Error {
+	args := m.Called(ctx, g)
+	return args.Get(0).(*errors.Error)
+}
+
+func (m *MockGroupsRepo) GetGroup(ctx context.Context, id string) (contact.Group, *errors.Error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(contact.Group), args.Get(1).(*errors.Error)
+}
+
+func (m *MockGroupsRepo) ListGroups(ctx context.Context) ([]contact.Group, *errors.Error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]contact.Group), args.Get(1).(*errors.Error)
+}
+
+func (m *MockGroupsRepo) GroupExists(ctx context.Context, name string) (bool, *errors.Error) {
+	args := m.Called(ctx, name)
+	return args.Bool(0), args.Get(1).(*errors.Error)
+}
+
+func (m *MockGroupsRepo) UpdateGroup(ctx context.Context, g contact.Group, change contact.Change) *errors.Error {
+	args := m.Called(ctx, g, change)
+	return args.Get(0).(*errors.Error)
+}
+
+func (m *MockGroupsRepo) DeleteGroup(ctx context.Context, id string, change contact.Change) *errors.Error {
+	args := m.Called(ctx, id, change)
+	return args.Get(0).(*errors.Error)
+}
+
+func (m *MockGroupsRepo) AddGroupMember(ctx context.Context, groupID, contactID string, change contact.Change) *errors.Error {
+	args := m.Called(ctx, groupID, contactID, change)
+	return args.Get(0).(*errors.Error)
+}
+
+func (m *MockGroupsRepo) RemoveGroupMember(ctx context.Context, groupID, contactID string, change contact.Change) *errors.Error {
+	args := m.Called(ctx, groupID, contactID, change)
+	return args.Get(0).(*errors.Error)
+}
+
+// stoppedClock is the time of every write of a service made by newStoppedService.
+var stoppedClock = time.Date(2026, time.October, 17, 2, 0, 0, 0, time.UTC)
+
+func newStoppedService(repo ContactsRepo, groups GroupsRepo) *service {
+	s := NewService(repo, groups, "IL").(*service)
+	s.now = func() time.Time { return stoppedClock }
+	return s
+}
+
 func TestGetContact_NotFound(t *testing.T) {
 	repo := new(MockContactsRepo)
-	service := NewService(repo)
+	service := NewService(repo, new(MockGroupsRepo), "IL")
 
 	repo.On("GetContact", mock.Anything, "123").Return(contact.Contact{}, errors.CreateError("contactsmanaging", "GetContact", fmt.Errorf("not found"), errors.NotFoundError))
 
@@ -82,7 +154,7 @@ func TestGetContact_NotFound(t *testing.T) {
 
 func TestAddContact_Conflict(t *testing.T) {
 	repo := new(MockContactsRepo)
-	service := NewService(repo)
+	service := NewService(repo, new(MockGroupsRepo), "IL")
 
 	contactToAdd := contact.Contact{FirstName: "John", LastName: "Doe"}
 	repo.On("ContactExists", mock.Anything, contactToAdd.FirstName, contactToAdd.LastName).Return(true, nil)
@@ -94,10 +166,270 @@ func TestAddContact_Conflict(t *testing.T) {
 	repo.AssertExpectations(t)
 }
 
+func TestUpdateContact_ReplacesWholeContact(t *testing.T) {
+	repo := new(MockContactsRepo)
+	service := newStoppedService(repo, new(MockGroupsRepo))
+
+	repo.On("UpdateContact", mock.Anything, contact.Contact{ID: "123", FirstName: "John", Version: 4, UpdatedAt: stoppedClock}).Return(int64(5), (*errors.Error)(nil))
+	repo.On("UpdateContact", mock.Anything, contact.Contact{ID: "404", FirstName: "John", UpdatedAt: stoppedClock}).
+		Return(int64(0), errors.CreateError(operationName, "UpdateContact", fmt.Errorf("not found"), errors.NotFoundError))
+
+	version, err := service.UpdateContact(context.Background(), contact.Contact{ID: "123", FirstName: "John", Version: 4})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5), version)
+
+	_, err = service.UpdateContact(context.Background(), contact.Contact{ID: "404", FirstName: "John"})
+	require.NotNil(t, err)
+	assert.Equal(t, errors.NotFoundError, err.StatusCode)
+	repo.AssertExpectations(t)
+}
+
+func TestWrites_StampTimeAndActor(t *testing.T) {
+	repo := new(MockContactsRepo)
+	groups := new(MockGroupsRepo)
+	service := newStoppedService(repo, groups)
+	ctx := WithActor(context.Background(), "sync-job")
+	service.now = func() time.Time { return stoppedClock.In(time.FixedZone("IDT", 3*60*60)).Add(1234 * time.Microsecond) }
+	at := stoppedClock.Add(time.Millisecond)
+
+	repo.On("ContactExists", mock.Anything, "John", "Doe").Return(false, (*errors.Error)(nil))
+	repo.On("InsertContact", mock.Anything, mock.MatchedBy(func(c contact.Contact) bool {
+		return c.CreatedAt == at && c.UpdatedAt == at && c.CreatedBy == "sync-job" && c.UpdatedBy == "sync-job"
+	})).Return((*errors.Error)(nil))
+	_, err := service.AddContact(ctx, contact.Contact{FirstName: "John", LastName: "Doe"})
+	require.Nil(t, err)
+
+	repo.On("UpdateContact", mock.Anything, contact.Contact{ID: "123", FirstName: "John", UpdatedAt: at, UpdatedBy: "sync-job"}).
+		Return(int64(2), (*errors.Error)(nil))
+	_, err = service.UpdateContact(ctx, contact.Contact{ID: "123", FirstName: "John"})
+	require.Nil(t, err)
+
+	groups.On("GetGroup", mock.Anything, "g1").Return(contact.Group{ID: "g1", Name: "family"}, (*errors.Error)(nil))
+	groups.On("RemoveGroupMember", mock.Anything, "g1", "123", contact.Change{At: at, By: "sync-job"}).Return((*errors.Error)(nil))
+	require.Nil(t, service.RemoveGroupMember(ctx, "g1", "123"))
+
+	repo.AssertExpectations(t)
+	groups.AssertExpectations(t)
+}
+
+func TestPatchContact_PhoneReplacesOnlyPrimary(t *testing.T) {
+	repo := new(MockContactsRepo)
+	service := NewService(repo, new(MockGroupsRepo), "IL")
+
+	existing := contact.Contact{
+		ID:    "123",
+		Phone: "0501234567",
+		Phones: []contact.Phone{
+			{Label: contact.LabelWork, Number: "048123456"},
+			{Label: contact.LabelMobile, Number: "0501234567", Primary: true},
+		},
+	}
+	expectedPhones := []contact.Phone{
+		{Label: contact.LabelWork, Number: "048123456", E164: "+97248123456"},
+		{Label: contact.LabelMobile, Number: "0529999999", E164: "+972529999999", Primary: true},
+	}
+	repo.On("UpdateContact", mock.Anything, mock.MatchedBy(func(c contact.Contact) bool {
+		return c.Phone == "0529999999" && assert.ObjectsAreEqual(expectedPhones, c.Phones) && c.Addresses == nil
+	})).Return(int64(1), (*errors.Error)(nil)).Once()
+
+	patched := existing
+	patched.Phone = "0529999999"
+	_, err := service.PatchContact(context.Background(), existing, patched)
+	assert.Nil(t, err)
+
+	t.Run("clearing the phone removes the primary entry", func(t *testing.T) {
+		repo.On("UpdateContact", mock.Anything, mock.MatchedBy(func(c contact.Contact) bool {
+			return c.Phone == "048123456" && len(c.Phones) == 1 && c.Phones[0].Primary
+		})).Return(int64(1), (*errors.Error)(nil)).Once()
+
+		patched := existing
+		patched.Phone = ""
+		_, err := service.PatchContact(context.Background(), existing, patched)
+		assert.Nil(t, err)
+	})
+
+	t.Run("the phone follows a patched collection", func(t *testing.T) {
+		repo.On("UpdateContact", mock.Anything, mock.MatchedBy(func(c contact.Contact) bool {
+			return c.Phone == "048123456" && len(c.Phones) == 1
+		})).Return(int64(1), (*errors.Error)(nil)).Once()
+
+		patched := existing
+		patched.Phones = existing.Phones[:1]
+		_, err := service.PatchContact(context.Background(), existing, patched)
+		assert.Nil(t, err)
+	})
+	repo.AssertExpectations(t)
+}
+
+func TestAddContact_InvalidPhone(t *testing.T) {
+	repo := new(MockContactsRepo)
+	service := NewService(repo, new(MockGroupsRepo), "IL")
+
+	contactToAdd := contact.Contact{FirstName: "John", LastName: "Doe", Phone: "050-12"}
+	repo.On("ContactExists", mock.Anything, contactToAdd.FirstName, contactToAdd.LastName).Return(false, nil)
+
+	id, err := service.AddContact(context.Background(), contactToAdd)
+
+	require.NotNil(t, err)
+	assert.Equal(t, errors.BadRequestError, err.StatusCode)
+	assert.Empty(t, id)
+	repo.AssertNotCalled(t, "InsertContact", mock.Anything, mock.Anything)
+}
+
+func TestGetContacts_MatchesAnyPhoneFormatting(t *testing.T) {
+	repo := new(MockContactsRepo)
+	service := NewService(repo, new(MockGroupsRepo), "IL")
+
+	repo.On("SearchContacts", mock.Anything, contact.Filters{FullText: "050-123", PhoneDigits: "50123", Limit: 10}).Return([]contact.Contact{}, (*errors.Error)(nil))
+
+	_, err := service.GetContacts(context.Background(), contact.Filters{FullText: "050-123", Limit: 10})
+
+	assert.Nil(t, err)
+	repo.AssertExpectations(t)
+}
+
+func TestGetContacts_NormalizesQueryPhones(t *testing.T) {
+	repo := new(MockContactsRepo)
+	service := NewService(repo, new(MockGroupsRepo), "IL")
+
+	q, parseErr := query.Parse(`phone:050* OR phone:050-1234567 OR 0501234 OR "050" OR email:050`)
+	require.NoError(t, parseErr)
+	repo.On("SearchContacts", mock.Anything, mock.Anything).Return([]contact.Contact{}, (*errors.Error)(nil))
+
+	_, err := service.GetContacts(context.Background(), contact.Filters{Query: q, Limit: 10})
+	assert.Nil(t, err)
+
+	var normalized []string
+	q.Walk(func(n *query.Node) {
+		if n.Op == query.OpTerm {
+			normalized = append(normalized, n.Normalized)
+		}
+	})
+	assert.Equal(t, []string{"+97250", "+972501234567", "501234", "", ""}, normalized)
+	repo.AssertExpectations(t)
+}
+
+func TestSuggestContacts_FollowsWrites(t *testing.T) {
+	repo := new(MockContactsRepo)
+	service := NewService(repo, new(MockGroupsRepo), "IL")
+
+	repo.On("ListContactSummaries", mock.Anything).Return([]contact.Contact{{ID: "1", FirstName: "Shayna", LastName: "Segal"}}, (*errors.Error)(nil))
+	repo.On("ContactExists", mock.Anything, "Shai", "Levi").Return(false, nil)
+	repo.On("InsertContact", mock.Anything, mock.Anything).Return((*errors.Error)(nil))
+	repo.On("UpdateContact", mock.Anything, mock.Anything).Return(int64(2), (*errors.Error)(nil))
+	repo.On("DeleteContact", mock.Anything, "1", int64(0)).Return((*errors.Error)(nil))
+
+	require.Nil(t, service.RefreshSuggestions(context.Background()))
+	assert.Equal(t, []suggest.Suggestion{{ID: "1", DisplayName: "Shayna Segal"}}, service.SuggestContacts(context.Background(), "sha", 0))
+
+	id, err := service.AddContact(context.Background(), contact.Contact{FirstName: "Shai", LastName: "Levi", Phone: "050-1234567"})
+	require.Nil(t, err)
+	assert.Equal(t, []suggest.Suggestion{{ID: id, DisplayName: "Shai Levi", PrimaryPhone: "050-1234567"}}, service.SuggestContacts(context.Background(), "+97250", 0))
+
+	_, err = service.UpdateContact(context.Background(), contact.Contact{ID: id, FirstName: "Shai", LastName: "Cohen"})
+	require.Nil(t, err)
+	assert.Len(t, service.SuggestContacts(context.Background(), "cohen", 0), 1)
+	assert.Empty(t, service.SuggestContacts(context.Background(), "levi", 0))
+
+	require.Nil(t, service.DeleteContact(context.Background(), "1", 0))
+	assert.Len(t, service.SuggestContacts(context.Background(), "sha", 1), 1)
+	assert.Empty(t, service.SuggestContacts(context.Background(), "segal", 0))
+	repo.AssertExpectations(t)
+}
+
+func TestGetContactsByPhone(t *testing.T) {
+	repo := new(MockContactsRepo)
+	service := NewService(repo, new(MockGroupsRepo), "IL")
+
+	owner := contact.Contact{ID: "123", FirstName: "John", LastName: "Doe"}
+	repo.On("FindContactsByPhone", mock.Anything, "+972501234567").Return([]contact.Contact{owner}, (*errors.Error)(nil))
+	repo.On("FindContactsByPhone", mock.Anything, "+972529999999").Return([]contact.Contact{}, (*errors.Error)(nil))
+
+	contacts, err := service.GetContactsByPhone(context.Background(), "050-123-4567")
+	assert.Nil(t, err)
+	assert.Equal(t, []contact.Contact{owner}, contacts)
+
+	_, err = service.GetContactsByPhone(context.Background(), "+972 52 999 9999")
+	require.NotNil(t, err)
+	assert.Equal(t, errors.NotFoundError, err.StatusCode)
+
+	_, err = service.GetContactsByPhone(context.Background(), "not a number")
+	require.NotNil(t, err)
+	assert.Equal(t, errors.BadRequestError, err.StatusCode)
+
+	repo.AssertExpectations(t)
+}
+
+func TestAddGroup_NormalizesNameAndRejectsDuplicates(t *testing.T) {
+	groups := new(MockGroupsRepo)
+	service := NewService(new(MockContactsRepo), groups, "IL")
+
+	groups.On("GroupExists", mock.Anything, "family").Return(false, (*errors.Error)(nil)).Once()
+	groups.On("InsertGroup", mock.Anything, mock.MatchedBy(func(g contact.Group) bool {
+		return g.Name == "family" && g.ID != ""
+	})).Return((*errors.Error)(nil))
+
+	id, err := service.AddGroup(context.Background(), contact.Group{Name: " Family "})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, id)
+
+	groups.On("GroupExists", mock.Anything, "family").Return(true, (*errors.Error)(nil))
+
+	_, err = service.AddGroup(context.Background(), contact.Group{Name: "FAMILY"})
+	require.NotNil(t, err)
+	assert.Equal(t, errors.ConflictError, err.StatusCode)
+	groups.AssertExpectations(t)
+}
+
+func TestAddGroupMember_ContactNotFound(t *testing.T) {
+	repo := new(MockContactsRepo)
+	groups := new(MockGroupsRepo)
+	service := NewService(repo, groups, "IL")
+
+	groups.On("GetGroup", mock.Anything, "g1").Return(contact.Group{ID: "g1", Name: "family"}, (*errors.Error)(nil))
+	repo.On("GetContact", mock.Anything, "123").Return(contact.Contact{}, errors.CreateError("contactsmanaging", "GetContact", fmt.Errorf("not found"), errors.NotFoundError))
+
+	err := service.AddGroupMember(context.Background(), "g1", "123")
+
+	require.NotNil(t, err)
+	assert.Equal(t, errors.NotFoundError, err.StatusCode)
+	groups.AssertNotCalled(t, "AddGroupMember", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestSmartGroups(t *testing.T) {
+	groups := new(MockGroupsRepo)
+	service := NewService(new(MockContactsRepo), groups, "IL")
+
+	smart := contact.Group{ID: "g1", Name: "haifa-customers", Query: "city:Haifa tag:customer"}
+	groups.On("GetGroup", mock.Anything, "g1").Return(smart, (*errors.Error)(nil))
+	groups.On("GetGroup", mock.Anything, "g2").Return(contact.Group{ID: "g2", Name: "family"}, (*errors.Error)(nil))
+
+	filters, err := service.GetGroupFilters(context.Background(), "g1")
+	require.Nil(t, err)
+	want, parseErr := query.Parse("city:Haifa tag:customer")
+	require.NoError(t, parseErr)
+	assert.Equal(t, contact.Filters{Query: want}, filters)
+
+	filters, err = service.GetGroupFilters(context.Background(), "g2")
+	require.Nil(t, err)
+	assert.Equal(t, contact.Filters{AllTags: []string{"family"}}, filters)
+
+	err = service.AddGroupMember(context.Background(), "g1", "123")
+	require.NotNil(t, err)
+	assert.Equal(t, errors.ConflictError, err.StatusCode)
+
+	err = service.UpdateGroup(context.Background(), contact.Group{ID: "g2", Name: "family", Query: "city:Haifa"})
+	require.NotNil(t, err)
+	assert.Equal(t, errors.ConflictError, err.StatusCode)
+
+	groups.AssertNotCalled(t, "AddGroupMember", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	groups.AssertNotCalled(t, "UpdateGroup", mock.Anything, mock.Anything, mock.Anything)
+}
+
 //add more tests
 // func TestAddContact_Success(t *testing.T) {
 // 	repo := new(MockContactsRepo)
-// 	service := NewService(repo)
+// 	service := NewService(repo, new(MockGroupsRepo), "IL")
 
 // 	contactToAdd := contact.Contact{FirstName: "John", LastName: "Doe"}
 // 	repo.On("ContactExists", mock.Anything, contactToAdd.FirstName, contactToAdd.LastName).Return(false, nil)
@@ -112,7 +444,7 @@ func TestAddContact_Conflict(t *testing.T) {
 
 // func TestGetContact_Success(t *testing.T) {
 // 	repo := new(MockContactsRepo)
-// 	service := NewService(repo)
+// 	service := NewService(repo, new(MockGroupsRepo), "IL")
 
 // 	expectedContact := contact.Contact{ID: "123", FirstName: "John", LastName: "Doe"}
 // 	repo.On("GetContact", mock.Anything, "123").Return(expectedContact, nil)
@@ -1,21 +1,53 @@
 package contactsmanaging
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"net/netip"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
+	"github.com/ShaynaSegal45/phonebook-api/collation"
 	"github.com/ShaynaSegal45/phonebook-api/contact"
+	"github.com/ShaynaSegal45/phonebook-api/cursor"
 	"github.com/ShaynaSegal45/phonebook-api/errors"
+	"github.com/ShaynaSegal45/phonebook-api/patch"
+	"github.com/ShaynaSegal45/phonebook-api/query"
+	"github.com/ShaynaSegal45/phonebook-api/suggest"
 )
 
 const (
-	idParam       = "id"
-	fullTextParam = "fullText"
+	idParam        = "id"
+	numberParam    = "number"
+	contactIDParam = "contactId"
+	fullTextParam  = "fullText"
+
+	cityParam       = "city"
+	regionParam     = "region"
+	postalCodeParam = "postalCode"
+	countryParam    = "country"
+	anyTagsParam    = "tags"
+	allTagsParam    = "allTags"
+	queryParam      = "q"
+	highlightParam  = "highlight"
+	matchParam      = "match"
+	localeParam     = "locale"
+	sortParam       = "sort"
+	fieldsParam     = "fields"
+	afterParam      = "after"
+	beforeParam     = "before"
+
+	updatedSinceParam  = "updatedSince"
+	createdBeforeParam = "createdBefore"
 
 	offsetParam = "offset"
 	countParam  = "count"
@@ -29,66 +61,233 @@ type Service interface {
 	Ping(ctx context.Context) string
 	AddContact(ctx context.Context, c contact.Contact) (string, *errors.Error)
 	GetContacts(ctx context.Context, filters contact.Filters) ([]contact.Contact, *errors.Error)
-	CountContacts(ctx context.Context, query string) (int, *errors.Error)
+	CountContacts(ctx context.Context, filters contact.Filters) (int, *errors.Error)
 	GetContact(ctx context.Context, id string) (contact.Contact, *errors.Error)
-	UpdateContact(ctx context.Context, c contact.Contact) *errors.Error
-	DeleteContact(ctx context.Context, id string) *errors.Error
+	GetCurrentContact(ctx context.Context, id string) (contact.Contact, *errors.Error)
+	GetContactsByPhone(ctx context.Context, number string) ([]contact.Contact, *errors.Error)
+	UpdateContact(ctx context.Context, c contact.Contact) (int64, *errors.Error)
+	PatchContact(ctx context.Context, original, patched contact.Contact) (int64, *errors.Error)
+	DeleteContact(ctx context.Context, id string, version int64) *errors.Error
+	SuggestContacts(ctx context.Context, q string, limit int) []suggest.Suggestion
+	RefreshSuggestions(ctx context.Context) *errors.Error
+	AddGroup(ctx context.Context, g contact.Group) (string, *errors.Error)
+	GetGroups(ctx context.Context) ([]contact.Group, *errors.Error)
+	GetGroup(ctx context.Context, id string) (contact.Group, *errors.Error)
+	GetGroupFilters(ctx context.Context, id string) (contact.Filters, *errors.Error)
+	UpdateGroup(ctx context.Context, g contact.Group) *errors.Error
+	DeleteGroup(ctx context.Context, id string) *errors.Error
+	AddGroupMember(ctx context.Context, groupID, contactID string) *errors.Error
+	RemoveGroupMember(ctx context.Context, groupID, contactID string) *errors.Error
 }
 
-func NewHTTPHandler(s Service) http.Handler {
+// NewHTTPHandler routes the API. Cursors signs the after/before tokens of the contact search, and
+// only the requests from trustedProxies name the actor of their writes with X-Forwarded-User.
+func NewHTTPHandler(s Service, cursors *cursor.Codec, trustedProxies []netip.Prefix) http.Handler {
 	router := chi.NewRouter()
-	endpoint := MakeEndpoints(s)
+	router.Use(forwardedUserFrom(trustedProxies))
+	endpoint := MakeEndpoints(s, cursors)
 
 	router.Post("/contact", endpoint.AddContactEndpoint)
 	router.Get("/contacts", endpoint.GetContactsEndpoint)
+	router.Get("/contacts/by-phone/{number}", endpoint.GetContactsByPhoneEndpoint)
+	router.Get("/contacts/suggest", endpoint.SuggestContactsEndpoint)
 	router.Get("/contact/{id}", endpoint.GetContactEndpoint)
 	router.Put("/contact/{id}", endpoint.UpdateContactEndpoint)
+	router.Patch("/contact/{id}", endpoint.PatchContactEndpoint)
 	router.Delete("/contact/{id}", endpoint.DeleteContactEndpoint)
+	router.Post("/groups", endpoint.AddGroupEndpoint)
+	router.Get("/groups", endpoint.GetGroupsEndpoint)
+	router.Get("/groups/{id}", endpoint.GetGroupEndpoint)
+	router.Put("/groups/{id}", endpoint.UpdateGroupEndpoint)
+	router.Delete("/groups/{id}", endpoint.DeleteGroupEndpoint)
+	router.Get("/groups/{id}/contacts", endpoint.GetGroupContactsEndpoint)
+	router.Put("/groups/{id}/members/{contactId}", endpoint.AddGroupMemberEndpoint)
+	router.Delete("/groups/{id}/members/{contactId}", endpoint.RemoveGroupMemberEndpoint)
 	router.Get("/ping", pingHandler)
 
 	return router
 }
 
+// forwardedUserFrom drops the X-Forwarded-User header of the requests that didn't come from one of
+// the trusted proxies, so a client reaching the service directly can't name the actor of a write.
+func forwardedUserFrom(trusted []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !fromTrustedProxy(r, trusted) {
+				r.Header.Del("X-Forwarded-User")
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func fromTrustedProxy(r *http.Request, trusted []netip.Prefix) bool {
+	remote, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+
+	for _, prefix := range trusted {
+		if prefix.Contains(remote.Addr().Unmap()) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseTrustedProxies reads a comma-separated list of the addresses or CIDR ranges of the proxies
+// trusted to set X-Forwarded-User, e.g. "127.0.0.1, 10.0.0.0/8".
+func ParseTrustedProxies(list string) ([]netip.Prefix, error) {
+	var trusted []netip.Prefix
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+			}
+			trusted = append(trusted, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+		trusted = append(trusted, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+	}
+
+	return trusted, nil
+}
+
 func pingHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("pong"))
 }
 
 type CreateContactRequest struct {
-	FirstName string `json:"firstName"`
-	LastName  string `json:"lastName"`
-	Phone     string `json:"phone"`
-	Address   string `json:"address"`
+	FirstName string            `json:"firstName"`
+	LastName  string            `json:"lastName"`
+	Phone     string            `json:"phone"`
+	Address   string            `json:"address"`
+	Phones    []contact.Phone   `json:"phones"`
+	Emails    []contact.Email   `json:"emails"`
+	Addresses []contact.Address `json:"addresses"`
 }
 
+// UpdateContactRequest replaces the whole contact, an omitted field or collection is cleared.
+// It is also the document a PatchContactRequest patches. IfMatch holds the If-Match header.
 type UpdateContactRequest struct {
-	ID        string `json:"id"`
-	FirstName string `json:"firstName"`
-	LastName  string `json:"lastName"`
-	Phone     string `json:"phone"`
-	Address   string `json:"address"`
+	IfMatch   string            `json:"-"`
+	ID        string            `json:"id"`
+	FirstName string            `json:"firstName"`
+	LastName  string            `json:"lastName"`
+	Phone     string            `json:"phone"`
+	Address   string            `json:"address"`
+	Phones    []contact.Phone   `json:"phones"`
+	Emails    []contact.Email   `json:"emails"`
+	Addresses []contact.Address `json:"addresses"`
+}
+
+// PatchContactRequest changes some fields of a contact with a merge patch or a JSON Patch.
+type PatchContactRequest struct {
+	ID      string
+	Patch   patch.Patch
+	IfMatch string
 }
 
 type GetContactRequest struct {
-	ID string `json:"id"`
+	ID          string `json:"id"`
+	IfNoneMatch string `json:"-"`
+}
+
+type GetContactsByPhoneRequest struct {
+	Number string
+}
+
+type SuggestContactsRequest struct {
+	Query string
+	Limit int
 }
 
 type DeleteContactRequest struct {
-	ID string `json:"id"`
+	ID      string `json:"id"`
+	IfMatch string `json:"-"`
 }
 
+// SearchContactsRequest pages by Offset, or through the After or Before token when Cursor is set.
+// An empty After starts from the first contact, an empty Before, with Backward set, from the last.
+// UpdatedSince and CreatedBefore are zero when not filtered on.
 type SearchContactsRequest struct {
-	Text   string
+	Text          string
+	Query         *query.Node
+	City          string
+	Region        string
+	PostalCode    string
+	Country       string
+	AnyTags       []string
+	AllTags       []string
+	Highlight     bool
+	Match         string
+	Locale        string
+	Sort          []contact.SortField
+	Fields        []string
+	Cursor        bool
+	Backward      bool
+	After         string
+	Before        string
+	UpdatedSince  time.Time
+	CreatedBefore time.Time
+	Offset        int
+	Limit         int
+}
+
+type GetContactResponse struct {
+	ID        string            `json:"id"`
+	FirstName string            `json:"firstName"`
+	LastName  string            `json:"lastName"`
+	Phone     string            `json:"phone"`
+	Address   string            `json:"address"`
+	Phones    []contact.Phone   `json:"phones"`
+	Emails    []contact.Email   `json:"emails"`
+	Addresses []contact.Address `json:"addresses"`
+	Groups    []string          `json:"groups"`
+	CreatedAt time.Time         `json:"createdAt"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+	CreatedBy string            `json:"createdBy"`
+	UpdatedBy string            `json:"updatedBy"`
+}
+
+type CreateGroupRequest struct {
+	Name  string `json:"name"`
+	Query string `json:"query"`
+}
+
+type UpdateGroupRequest struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Query string `json:"query"`
+}
+
+type GetGroupRequest struct {
+	ID string `json:"id"`
+}
+
+type GetGroupContactsRequest struct {
+	ID     string
 	Offset int
 	Limit  int
 }
 
-type GetContactResponse struct {
-	ID        string `json:"id"`
-	FirstName string `json:"firstName"`
-	LastName  string `json:"lastName"`
-	Phone     string `json:"phone"`
-	Address   string `json:"address"`
+type DeleteGroupRequest struct {
+	ID string `json:"id"`
+}
+
+type GroupMemberRequest struct {
+	GroupID   string
+	ContactID string
 }
 
 func decodeAddContactRequest(r *http.Request) (interface{}, error) {
@@ -100,7 +299,8 @@ func decodeAddContactRequest(r *http.Request) (interface{}, error) {
 func decodeGetContactRequest(r *http.Request) (interface{}, error) {
 	id := chi.URLParam(r, idParam)
 	return GetContactRequest{
-		ID: id,
+		ID:          id,
+		IfNoneMatch: r.Header.Get("If-None-Match"),
 	}, nil
 }
 
@@ -108,19 +308,184 @@ func decodeUpdateContactRequest(r *http.Request) (interface{}, error) {
 	id := chi.URLParam(r, idParam)
 	var req UpdateContactRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
+	if err == io.EOF {
+		err = fmt.Errorf("request body is required")
+	}
 	req.ID = id
+	req.IfMatch = r.Header.Get("If-Match")
 	return req, err
 }
 
+// errUnsupportedPatchType answers a PATCH whose Content-Type is neither patch media type.
+var errUnsupportedPatchType = fmt.Errorf("Content-Type must be %s or %s", patch.MergePatchType, patch.JSONPatchType)
+
+func decodePatchContactRequest(r *http.Request) (interface{}, error) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || (mediaType != patch.MergePatchType && mediaType != patch.JSONPatchType) {
+		return nil, errUnsupportedPatchType
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	if len(body) == 0 {
+		return nil, fmt.Errorf("request body is required")
+	}
+	p, err := patch.Decode(mediaType, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return PatchContactRequest{
+		ID:      chi.URLParam(r, idParam),
+		Patch:   p,
+		IfMatch: r.Header.Get("If-Match"),
+	}, nil
+}
+
+// decodePatchedContact reads the document a patch produced. It has to still be a contact: an
+// unknown member or a changed id is rejected rather than ignored.
+func decodePatchedContact(id string, doc []byte) (UpdateContactRequest, error) {
+	decoder := json.NewDecoder(bytes.NewReader(doc))
+	decoder.DisallowUnknownFields()
+
+	var req UpdateContactRequest
+	if err := decoder.Decode(&req); err != nil {
+		return UpdateContactRequest{}, fmt.Errorf("patched contact is invalid: %w", err)
+	}
+	if req.ID != id {
+		return UpdateContactRequest{}, fmt.Errorf("patched contact is invalid: id can't be changed")
+	}
+
+	return req, nil
+}
+
+// decodeGetContactsByPhoneRequest unescapes the number itself: chi matches the raw path when it
+// has escapes, so a '+' sent as %2B would otherwise arrive still escaped.
+func decodeGetContactsByPhoneRequest(r *http.Request) (interface{}, error) {
+	number, err := url.PathUnescape(chi.URLParam(r, numberParam))
+	if err != nil {
+		return nil, fmt.Errorf("invalid phone number: %w", err)
+	}
+	if number == "" {
+		return nil, fmt.Errorf("missing phone number")
+	}
+	return GetContactsByPhoneRequest{
+		Number: number,
+	}, nil
+}
+
+// decodeSuggestContactsRequest leaves a missing or invalid limit to the service's default.
+func decodeSuggestContactsRequest(r *http.Request) (interface{}, error) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get(limitParam))
+	return SuggestContactsRequest{
+		Query: r.URL.Query().Get(queryParam),
+		Limit: limit,
+	}, nil
+}
+
 func decodeDeleteContactRequest(r *http.Request) (interface{}, error) {
 	id := chi.URLParam(r, idParam)
 	return DeleteContactRequest{
-		ID: id,
+		ID:      id,
+		IfMatch: r.Header.Get("If-Match"),
 	}, nil
 }
 
 func decodeSearchContactsRequest(r *http.Request) (interface{}, error) {
-	query := r.URL.Query().Get(fullTextParam)
+	text := r.URL.Query().Get(fullTextParam)
+	limit, offset := decodePage(r)
+
+	q, err := query.Parse(r.URL.Query().Get(queryParam))
+	if err != nil {
+		return nil, fmt.Errorf("decodeSearchContactsRequest: %w", err)
+	}
+
+	highlight, _ := strconv.ParseBool(r.URL.Query().Get(highlightParam))
+
+	match := r.URL.Query().Get(matchParam)
+	if match != "" && match != contact.MatchFuzzy {
+		return nil, fmt.Errorf("decodeSearchContactsRequest: unsupported match %q", match)
+	}
+
+	locale, err := collation.Match(r.URL.Query().Get(localeParam))
+	if err != nil {
+		return nil, fmt.Errorf("decodeSearchContactsRequest: %w", err)
+	}
+
+	sort, err := contact.ParseSort(r.URL.Query().Get(sortParam))
+	if err != nil {
+		return nil, fmt.Errorf("decodeSearchContactsRequest: %w", err)
+	}
+
+	fields, err := contact.ParseFields(r.URL.Query().Get(fieldsParam))
+	if err != nil {
+		return nil, fmt.Errorf("decodeSearchContactsRequest: %w", err)
+	}
+
+	after, before := r.URL.Query().Get(afterParam), r.URL.Query().Get(beforeParam)
+	paged := r.URL.Query().Has(afterParam) || r.URL.Query().Has(beforeParam)
+	switch {
+	case r.URL.Query().Has(afterParam) && r.URL.Query().Has(beforeParam):
+		return nil, fmt.Errorf("decodeSearchContactsRequest: after and before can't be combined")
+	case paged && (len(sort) > 0 || match != ""):
+		return nil, fmt.Errorf("decodeSearchContactsRequest: after and before page in name order, they can't be combined with sort or match")
+	}
+	if paged {
+		offset = 0
+	}
+
+	updatedSince, err := parseTimeParam(r, updatedSinceParam)
+	if err != nil {
+		return nil, fmt.Errorf("decodeSearchContactsRequest: %w", err)
+	}
+	createdBefore, err := parseTimeParam(r, createdBeforeParam)
+	if err != nil {
+		return nil, fmt.Errorf("decodeSearchContactsRequest: %w", err)
+	}
+
+	return SearchContactsRequest{
+		Text:          text,
+		Highlight:     highlight,
+		Match:         match,
+		Locale:        locale,
+		Sort:          sort,
+		Fields:        fields,
+		Cursor:        paged,
+		Backward:      r.URL.Query().Has(beforeParam),
+		After:         after,
+		Before:        before,
+		Query:         q,
+		City:          r.URL.Query().Get(cityParam),
+		Region:        r.URL.Query().Get(regionParam),
+		PostalCode:    r.URL.Query().Get(postalCodeParam),
+		Country:       r.URL.Query().Get(countryParam),
+		AnyTags:       splitList(r.URL.Query().Get(anyTagsParam)),
+		AllTags:       splitList(r.URL.Query().Get(allTagsParam)),
+		UpdatedSince:  updatedSince,
+		CreatedBefore: createdBefore,
+		Limit:         limit,
+		Offset:        offset,
+	}, nil
+}
+
+// parseTimeParam reads an RFC 3339 timestamp such as 2026-10-17T02:00:00Z, the zero time when the
+// parameter is missing.
+func parseTimeParam(r *http.Request, param string) (time.Time, error) {
+	value := r.URL.Query().Get(param)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be an RFC 3339 timestamp, got %q", param, value)
+	}
+	return t, nil
+}
+
+func decodePage(r *http.Request) (int, int) {
 	limitStr := r.URL.Query().Get(limitParam)
 	offsetStr := r.URL.Query().Get(offsetParam)
 
@@ -134,13 +499,65 @@ func decodeSearchContactsRequest(r *http.Request) (interface{}, error) {
 		offset = defaultOffset
 	}
 
-	return SearchContactsRequest{
-		Text:   query,
+	return limit, offset
+}
+
+// splitList reads a comma-separated query parameter such as "family,vendors".
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+func decodeAddGroupRequest(r *http.Request) (interface{}, error) {
+	var req CreateGroupRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	return req, err
+}
+
+func decodeGetGroupRequest(r *http.Request) (interface{}, error) {
+	id := chi.URLParam(r, idParam)
+	return GetGroupRequest{
+		ID: id,
+	}, nil
+}
+
+func decodeGetGroupContactsRequest(r *http.Request) (interface{}, error) {
+	limit, offset := decodePage(r)
+	return GetGroupContactsRequest{
+		ID:     chi.URLParam(r, idParam),
 		Limit:  limit,
 		Offset: offset,
 	}, nil
 }
 
+func decodeUpdateGroupRequest(r *http.Request) (interface{}, error) {
+	id := chi.URLParam(r, idParam)
+	var req UpdateGroupRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	req.ID = id
+	return req, err
+}
+
+func decodeDeleteGroupRequest(r *http.Request) (interface{}, error) {
+	id := chi.URLParam(r, idParam)
+	return DeleteGroupRequest{
+		ID: id,
+	}, nil
+}
+
+func decodeGroupMemberRequest(r *http.Request) (interface{}, error) {
+	return GroupMemberRequest{
+		GroupID:   chi.URLParam(r, idParam),
+		ContactID: chi.URLParam(r, contactIDParam),
+	}, nil
+}
+
 func encodeAddContactResponse(w http.ResponseWriter, id string) {
 	response := map[string]string{"id": id}
 	w.Header().Set("Content-Type", "application/json")
@@ -162,6 +579,14 @@ func encodeGetContactResponse(w http.ResponseWriter, response interface{}) {
 		"lastname":  res.LastName,
 		"phone":     res.Phone,
 		"address":   res.Address,
+		"phones":    res.Phones,
+		"emails":    res.Emails,
+		"addresses": res.Addresses,
+		"groups":    res.Groups,
+		"createdAt": res.CreatedAt,
+		"updatedAt": res.UpdatedAt,
+		"createdBy": res.CreatedBy,
+		"updatedBy": res.UpdatedBy,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -173,6 +598,64 @@ func encodeGetContactResponse(w http.ResponseWriter, response interface{}) {
 	}
 }
 
+func encodeGetContactsByPhoneResponse(w http.ResponseWriter, contacts []contact.Contact) {
+	response := map[string]interface{}{"contacts": contacts}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	}
+}
+
+func encodeSuggestContactsResponse(w http.ResponseWriter, suggestions []suggest.Suggestion) {
+	response := map[string]interface{}{"suggestions": suggestions}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	}
+}
+
+func encodeGetGroupsResponse(w http.ResponseWriter, groups []contact.Group) {
+	response := map[string]interface{}{"groups": groups}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	}
+}
+
+func encodeGetGroupResponse(w http.ResponseWriter, g contact.Group) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(g); err != nil {
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	}
+}
+
+// etag is the entity tag of a contact's version.
+func etag(version int64) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// matchesETag reports whether an If-Match or If-None-Match header lists the tag of version, "*"
+// matching any. If-Match compares strongly, so a weak W/ tag only matches when weak is set.
+func matchesETag(header string, version int64, weak bool) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if weak {
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+		if tag == "*" || tag == etag(version) {
+			return true
+		}
+	}
+	return false
+}
+
 func encodeUpdateContactResponse(w http.ResponseWriter) {
 	response := map[string]string{}
 
@@ -203,64 +686,97 @@ func encodeSearchContactsHandlerResponse(w http.ResponseWriter, response interfa
 	pagination := encodeSearchContactsPagination(context.Background(), res.Pagination, res.TotalContactsCount)
 
 	contactsjson := map[string]interface{}{
-		"contacts":   res.Contacts,
+		"contacts":   selectFields(res.Contacts, res.Fields),
 		"pagination": pagination,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	if link := linkHeader(pagination); link != "" {
+		w.Header().Set("Link", link)
+	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(res.TotalContactsCount))
 
 	if err := json.NewEncoder(w).Encode(contactsjson); err != nil {
 		http.Error(w, "Error encoding response", http.StatusInternalServerError)
 	}
 }
 
-func encodeSearchContactsPagination(ctx context.Context, pagination Pagination, totalContacts int) map[string]interface{} {
-	baseURL := "/contacts"
-	queryParamsStr := buildQueryParamsStr(pagination.queryParams)
-
-	var next, prev string
-
-	if pagination.next != nil {
-		if queryParamsStr != "" {
-			next = fmt.Sprintf("%s?%s&limit=%v&offset=%v&count=%v", baseURL, queryParamsStr, pagination.next.limit, pagination.next.offset, totalContacts)
-		} else {
-			next = fmt.Sprintf("%s?limit=%v&offset=%v&count=%v", baseURL, pagination.next.limit, pagination.next.offset, totalContacts)
-		}
+// selectFields narrows every contact to the requested JSON fields, or leaves them whole when none was.
+func selectFields(contacts []contact.Contact, fields []string) interface{} {
+	if len(fields) == 0 {
+		return contacts
 	}
 
-	if pagination.prev != nil {
-		if queryParamsStr != "" {
-			prev = fmt.Sprintf("%s?%s&limit=%v&offset=%v&count=%v", baseURL, queryParamsStr, pagination.prev.limit, pagination.prev.offset, totalContacts)
-		} else {
-			prev = fmt.Sprintf("%s?limit=%v&offset=%v&count=%v", baseURL, pagination.prev.limit, pagination.prev.offset, totalContacts)
+	selected := make([]map[string]json.RawMessage, 0, len(contacts))
+	for _, c := range contacts {
+		data, err := json.Marshal(c)
+		if err != nil {
+			continue
+		}
+		var all map[string]json.RawMessage
+		if err := json.Unmarshal(data, &all); err != nil {
+			continue
 		}
+		narrowed := make(map[string]json.RawMessage, len(fields))
+		for _, field := range fields {
+			if value, ok := all[field]; ok {
+				narrowed[field] = value
+			}
+		}
+		selected = append(selected, narrowed)
 	}
 
+	return selected
+}
+
+func encodeSearchContactsPagination(ctx context.Context, pagination Pagination, totalContacts int) map[string]interface{} {
 	return map[string]interface{}{
-		"next":  next,
-		"prev":  prev,
+		"next":  pageLink(pagination, pagination.next, totalContacts),
+		"prev":  pageLink(pagination, pagination.prev, totalContacts),
+		"first": pageLink(pagination, pagination.first, totalContacts),
+		"last":  pageLink(pagination, pagination.last, totalContacts),
 		"count": totalContacts,
 	}
 }
 
-func buildQueryParamsStr(queryParams map[string]string) string {
-	queryParamsStr := ""
-	paginationDefaultQueries := map[string]bool{
-		"count":  true,
-		"limit":  true,
-		"offset": true,
-	}
+// pageLinkRelations are the RFC 8288 relations of the pagination links, in header order.
+var pageLinkRelations = []string{"first", "prev", "next", "last"}
 
-	for k, v := range queryParams {
-		if _, ok := paginationDefaultQueries[k]; ok {
-			continue
-		}
-		if queryParamsStr == "" {
-			queryParamsStr = fmt.Sprintf("%s=%s", k, v)
-		} else {
-			queryParamsStr = fmt.Sprintf("%s&%s=%s", queryParamsStr, k, v)
+// linkHeader lists the pagination links of a response as an RFC 8288 Link header.
+func linkHeader(pagination map[string]interface{}) string {
+	var links []string
+	for _, rel := range pageLinkRelations {
+		if link, _ := pagination[rel].(string); link != "" {
+			links = append(links, fmt.Sprintf(`<%s>; rel="%s"`, link, rel))
 		}
 	}
 
-	return queryParamsStr
+	return strings.Join(links, ", ")
+}
+
+// pageLink is the absolute URL of a page: the request's own, with every parameter URL-encoded and
+// sorted by name, and the page pointed at by its cursor when it has one, by its offset otherwise.
+func pageLink(pagination Pagination, page *paginationValues, totalContacts int) string {
+	if page == nil {
+		return ""
+	}
+
+	query := url.Values{}
+	for key, values := range pagination.query {
+		query[key] = values
+	}
+	query.Set(limitParam, strconv.FormatInt(page.limit, 10))
+	switch {
+	case page.cursor && page.before:
+		query.Set(beforeParam, page.token)
+	case page.cursor:
+		query.Set(afterParam, page.token)
+	default:
+		query.Set(offsetParam, strconv.FormatInt(page.offset, 10))
+	}
+	query.Set(countParam, strconv.Itoa(totalContacts))
+
+	link := pagination.base
+	link.RawQuery = query.Encode()
+	return link.String()
 }
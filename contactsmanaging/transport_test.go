@@ -0,0 +1,482 @@
+package contactsmanaging
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ShaynaSegal45/phonebook-api/contact"
+	"github.com/ShaynaSegal45/phonebook-api/cursor"
+	"github.com/ShaynaSegal45/phonebook-api/errors"
+	"github.com/ShaynaSegal45/phonebook-api/query"
+)
+
+func TestDecodeSearchContactsRequest_SortAndFields(t *testing.T) {
+	request, err := decodeSearchContactsRequest(httptest.NewRequest("GET", "/contacts?sort=-lastName,firstName&fields=firstName,phone", nil))
+	require.NoError(t, err)
+	req := request.(SearchContactsRequest)
+	assert.Equal(t, []contact.SortField{{Field: contact.SortLastName, Desc: true}, {Field: contact.SortFirstName}}, req.Sort)
+	assert.Equal(t, []string{"id", "firstName", "phone"}, req.Fields)
+
+	_, err = decodeSearchContactsRequest(httptest.NewRequest("GET", "/contacts?sort=address", nil))
+	assert.Error(t, err)
+	_, err = decodeSearchContactsRequest(httptest.NewRequest("GET", "/contacts?fields=password", nil))
+	assert.Error(t, err)
+}
+
+func TestDecodeSearchContactsRequest_ChangedSince(t *testing.T) {
+	request, err := decodeSearchContactsRequest(httptest.NewRequest("GET", "/contacts?updatedSince=2026-10-17T02:00:00.5%2B03:00&createdBefore=2026-10-01T00:00:00Z", nil))
+	require.NoError(t, err)
+	filters := request.(SearchContactsRequest).toFilters()
+	assert.True(t, filters.UpdatedSince.Equal(time.Date(2026, time.October, 16, 23, 0, 0, 500000000, time.UTC)), filters.UpdatedSince)
+	assert.True(t, filters.CreatedBefore.Equal(time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC)), filters.CreatedBefore)
+
+	for _, target := range []string{"/contacts?updatedSince=yesterday", "/contacts?createdBefore=2026-10-01"} {
+		_, err = decodeSearchContactsRequest(httptest.NewRequest("GET", target, nil))
+		assert.Error(t, err, target)
+	}
+}
+
+func TestSearchContactsPagination_KeepsSortAndFields(t *testing.T) {
+	r := httptest.NewRequest("GET", "/contacts?sort=-lastName&fields=id,phone&limit=2&offset=2", nil)
+	pagination := encodeSearchContactsPagination(r.Context(), createPagination(2, 2, 5, r), 5)
+
+	for _, link := range []string{"next", "prev"} {
+		u, err := url.Parse(pagination[link].(string))
+		require.NoError(t, err)
+		assert.Equal(t, "-lastName", u.Query().Get("sort"), link)
+		assert.Equal(t, "id,phone", u.Query().Get("fields"), link)
+	}
+	assert.Equal(t, "http://example.com/contacts?count=5&fields=id%2Cphone&limit=2&offset=4&sort=-lastName", pagination["next"])
+	assert.Equal(t, "http://example.com/contacts?count=5&fields=id%2Cphone&limit=2&offset=0&sort=-lastName", pagination["prev"])
+}
+
+func TestSearchContactsPagination_Links(t *testing.T) {
+	r := httptest.NewRequest("GET", "/contacts?fullText=a%26b+Jos%C3%A9&tags=family&tags=vip&limit=10&offset=10&count=1", nil)
+	r.Header.Set("X-Forwarded-Host", "phonebook.example.org, proxy.internal")
+	r.Header.Set("X-Forwarded-Proto", "https")
+	pagination := encodeSearchContactsPagination(r.Context(), createPagination(10, 10, 25, r), 25)
+
+	assert.Equal(t, "https://phonebook.example.org/contacts?count=25&fullText=a%26b+Jos%C3%A9&limit=10&offset=20&tags=family&tags=vip", pagination["next"])
+	assert.Equal(t, "https://phonebook.example.org/contacts?count=25&fullText=a%26b+Jos%C3%A9&limit=10&offset=0&tags=family&tags=vip", pagination["prev"])
+	assert.Equal(t, pagination["prev"], pagination["first"])
+	assert.Equal(t, pagination["next"], pagination["last"])
+
+	u, err := url.Parse(pagination["next"].(string))
+	require.NoError(t, err)
+	assert.Equal(t, "a&b José", u.Query().Get("fullText"))
+
+	t.Run("are sent as a Link header with the total count", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		encodeSearchContactsHandlerResponse(w, SearchContactsResponse{Pagination: createPagination(10, 10, 25, r), TotalContactsCount: 25})
+
+		assert.Equal(t, "25", w.Header().Get("X-Total-Count"))
+		assert.Equal(t, fmt.Sprintf(`<%s>; rel="first", <%s>; rel="prev", <%s>; rel="next", <%s>; rel="last"`,
+			pagination["first"], pagination["prev"], pagination["next"], pagination["last"]), w.Header().Get("Link"))
+	})
+
+	t.Run("point at the last page when it is full", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/contacts", nil)
+		pagination := encodeSearchContactsPagination(r.Context(), createPagination(0, 10, 30, r), 30)
+		assert.Equal(t, "http://example.com/contacts?count=30&limit=10&offset=20", pagination["last"])
+		assert.Empty(t, pagination["prev"])
+
+		pagination = encodeSearchContactsPagination(r.Context(), createPagination(0, 10, 0, r), 0)
+		assert.Equal(t, "http://example.com/contacts?count=0&limit=10&offset=0", pagination["last"])
+	})
+}
+
+func TestSelectFields(t *testing.T) {
+	contacts := []contact.Contact{{ID: "1", FirstName: "Shayna", LastName: "Segal", Phone: "050-1234567"}}
+
+	data, err := json.Marshal(selectFields(contacts, []string{"id", "phone"}))
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"id": "1", "phone": "050-1234567"}]`, string(data))
+
+	assert.Equal(t, contacts, selectFields(contacts, nil))
+}
+
+func TestGetContacts_CursorPages(t *testing.T) {
+	repo := new(MockContactsRepo)
+	cursors := cursor.NewCodec([]byte("secret"))
+	handler := NewHTTPHandler(NewService(repo, new(MockGroupsRepo), "IL"), cursors, nil)
+
+	cohen := contact.Contact{ID: "1", FirstName: "Dan", LastName: "Cohen"}
+	levi := contact.Contact{ID: "2", FirstName: "Shai", LastName: "Levi"}
+	segal := contact.Contact{ID: "3", FirstName: "Shayna", LastName: "Segal"}
+	leviCursor := contact.Cursor{LastName: "Levi", FirstName: "Shai", ID: "2"}
+	segalCursor := contact.Cursor{LastName: "Segal", FirstName: "Shayna", ID: "3"}
+
+	repo.On("SearchContacts", mock.Anything, contact.Filters{Locale: "und", After: &contact.Cursor{}, Limit: 3}).
+		Return([]contact.Contact{cohen, levi, segal}, (*errors.Error)(nil))
+	repo.On("SearchContacts", mock.Anything, contact.Filters{Locale: "und", After: &leviCursor, Limit: 3}).
+		Return([]contact.Contact{segal}, (*errors.Error)(nil))
+	repo.On("SearchContacts", mock.Anything, contact.Filters{Locale: "und", Before: &segalCursor, Limit: 3}).
+		Return([]contact.Contact{cohen, levi}, (*errors.Error)(nil))
+	repo.On("SearchContacts", mock.Anything, contact.Filters{Locale: "und", Before: &contact.Cursor{}, Limit: 3}).
+		Return([]contact.Contact{cohen, levi, segal}, (*errors.Error)(nil))
+	repo.On("CountContacts", mock.Anything, mock.Anything).Return(3, (*errors.Error)(nil))
+
+	var first, last string
+	get := func(target string) (ids []string, next, prev string) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var response struct {
+			Contacts   []contact.Contact `json:"contacts"`
+			Pagination struct {
+				Next  string `json:"next"`
+				Prev  string `json:"prev"`
+				First string `json:"first"`
+				Last  string `json:"last"`
+			} `json:"pagination"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		first, last = response.Pagination.First, response.Pagination.Last
+		for _, c := range response.Contacts {
+			ids = append(ids, c.ID)
+		}
+		return ids, response.Pagination.Next, response.Pagination.Prev
+	}
+	position := func(link, param string) contact.Cursor {
+		t.Helper()
+		u, err := url.Parse(link)
+		require.NoError(t, err)
+		_, c, err := cursors.Decode(u.Query().Get(param))
+		require.NoError(t, err)
+		return c
+	}
+
+	ids, next, prev := get("/contacts?limit=2&after=")
+	assert.Equal(t, []string{"1", "2"}, ids)
+	assert.Equal(t, leviCursor, position(next, "after"))
+	assert.Empty(t, prev)
+	assert.Equal(t, "http://example.com/contacts?after=&count=3&limit=2", first)
+	assert.Equal(t, "http://example.com/contacts?before=&count=3&limit=2", last)
+
+	ids, next, prev = get(next)
+	assert.Equal(t, []string{"3"}, ids)
+	assert.Empty(t, next)
+	assert.Equal(t, segalCursor, position(prev, "before"))
+
+	ids, next, prev = get(prev)
+	assert.Equal(t, []string{"1", "2"}, ids)
+	assert.Equal(t, leviCursor, position(next, "after"))
+	assert.Empty(t, prev)
+
+	ids, next, prev = get(last)
+	assert.Equal(t, []string{"2", "3"}, ids)
+	assert.Empty(t, next)
+	assert.Equal(t, leviCursor, position(prev, "before"))
+
+	for _, target := range []string{
+		"/contacts?after=forged.token",
+		"/contacts?after=&before=",
+		"/contacts?after=&sort=firstName",
+		"/contacts?locale=he&after=" + cursors.Encode("und", leviCursor),
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, target)
+	}
+	repo.AssertExpectations(t)
+}
+
+func TestPatchContact(t *testing.T) {
+	repo := new(MockContactsRepo)
+	handler := NewHTTPHandler(NewService(repo, new(MockGroupsRepo), "IL"), cursor.NewCodec([]byte("secret")), nil)
+
+	stored := contact.Contact{ID: "1", FirstName: "Shayna", LastName: "Segal", Address: "Herzl 1, Haifa",
+		Addresses: []contact.Address{{Label: contact.LabelHome, Address: "Herzl 1, Haifa", Street: "Herzl 1", City: "Haifa", Primary: true}}}
+	repo.On("GetCurrentContact", mock.Anything, "1").Return(stored, (*errors.Error)(nil))
+	repo.On("GetCurrentContact", mock.Anything, "2").Return(contact.Contact{}, errors.CreateError(operationName, "GetContact", fmt.Errorf("not found"), errors.NotFoundError))
+
+	send := func(method, target, contentType, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		if contentType != "" {
+			r.Header.Set("Content-Type", contentType)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("a merge patch clears fields set to null", func(t *testing.T) {
+		repo.On("UpdateContact", mock.Anything, mock.MatchedBy(func(c contact.Contact) bool {
+			return c.FirstName == "Shayna" && c.LastName == "Cohen" && c.Address == "" && len(c.Addresses) == 0
+		})).Return(int64(2), (*errors.Error)(nil)).Once()
+
+		w := send("PATCH", "/contact/1", "application/merge-patch+json", `{"lastName": "Cohen", "address": null}`)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	})
+
+	t.Run("a JSON patch appends to a collection", func(t *testing.T) {
+		repo.On("UpdateContact", mock.Anything, mock.MatchedBy(func(c contact.Contact) bool {
+			return c.LastName == "Segal" && len(c.Emails) == 1 && c.Emails[0].Email == "shayna@example.com" && c.Address == "Herzl 1, Haifa"
+		})).Return(int64(2), (*errors.Error)(nil)).Once()
+
+		w := send("PATCH", "/contact/1", "application/json-patch+json; charset=utf-8",
+			`[{"op": "test", "path": "/lastName", "value": "Segal"}, {"op": "add", "path": "/emails/-", "value": {"email": "shayna@example.com"}}]`)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	})
+
+	for _, tc := range []struct {
+		name        string
+		target      string
+		contentType string
+		body        string
+		status      int
+	}{
+		{"unsupported media type", "/contact/1", "application/json", `{"lastName": "Cohen"}`, http.StatusUnsupportedMediaType},
+		{"missing body", "/contact/1", "application/merge-patch+json", ``, http.StatusBadRequest},
+		{"invalid operation", "/contact/1", "application/json-patch+json", `[{"op": "add", "path": "/lastName"}]`, http.StatusBadRequest},
+		{"failed test", "/contact/1", "application/json-patch+json", `[{"op": "test", "path": "/lastName", "value": "Levi"}]`, http.StatusConflict},
+		{"missing member", "/contact/1", "application/json-patch+json", `[{"op": "remove", "path": "/phones/0"}]`, http.StatusConflict},
+		{"changed id", "/contact/1", "application/merge-patch+json", `{"id": "2"}`, http.StatusBadRequest},
+		{"unknown member", "/contact/1", "application/merge-patch+json", `{"nickname": "Shay"}`, http.StatusBadRequest},
+		{"no name left", "/contact/1", "application/merge-patch+json", `{"firstName": null, "lastName": null}`, http.StatusBadRequest},
+		{"unknown contact", "/contact/2", "application/merge-patch+json", `{"lastName": "Cohen"}`, http.StatusNotFound},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := send("PATCH", tc.target, tc.contentType, tc.body)
+			assert.Equal(t, tc.status, w.Code, w.Body.String())
+		})
+	}
+
+	t.Run("a PUT requires a body", func(t *testing.T) {
+		w := send("PUT", "/contact/1", "application/json", ``)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "request body is required")
+	})
+	repo.AssertExpectations(t)
+}
+
+func TestContactETags(t *testing.T) {
+	repo := new(MockContactsRepo)
+	// httptest sends from 192.0.2.1.
+	trusted := []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")}
+	handler := NewHTTPHandler(newStoppedService(repo, new(MockGroupsRepo)), cursor.NewCodec([]byte("secret")), trusted)
+
+	stored := contact.Contact{ID: "1", FirstName: "Shayna", LastName: "Segal", Version: 3}
+	stale := errors.CreateError(operationName, "UpdateContact", fmt.Errorf("stale"), errors.PreconditionFailedError)
+	send := func(method, body string, headers ...string) *httptest.ResponseRecorder {
+		t.Helper()
+		r := httptest.NewRequest(method, "/contact/1", strings.NewReader(body))
+		for i := 0; i < len(headers); i += 2 {
+			r.Header.Set(headers[i], headers[i+1])
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("get sends the version and honours If-None-Match", func(t *testing.T) {
+		repo.On("GetContact", mock.Anything, "1").Return(stored, nil).Once()
+		repo.On("GetCurrentContact", mock.Anything, "1").Return(stored, (*errors.Error)(nil)).Twice()
+
+		w := send("GET", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+
+		w = send("GET", "", "If-None-Match", `"2", W/"3"`)
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Empty(t, w.Body.String())
+
+		w = send("GET", "", "If-None-Match", `"2"`)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("writes only replace the version If-Match names", func(t *testing.T) {
+		repo.On("GetCurrentContact", mock.Anything, "1").Return(stored, (*errors.Error)(nil)).Times(4)
+		updated := contact.Contact{ID: "1", FirstName: "Shay", Version: 3, UpdatedAt: stoppedClock, UpdatedBy: "shayna"}
+		repo.On("UpdateContact", mock.Anything, updated).Return(int64(4), (*errors.Error)(nil)).Once()
+		repo.On("DeleteContact", mock.Anything, "1", int64(3)).Return(stale).Once()
+
+		w := send("PUT", `{"firstName": "Shay"}`, "If-Match", `"2"`)
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+		w = send("PUT", `{"firstName": "Shay"}`, "If-Match", `W/"3"`)
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code, "If-Match compares strongly")
+
+		w = send("PUT", `{"firstName": "Shay"}`, "If-Match", `"3"`, "X-Forwarded-User", "shayna")
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, `"4"`, w.Header().Get("ETag"))
+
+		w = send("DELETE", "", "If-Match", `*`)
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code, "the contact changed after the check")
+	})
+
+	t.Run("a patch without If-Match is reapplied to a contact changed meanwhile", func(t *testing.T) {
+		changed := stored
+		changed.LastName, changed.Version = "Levi", 4
+		repo.On("GetCurrentContact", mock.Anything, "1").Return(stored, (*errors.Error)(nil)).Once()
+		repo.On("GetCurrentContact", mock.Anything, "1").Return(changed, (*errors.Error)(nil)).Once()
+		repo.On("UpdateContact", mock.Anything, mock.MatchedBy(func(c contact.Contact) bool { return c.Version == 3 })).Return(int64(0), stale).Once()
+		repo.On("UpdateContact", mock.Anything, mock.MatchedBy(func(c contact.Contact) bool {
+			return c.Version == 4 && c.FirstName == "Shay" && c.LastName == "Levi"
+		})).Return(int64(5), (*errors.Error)(nil)).Once()
+
+		w := send("PATCH", `{"firstName": "Shay"}`, "Content-Type", "application/merge-patch+json")
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, `"5"`, w.Header().Get("ETag"))
+	})
+
+	t.Run("a patch with If-Match isn't", func(t *testing.T) {
+		repo.On("GetCurrentContact", mock.Anything, "1").Return(stored, (*errors.Error)(nil)).Once()
+
+		w := send("PATCH", `{"firstName": "Shay"}`, "Content-Type", "application/merge-patch+json", "If-Match", `"2"`)
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	})
+
+	t.Run("conditional requests don't trust a stale cached version", func(t *testing.T) {
+		cached := stored
+		cached.Version = 2
+		repo.On("GetContact", mock.Anything, "1").Return(cached, nil).Once()
+		repo.On("GetCurrentContact", mock.Anything, "1").Return(stored, (*errors.Error)(nil)).Twice()
+		repo.On("DeleteContact", mock.Anything, "1", int64(3)).Return((*errors.Error)(nil)).Once()
+
+		w := send("GET", "")
+		assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+
+		w = send("GET", "", "If-None-Match", `"2"`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+
+		w = send("DELETE", "", "If-Match", `"3"`)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	})
+	repo.AssertExpectations(t)
+}
+
+func TestForwardedUser_TrustedProxies(t *testing.T) {
+	repo := new(MockContactsRepo)
+	trusted, err := ParseTrustedProxies(" 10.0.0.0/8, ::1,")
+	require.NoError(t, err)
+	handler := NewHTTPHandler(newStoppedService(repo, new(MockGroupsRepo)), cursor.NewCodec([]byte("secret")), trusted)
+
+	for _, test := range []struct {
+		remoteAddr string
+		actor      string
+	}{
+		{"10.1.2.3:4567", "proxy-user"},
+		{"[::1]:4567", "proxy-user"},
+		{"192.0.2.1:4567", ""},
+		{"[::ffff:10.1.2.3]:4567", "proxy-user"},
+	} {
+		t.Run(test.remoteAddr, func(t *testing.T) {
+			updated := contact.Contact{ID: "1", FirstName: "Shay", UpdatedAt: stoppedClock, UpdatedBy: test.actor}
+			repo.On("UpdateContact", mock.Anything, updated).Return(int64(4), (*errors.Error)(nil)).Once()
+
+			r := httptest.NewRequest("PUT", "/contact/1", strings.NewReader(`{"firstName": "Shay"}`))
+			r.RemoteAddr = test.remoteAddr
+			r.Header.Set("X-Forwarded-User", "proxy-user")
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		})
+	}
+	repo.AssertExpectations(t)
+
+	_, err = ParseTrustedProxies("10.0.0.0/33")
+	assert.Error(t, err)
+	_, err = ParseTrustedProxies("proxy.local")
+	assert.Error(t, err)
+}
+
+func TestGetContactsByPhone_EscapedNumber(t *testing.T) {
+	repo := new(MockContactsRepo)
+	handler := NewHTTPHandler(NewService(repo, new(MockGroupsRepo), "IL"), cursor.NewCodec([]byte("secret")), nil)
+	owner := contact.Contact{ID: "1", FirstName: "Shayna", LastName: "Segal"}
+	repo.On("FindContactsByPhone", mock.Anything, "+972501234567").Return([]contact.Contact{owner}, (*errors.Error)(nil))
+
+	for _, target := range []string{"/contacts/by-phone/%2B972501234567", "/contacts/by-phone/+972501234567", "/contacts/by-phone/050-123%204567"} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		assert.Equal(t, http.StatusOK, w.Code, target)
+		assert.Contains(t, w.Body.String(), `"id":"1"`, target)
+	}
+	repo.AssertExpectations(t)
+}
+
+func TestGetGroupContacts_PagesAndErrors(t *testing.T) {
+	repo := new(MockContactsRepo)
+	groups := new(MockGroupsRepo)
+	handler := NewHTTPHandler(NewService(repo, groups, "IL"), cursor.NewCodec([]byte("secret")), nil)
+
+	groups.On("GetGroup", mock.Anything, "g1").Return(contact.Group{ID: "g1", Name: "haifa", Query: "city:Haifa"}, (*errors.Error)(nil))
+	groups.On("GetGroup", mock.Anything, "g2").Return(contact.Group{ID: "g2", Name: "broken", Query: "city:"}, (*errors.Error)(nil))
+	groups.On("GetGroup", mock.Anything, "g3").
+		Return(contact.Group{}, errors.CreateError(operationName, "GetGroup", fmt.Errorf("not found"), errors.NotFoundError))
+	groups.On("GroupExists", mock.Anything, mock.Anything).Return(false, (*errors.Error)(nil))
+	haifa, err := query.Parse("city:Haifa")
+	require.NoError(t, err)
+	stored := []contact.Contact{{ID: "1"}, {ID: "2"}, {ID: "3"}, {ID: "4"}, {ID: "5"}}
+	for offset := 0; offset < len(stored); offset += 2 {
+		repo.On("SearchContacts", mock.Anything, contact.Filters{Query: haifa, Limit: 2, Offset: offset}).
+			Return(stored[offset:min(offset+2, len(stored))], (*errors.Error)(nil))
+	}
+	repo.On("CountContacts", mock.Anything, mock.MatchedBy(func(f contact.Filters) bool { return assert.ObjectsAreEqual(haifa, f.Query) })).Return(len(stored), (*errors.Error)(nil))
+
+	get := func(target string) (ids []string, next, prev string) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var response struct {
+			Contacts   []contact.Contact `json:"contacts"`
+			Pagination struct {
+				Next string `json:"next"`
+				Prev string `json:"prev"`
+			} `json:"pagination"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		for _, c := range response.Contacts {
+			ids = append(ids, c.ID)
+		}
+		assert.Equal(t, "5", w.Header().Get("X-Total-Count"))
+		return ids, response.Pagination.Next, response.Pagination.Prev
+	}
+
+	ids, next, prev := get("/groups/g1/contacts?limit=2")
+	assert.Equal(t, []string{"1", "2"}, ids)
+	assert.Equal(t, "http://example.com/groups/g1/contacts?count=5&limit=2&offset=2", next)
+	assert.Empty(t, prev)
+
+	ids, next, _ = get(next)
+	assert.Equal(t, []string{"3", "4"}, ids)
+
+	ids, next, prev = get(next)
+	assert.Equal(t, []string{"5"}, ids)
+	assert.Empty(t, next)
+	assert.Equal(t, "http://example.com/groups/g1/contacts?count=5&limit=2&offset=2", prev)
+
+	for target, status := range map[string]int{
+		"/groups/g2/contacts": http.StatusInternalServerError,
+		"/groups/g3/contacts": http.StatusNotFound,
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		assert.Equal(t, status, w.Code, target)
+	}
+
+	t.Run("a smart group query is checked on write with its position", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("POST", "/groups", strings.NewReader(`{"name": "vip", "query": "city:Haifa (tag:vip"}`)))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "expected ) to close ( at position 11")
+	})
+	repo.AssertExpectations(t)
+}
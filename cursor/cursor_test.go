@@ -0,0 +1,35 @@
+package cursor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ShaynaSegal45/phonebook-api/contact"
+)
+
+func TestCodec(t *testing.T) {
+	codec := NewCodec([]byte("secret"))
+	position := contact.Cursor{LastName: "Segal", FirstName: "Shayna", ID: "1"}
+
+	token := codec.Encode("he", position)
+	locale, decoded, err := codec.Decode(token)
+	require.NoError(t, err)
+	assert.Equal(t, "he", locale)
+	assert.Equal(t, position, decoded)
+
+	t.Run("rejects forged and malformed tokens", func(t *testing.T) {
+		_, _, err := NewCodec([]byte("other")).Decode(token)
+		assert.ErrorIs(t, err, ErrInvalid)
+
+		forged := NewCodec([]byte("secret")).Encode("he", contact.Cursor{ID: "2"})
+		_, _, err = codec.Decode(forged[:len(forged)-2] + token[len(token)-2:])
+		assert.ErrorIs(t, err, ErrInvalid)
+
+		for _, token := range []string{"", "abc", "abc.def", "." + token} {
+			_, _, err := codec.Decode(token)
+			assert.ErrorIs(t, err, ErrInvalid, token)
+		}
+	})
+}
@@ -13,9 +13,12 @@ type Error struct {
 }
 
 const (
-	InternalError = http.StatusInternalServerError
-	ConflictError = http.StatusConflict
-	NotFoundError = http.StatusNotFound
+	InternalError   = http.StatusInternalServerError
+	ConflictError   = http.StatusConflict
+	NotFoundError   = http.StatusNotFound
+	BadRequestError = http.StatusBadRequest
+	// PreconditionFailedError is a conditional write whose expected version is no longer the stored one.
+	PreconditionFailedError = http.StatusPreconditionFailed
 )
 
 func CreateError(operationName, functionName string, err error, status ...int) *Error {
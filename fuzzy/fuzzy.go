@@ -0,0 +1,91 @@
+// Package fuzzy matches names that are spelled a little differently, "Segel" and "Segal" or
+// "Jon" and "John": Key is a phonetic key stored next to every name, and Score rates a hit
+// by edit distance.
+package fuzzy
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/ShaynaSegal45/phonebook-api/collation"
+)
+
+// Key returns the Metaphone key of a name, e.g. "SKL" for "Segal". Accents are dropped and other
+// letters outside A-Z are ignored, so a name written in another script has an empty key.
+func Key(name string) string {
+	var letters []byte
+	for _, r := range strings.ToUpper(collation.Fold(name)) {
+		if r >= 'A' && r <= 'Z' {
+			letters = append(letters, byte(r))
+		}
+	}
+
+	return metaphone(string(letters))
+}
+
+// Similarity rates two words from 0 (nothing in common) to 1 (equal, ignoring case and accents), by
+// their edit distance. Words that sound alike score halfway closer to 1.
+func Similarity(a, b string) float64 {
+	a, b = collation.Fold(a), collation.Fold(b)
+	longest := max(len([]rune(a)), len([]rune(b)))
+	if longest == 0 {
+		return 0
+	}
+
+	similarity := 1 - float64(levenshtein(a, b))/float64(longest)
+	if key := Key(a); key != "" && key == Key(b) {
+		similarity = (similarity + 1) / 2
+	}
+
+	return similarity
+}
+
+// Score rates how well a query matches a contact's names: every word of the query is paired with
+// the name word most similar to it, and the similarities are averaged.
+func Score(query string, names ...string) float64 {
+	queryWords, nameWords := Words(query), Words(strings.Join(names, " "))
+	if len(queryWords) == 0 || len(nameWords) == 0 {
+		return 0
+	}
+
+	var total float64
+	for _, q := range queryWords {
+		var best float64
+		for _, n := range nameWords {
+			best = max(best, Similarity(q, n))
+		}
+		total += best
+	}
+
+	return total / float64(len(queryWords))
+}
+
+// Words splits a query or a name into words.
+func Words(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(rb)]
+}
@@ -0,0 +1,50 @@
+package fuzzy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKey(t *testing.T) {
+	tests := map[string]string{
+		"Segal":    "SKL",
+		"Jon":      "JN",
+		"John":     "JN",
+		"Cohen":    "KHN",
+		"Kohen":    "KHN",
+		"Philips":  "FLPS",
+		"Thomas":   "0MS",
+		"Schmidt":  "SKMTT",
+		"Knight":   "NT",
+		"Wright":   "RT",
+		"Xavier":   "SFR",
+		"O'Brien":  "OBRN",
+		"Mary-Ann": "MRYN",
+		"José":     "JS",
+		"שיינה":    "",
+		"":         "",
+	}
+
+	for name, expected := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, expected, Key(name))
+		})
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	assert.Equal(t, 1.0, Similarity("Segal", "segal"))
+	assert.InDelta(t, 0.8, Similarity("Segel", "Segal"), 0.001)
+	assert.InDelta(t, 0.875, Similarity("Jon", "John"), 0.001)
+	assert.Equal(t, 1.0, Similarity("Jose", "JOSÉ"))
+	assert.Less(t, Similarity("Jon", "Dan"), 0.5)
+	assert.Equal(t, 0.0, Similarity("", ""))
+}
+
+func TestScore(t *testing.T) {
+	assert.Equal(t, 1.0, Score("shayna segal", "Shayna", "Segal"))
+	assert.Greater(t, Score("jon segel", "John", "Segal"), Score("jon segel", "John", "Doe"))
+	assert.Equal(t, 0.0, Score("", "John"))
+	assert.Equal(t, 0.0, Score("john"))
+}
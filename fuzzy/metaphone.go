@@ -0,0 +1,130 @@
+package fuzzy
+
+import "strings"
+
+// metaphone implements Lawrence Philips' original Metaphone over upper-case A-Z letters.
+// "0" stands for the "th" sound and "X" for "sh".
+func metaphone(word string) string {
+	if word == "" {
+		return ""
+	}
+
+	switch {
+	case hasPrefix(word, "AE", "GN", "KN", "PN", "WR"):
+		word = word[1:]
+	case word[0] == 'X':
+		word = "S" + word[1:]
+	case strings.HasPrefix(word, "WH"):
+		word = "W" + word[2:]
+	}
+
+	at := func(i int) byte {
+		if i < 0 || i >= len(word) {
+			return 0
+		}
+		return word[i]
+	}
+	vowel := func(c byte) bool { return strings.IndexByte("AEIOU", c) >= 0 }
+	frontVowel := func(c byte) bool { return c == 'E' || c == 'I' || c == 'Y' }
+
+	var key strings.Builder
+	for i := 0; i < len(word); i++ {
+		c := word[i]
+		if c != 'C' && c == at(i-1) {
+			continue
+		}
+
+		switch c {
+		case 'A', 'E', 'I', 'O', 'U':
+			if i == 0 {
+				key.WriteByte(c)
+			}
+		case 'B':
+			if !(i == len(word)-1 && at(i-1) == 'M') {
+				key.WriteByte('B')
+			}
+		case 'C':
+			switch {
+			case at(i+1) == 'I' && at(i+2) == 'A', at(i+1) == 'H' && at(i-1) != 'S':
+				key.WriteByte('X')
+			case frontVowel(at(i + 1)):
+				if at(i-1) != 'S' {
+					key.WriteByte('S')
+				}
+			default:
+				key.WriteByte('K')
+			}
+		case 'D':
+			if at(i+1) == 'G' && frontVowel(at(i+2)) {
+				key.WriteByte('J')
+				i++
+			} else {
+				key.WriteByte('T')
+			}
+		case 'G':
+			switch {
+			case at(i+1) == 'H' && !(i+2 >= len(word) || vowel(at(i+2))):
+			case at(i+1) == 'N' && (i+2 == len(word) || word[i+2:] == "ED"):
+			case frontVowel(at(i+1)) && at(i-1) != 'G':
+				key.WriteByte('J')
+			default:
+				key.WriteByte('K')
+			}
+		case 'H':
+			if strings.IndexByte("CSPTG", at(i-1)) < 0 && !(vowel(at(i-1)) && !vowel(at(i+1))) {
+				key.WriteByte('H')
+			}
+		case 'K':
+			if at(i-1) != 'C' {
+				key.WriteByte('K')
+			}
+		case 'P':
+			if at(i+1) == 'H' {
+				key.WriteByte('F')
+			} else {
+				key.WriteByte('P')
+			}
+		case 'Q':
+			key.WriteByte('K')
+		case 'S':
+			if at(i+1) == 'H' || at(i+1) == 'I' && (at(i+2) == 'O' || at(i+2) == 'A') {
+				key.WriteByte('X')
+			} else {
+				key.WriteByte('S')
+			}
+		case 'T':
+			switch {
+			case at(i+1) == 'I' && (at(i+2) == 'O' || at(i+2) == 'A'):
+				key.WriteByte('X')
+			case at(i+1) == 'H':
+				key.WriteByte('0')
+			case at(i+1) == 'C' && at(i+2) == 'H':
+			default:
+				key.WriteByte('T')
+			}
+		case 'V':
+			key.WriteByte('F')
+		case 'W', 'Y':
+			if vowel(at(i + 1)) {
+				key.WriteByte(c)
+			}
+		case 'X':
+			key.WriteString("KS")
+		case 'Z':
+			key.WriteByte('S')
+		default:
+			key.WriteByte(c)
+		}
+	}
+
+	return key.String()
+}
+
+func hasPrefix(word string, prefixes ...string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(word, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,252 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+)
+
+const (
+	versionTable = "schema_version"
+	// stepsTable records the statements of a partly applied version, where DDL isn't transactional.
+	stepsTable = "schema_version_steps"
+)
+
+// Migration is one versioned schema change. Up and Down hold the statements for
+// every supported dialect, keyed by adapter name. Rewrite, when set, changes the stored rows in Go
+// after the Up statements, in the transaction that records the version.
+type Migration struct {
+	Version     int
+	Description string
+	Up          Statements
+	Down        Statements
+	Rewrite     func(ctx context.Context, tx *sql.Tx, dialect string) error
+}
+
+type Statements map[string][]string
+
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt string
+}
+
+type Migrator struct {
+	db         *sql.DB
+	dialect    string
+	migrations []Migration
+	// transactionalDDL is false on MySQL, which commits every DDL statement on its own.
+	transactionalDDL bool
+}
+
+func New(db *sql.DB, dialect string) *Migrator {
+	return &Migrator{
+		db:               db,
+		dialect:          dialect,
+		migrations:       All(),
+		transactionalDDL: dialect != mysql,
+	}
+}
+
+// All returns every known migration ordered by version.
+func All() []Migration {
+	migrations := make([]Migration, len(versions))
+	copy(migrations, versions)
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations
+}
+
+// Up applies every pending migration in version order and returns the ones it applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	applied, err := m.appliedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		if err := m.apply(ctx, migration.Version, "up", migration.Up, migration.Rewrite, fmt.Sprintf(`INSERT INTO %s (version) VALUES (%d)`, versionTable, migration.Version)); err != nil {
+			return done, fmt.Errorf("migrations.Up: version %d (%s): %w", migration.Version, migration.Description, err)
+		}
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
+
+// Down rolls back the latest steps applied migrations, newest first, and returns the ones it reverted.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	applied, err := m.appliedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if err := m.apply(ctx, migration.Version, "down", migration.Down, nil, fmt.Sprintf(`DELETE FROM %s WHERE version = %d`, versionTable, migration.Version)); err != nil {
+			return done, fmt.Errorf("migrations.Down: version %d (%s): %w", migration.Version, migration.Description, err)
+		}
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.appliedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		appliedAt, ok := applied[migration.Version]
+		statuses = append(statuses, Status{Migration: migration, Applied: ok, AppliedAt: appliedAt})
+	}
+
+	return statuses, nil
+}
+
+// apply runs the statements of a version and records it in one transaction, so a version that
+// fails leaves the schema as it was. Where DDL isn't transactional it runs them in steps instead.
+func (m *Migrator) apply(ctx context.Context, version int, direction string, statements Statements,
+	rewrite func(context.Context, *sql.Tx, string) error, record string) error {
+	queries, ok := statements[m.dialect]
+	if !ok {
+		return fmt.Errorf("no statements for dialect %q", m.dialect)
+	}
+	if !m.transactionalDDL {
+		return m.applyInSteps(ctx, version, direction, queries, rewrite, record)
+	}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, query := range queries {
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return err
+		}
+	}
+	if rewrite != nil {
+		if err := rewrite(ctx, tx, m.dialect); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, record); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// applyInSteps runs the statements of a version one by one, recording each in the steps table as it
+// commits. A version that failed partway, leaving the tables and columns of its first statements in
+// place, resumes after the last statement it ran once the cause is fixed, rather than failing again
+// on those. A crash between a statement and its record still needs the schema fixing by hand.
+func (m *Migrator) applyInSteps(ctx context.Context, version int, direction string, queries []string,
+	rewrite func(context.Context, *sql.Tx, string) error, record string) error {
+	ran := make(map[int]bool)
+	rows, err := m.db.QueryContext(ctx, fmt.Sprintf(`SELECT step FROM %s WHERE version = %d AND direction = '%s'`, stepsTable, version, direction))
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var step int
+		if err := rows.Scan(&step); err != nil {
+			rows.Close()
+			return err
+		}
+		ran[step] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for step, query := range queries {
+		if ran[step] {
+			continue
+		}
+		if _, err := m.db.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("statement %d: %w", step+1, err)
+		}
+		ranStep := fmt.Sprintf(`INSERT INTO %s (version, direction, step) VALUES (%d, '%s', %d)`, stepsTable, version, direction, step)
+		if _, err := m.db.ExecContext(ctx, ranStep); err != nil {
+			return err
+		}
+	}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if rewrite != nil {
+		if err := rewrite(ctx, tx, m.dialect); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, record); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE version = %d`, stepsTable, version)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// appliedVersions creates the version table on first use, and the steps table where DDL isn't transactional,
+// and maps each applied version to the time it was applied.
+func (m *Migrator) appliedVersions(ctx context.Context) (map[int]string, error) {
+	createTable := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		version INTEGER NOT NULL PRIMARY KEY,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`, versionTable)
+	if _, err := m.db.ExecContext(ctx, createTable); err != nil {
+		return nil, fmt.Errorf("migrations: failed to create %s table: %w", versionTable, err)
+	}
+
+	if !m.transactionalDDL {
+		createTable := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			version INTEGER NOT NULL,
+			direction VARCHAR(4) NOT NULL,
+			step INTEGER NOT NULL,
+			PRIMARY KEY (version, direction, step)
+		)`, stepsTable)
+		if _, err := m.db.ExecContext(ctx, createTable); err != nil {
+			return nil, fmt.Errorf("migrations: failed to create %s table: %w", stepsTable, err)
+		}
+	}
+
+	rows, err := m.db.QueryContext(ctx, fmt.Sprintf(`SELECT version, applied_at FROM %s`, versionTable))
+	if err != nil {
+		return nil, fmt.Errorf("migrations: failed to read %s: %w", versionTable, err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]string)
+	for rows.Next() {
+		var version int
+		var appliedAt string
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("migrations: failed to scan %s: %w", versionTable, err)
+		}
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
@@ -0,0 +1,177 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ShaynaSegal45/phonebook-api/config"
+	"github.com/ShaynaSegal45/phonebook-api/query"
+)
+
+func TestVersionsCoverEveryDialect(t *testing.T) {
+	seen := make(map[int]bool)
+	for _, m := range All() {
+		assert.False(t, seen[m.Version], "duplicate version %d", m.Version)
+		seen[m.Version] = true
+
+		for _, dialect := range []string{config.AdapterSQLite, config.AdapterMySQL, config.AdapterPostgres} {
+			assert.Contains(t, m.Up, dialect, "version %d has no up statements for %s", m.Version, dialect)
+			assert.Contains(t, m.Down, dialect, "version %d has no down statements for %s", m.Version, dialect)
+		}
+	}
+}
+
+func TestMigrator_UpDownStatus(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open(config.AdapterSQLite, ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+
+	migrator := New(db, config.AdapterSQLite)
+	total := len(All())
+
+	applied, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Len(t, applied, total)
+
+	applied, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, applied, "up must be idempotent")
+
+	statuses, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	for _, s := range statuses {
+		assert.True(t, s.Applied, "version %d", s.Version)
+		assert.NotEmpty(t, s.AppliedAt)
+	}
+
+	reverted, err := migrator.Down(ctx, total)
+	require.NoError(t, err)
+	assert.Len(t, reverted, total)
+
+	_, err = db.Exec(`SELECT 1 FROM contacts`)
+	assert.Error(t, err, "contacts table should be dropped")
+
+	statuses, err = migrator.Status(ctx)
+	require.NoError(t, err)
+	for _, s := range statuses {
+		assert.False(t, s.Applied, "version %d", s.Version)
+	}
+}
+
+// A version that failed partway where DDL isn't transactional, as on MySQL, resumes where it
+// stopped instead of failing on the tables its first statements already made.
+func TestMigrator_ResumesPartlyAppliedVersion(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open(config.AdapterSQLite, ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+
+	migrator := New(db, config.AdapterSQLite)
+	migrator.transactionalDDL = false
+	migrator.migrations = []Migration{{
+		Version:     1,
+		Description: "add a, b and c",
+		Up: Statements{config.AdapterSQLite: {
+			`CREATE TABLE a (id INTEGER)`,
+			`CREATE TABLE b (id INTEGER)`,
+			`CREATE TABLE c (id INTEGER)`,
+		}},
+		Down: Statements{config.AdapterSQLite: {
+			`DROP TABLE c`,
+			`DROP TABLE b`,
+			`DROP TABLE a`,
+		}},
+	}}
+
+	// c is in the way, so the version stops after making a and b.
+	_, err = db.Exec(`CREATE TABLE c (id INTEGER)`)
+	require.NoError(t, err)
+	_, err = migrator.Up(ctx)
+	require.ErrorContains(t, err, "statement 3")
+	statuses, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	assert.False(t, statuses[0].Applied)
+
+	_, err = db.Exec(`DROP TABLE c`)
+	require.NoError(t, err)
+	applied, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Len(t, applied, 1)
+	for _, table := range []string{"a", "b", "c"} {
+		_, err = db.Exec(`SELECT id FROM ` + table)
+		assert.NoError(t, err, table)
+	}
+
+	// The same goes for a version rolled back partway.
+	_, err = db.Exec(`DROP TABLE b`)
+	require.NoError(t, err)
+	_, err = migrator.Down(ctx, 1)
+	require.ErrorContains(t, err, "statement 2")
+	_, err = db.Exec(`CREATE TABLE b (id INTEGER)`)
+	require.NoError(t, err)
+	reverted, err := migrator.Down(ctx, 1)
+	require.NoError(t, err)
+	assert.Len(t, reverted, 1)
+
+	var steps int
+	require.NoError(t, db.QueryRow(`SELECT count(*) FROM `+stepsTable).Scan(&steps))
+	assert.Zero(t, steps)
+}
+
+func TestMigrator_RewritesSmartQueries(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open(config.AdapterSQLite, ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+
+	migrator := New(db, config.AdapterSQLite)
+	all := migrator.migrations
+	migrator.migrations = all[:len(all)-1]
+	_, err = migrator.Up(ctx)
+	require.NoError(t, err)
+
+	groups := map[string]string{
+		"static":  "",
+		"city":    "city=Haifa",
+		"text":    `text="jo-anne segal" AND tag=vip`,
+		"phrase":  `city="Tel Aviv" and country=Israel`,
+		"special": `region=-north AND postalCode=1234*`,
+		"broken":  "city=",
+	}
+	for id, q := range groups {
+		_, err := db.Exec(`INSERT INTO contact_groups (id, name, smart_query) VALUES (?, ?, ?)`, id, id, q)
+		require.NoError(t, err)
+	}
+
+	migrator.migrations = all
+	applied, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	require.Len(t, applied, 1)
+
+	want := map[string]string{
+		"static":  "",
+		"city":    "city:Haifa",
+		"text":    "jo anne segal tag:vip",
+		"phrase":  `city:"Tel Aviv" country:Israel`,
+		"special": `region:"-north" postalCode:"1234*"`,
+		"broken":  "city=",
+	}
+	for id, q := range want {
+		var got string
+		require.NoError(t, db.QueryRow(`SELECT smart_query FROM contact_groups WHERE id = ?`, id).Scan(&got))
+		assert.Equal(t, q, got, id)
+		if got != "" && id != "broken" {
+			_, err := query.Parse(got)
+			assert.NoError(t, err, id)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// rewriteSmartQueries converts the smart group queries stored in the `field=value AND field=value`
+// form smart groups were first written in to the search language of GET /contacts, e.g.
+// `city=Haifa AND text=segal` to `city:Haifa segal`. A query that doesn't parse is left as it was.
+func rewriteSmartQueries(ctx context.Context, tx *sql.Tx, dialect string) error {
+	rows, err := tx.QueryContext(ctx, `SELECT id, smart_query FROM contact_groups WHERE smart_query IS NOT NULL AND smart_query <> ''`)
+	if err != nil {
+		return err
+	}
+	rewritten := make(map[string]string)
+	for rows.Next() {
+		var id, old string
+		if err := rows.Scan(&id, &old); err != nil {
+			rows.Close()
+			return err
+		}
+		if q, ok := rewriteSmartQuery(old); ok {
+			rewritten[id] = q
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	update := `UPDATE contact_groups SET smart_query = ? WHERE id = ?`
+	if dialect == postgres {
+		update = `UPDATE contact_groups SET smart_query = $1 WHERE id = $2`
+	}
+	for id, q := range rewritten {
+		if _, err := tx.ExecContext(ctx, update, q, id); err != nil {
+			return fmt.Errorf("group %s: %w", id, err)
+		}
+	}
+
+	return nil
+}
+
+// rewriteSmartQuery reads a query of the first smart groups: field=value conditions over text,
+// city, region, postalCode, country and tag, joined by AND in any case, values double-quoted when
+// they hold spaces. Text values become bare words, the others field:value terms.
+func rewriteSmartQuery(old string) (string, bool) {
+	var terms []string
+	rest := strings.TrimLeft(old, " ")
+	for {
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 {
+			return "", false
+		}
+		field := rest[:eq]
+		rest = rest[eq+1:]
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return "", false
+			}
+			value, rest = rest[1:1+end], rest[2+end:]
+		} else {
+			end := strings.IndexByte(rest, ' ')
+			if end < 0 {
+				end = len(rest)
+			}
+			value, rest = rest[:end], rest[end:]
+		}
+		if value == "" || strings.Contains(value, `"`) {
+			return "", false
+		}
+
+		switch field {
+		case "text":
+			// The fullText ignored everything but letters and digits.
+			for _, word := range strings.FieldsFunc(value, func(r rune) bool {
+				return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+			}) {
+				terms = append(terms, queryValue(word))
+			}
+		case "city", "region", "postalCode", "country", "tag":
+			terms = append(terms, field+":"+queryValue(value))
+		default:
+			return "", false
+		}
+
+		rest = strings.TrimLeft(rest, " ")
+		if rest == "" {
+			return strings.Join(terms, " "), len(terms) > 0
+		}
+		and, after, found := strings.Cut(rest, " ")
+		if !found || !strings.EqualFold(and, "AND") {
+			return "", false
+		}
+		rest = strings.TrimLeft(after, " ")
+	}
+}
+
+// queryValue quotes a value the search language would otherwise read as more than a plain word:
+// an operator, a negation, a prefix or several words.
+func queryValue(value string) string {
+	switch {
+	case value == "AND", value == "OR", value == "NOT", strings.HasPrefix(value, "-"),
+		strings.HasSuffix(value, "*"), strings.ContainsAny(value, ` ():`):
+		return `"` + value + `"`
+	default:
+		return value
+	}
+}
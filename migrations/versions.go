@@ -0,0 +1,515 @@
+package migrations
+
+import "github.com/ShaynaSegal45/phonebook-api/config"
+
+const (
+	sqlite   = config.AdapterSQLite
+	mysql    = config.AdapterMySQL
+	postgres = config.AdapterPostgres
+)
+
+// versions lists every schema change. Never edit an applied migration, add a new version instead.
+var versions = []Migration{
+	{
+		Version:     1,
+		Description: "create contacts table",
+		Up: Statements{
+			sqlite: {
+				`CREATE TABLE IF NOT EXISTS contacts (
+					id TEXT PRIMARY KEY,
+					firstname TEXT,
+					lastname TEXT,
+					address TEXT,
+					phone TEXT
+				)`,
+				`CREATE INDEX IF NOT EXISTS idx_id ON contacts(id)`,
+				`CREATE INDEX IF NOT EXISTS idx_name ON contacts(firstname)`,
+			},
+			// MySQL has no CREATE INDEX IF NOT EXISTS, so the index is declared inline with the table.
+			mysql: {
+				`CREATE TABLE IF NOT EXISTS contacts (
+					id VARCHAR(36) NOT NULL PRIMARY KEY,
+					firstname VARCHAR(255),
+					lastname VARCHAR(255),
+					address TEXT,
+					phone VARCHAR(64),
+					INDEX idx_name (firstname)
+				) DEFAULT CHARSET=utf8mb4`,
+			},
+			// The search vector is a generated column so it can never drift from the row it indexes.
+			postgres: {
+				`CREATE TABLE IF NOT EXISTS contacts (
+					id TEXT PRIMARY KEY,
+					firstname TEXT,
+					lastname TEXT,
+					address TEXT,
+					phone TEXT,
+					search_vector tsvector GENERATED ALWAYS AS (
+						to_tsvector('simple', coalesce(firstname, '') || ' ' || coalesce(lastname, '') || ' ' || coalesce(phone, ''))
+					) STORED
+				)`,
+				`CREATE INDEX IF NOT EXISTS idx_name ON contacts(firstname)`,
+				`CREATE INDEX IF NOT EXISTS idx_search_vector ON contacts USING GIN (search_vector)`,
+			},
+		},
+		Down: Statements{
+			sqlite:   {`DROP TABLE IF EXISTS contacts`},
+			mysql:    {`DROP TABLE IF EXISTS contacts`},
+			postgres: {`DROP TABLE IF EXISTS contacts`},
+		},
+	},
+	{
+		Version:     2,
+		Description: "add phones, emails and addresses tables",
+		Up: Statements{
+			sqlite: {
+				`CREATE TABLE contact_phones (
+					contact_id TEXT NOT NULL,
+					position INTEGER NOT NULL,
+					label TEXT NOT NULL,
+					number TEXT NOT NULL,
+					is_primary BOOLEAN NOT NULL DEFAULT 0,
+					PRIMARY KEY (contact_id, position)
+				)`,
+				`CREATE TABLE contact_emails (
+					contact_id TEXT NOT NULL,
+					position INTEGER NOT NULL,
+					label TEXT NOT NULL,
+					email TEXT NOT NULL,
+					is_primary BOOLEAN NOT NULL DEFAULT 0,
+					PRIMARY KEY (contact_id, position)
+				)`,
+				`CREATE TABLE contact_addresses (
+					contact_id TEXT NOT NULL,
+					position INTEGER NOT NULL,
+					label TEXT NOT NULL,
+					address TEXT NOT NULL,
+					is_primary BOOLEAN NOT NULL DEFAULT 0,
+					PRIMARY KEY (contact_id, position)
+				)`,
+				`INSERT INTO contact_phones (contact_id, position, label, number, is_primary)
+					SELECT id, 0, 'mobile', phone, 1 FROM contacts WHERE phone IS NOT NULL AND phone <> ''`,
+				`INSERT INTO contact_addresses (contact_id, position, label, address, is_primary)
+					SELECT id, 0, 'home', address, 1 FROM contacts WHERE address IS NOT NULL AND address <> ''`,
+			},
+			mysql: {
+				`CREATE TABLE contact_phones (
+					contact_id VARCHAR(36) NOT NULL,
+					position INT NOT NULL,
+					label VARCHAR(16) NOT NULL,
+					number VARCHAR(64) NOT NULL,
+					is_primary BOOLEAN NOT NULL DEFAULT FALSE,
+					PRIMARY KEY (contact_id, position)
+				) DEFAULT CHARSET=utf8mb4`,
+				`CREATE TABLE contact_emails (
+					contact_id VARCHAR(36) NOT NULL,
+					position INT NOT NULL,
+					label VARCHAR(16) NOT NULL,
+					email VARCHAR(320) NOT NULL,
+					is_primary BOOLEAN NOT NULL DEFAULT FALSE,
+					PRIMARY KEY (contact_id, position)
+				) DEFAULT CHARSET=utf8mb4`,
+				`CREATE TABLE contact_addresses (
+					contact_id VARCHAR(36) NOT NULL,
+					position INT NOT NULL,
+					label VARCHAR(16) NOT NULL,
+					address TEXT NOT NULL,
+					is_primary BOOLEAN NOT NULL DEFAULT FALSE,
+					PRIMARY KEY (contact_id, position)
+				) DEFAULT CHARSET=utf8mb4`,
+				`INSERT INTO contact_phones (contact_id, position, label, number, is_primary)
+					SELECT id, 0, 'mobile', phone, TRUE FROM contacts WHERE phone IS NOT NULL AND phone <> ''`,
+				`INSERT INTO contact_addresses (contact_id, position, label, address, is_primary)
+					SELECT id, 0, 'home', address, TRUE FROM contacts WHERE address IS NOT NULL AND address <> ''`,
+			},
+			postgres: {
+				`CREATE TABLE contact_phones (
+					contact_id TEXT NOT NULL,
+					position INTEGER NOT NULL,
+					label TEXT NOT NULL,
+					number TEXT NOT NULL,
+					is_primary BOOLEAN NOT NULL DEFAULT FALSE,
+					search_vector tsvector GENERATED ALWAYS AS (to_tsvector('simple', number)) STORED,
+					PRIMARY KEY (contact_id, position)
+				)`,
+				`CREATE TABLE contact_emails (
+					contact_id TEXT NOT NULL,
+					position INTEGER NOT NULL,
+					label TEXT NOT NULL,
+					email TEXT NOT NULL,
+					is_primary BOOLEAN NOT NULL DEFAULT FALSE,
+					search_vector tsvector GENERATED ALWAYS AS (to_tsvector('simple', email)) STORED,
+					PRIMARY KEY (contact_id, position)
+				)`,
+				`CREATE TABLE contact_addresses (
+					contact_id TEXT NOT NULL,
+					position INTEGER NOT NULL,
+					label TEXT NOT NULL,
+					address TEXT NOT NULL,
+					is_primary BOOLEAN NOT NULL DEFAULT FALSE,
+					search_vector tsvector GENERATED ALWAYS AS (to_tsvector('simple', address)) STORED,
+					PRIMARY KEY (contact_id, position)
+				)`,
+				`CREATE INDEX idx_contact_phones_search ON contact_phones USING GIN (search_vector)`,
+				`CREATE INDEX idx_contact_emails_search ON contact_emails USING GIN (search_vector)`,
+				`CREATE INDEX idx_contact_addresses_search ON contact_addresses USING GIN (search_vector)`,
+				`INSERT INTO contact_phones (contact_id, position, label, number, is_primary)
+					SELECT id, 0, 'mobile', phone, TRUE FROM contacts WHERE phone IS NOT NULL AND phone <> ''`,
+				`INSERT INTO contact_addresses (contact_id, position, label, address, is_primary)
+					SELECT id, 0, 'home', address, TRUE FROM contacts WHERE address IS NOT NULL AND address <> ''`,
+			},
+		},
+		Down: Statements{
+			sqlite:   {`DROP TABLE contact_addresses`, `DROP TABLE contact_emails`, `DROP TABLE contact_phones`},
+			mysql:    {`DROP TABLE contact_addresses`, `DROP TABLE contact_emails`, `DROP TABLE contact_phones`},
+			postgres: {`DROP TABLE contact_addresses`, `DROP TABLE contact_emails`, `DROP TABLE contact_phones`},
+		},
+	},
+	{
+		Version:     3,
+		Description: "add structured address fields",
+		Up: Statements{
+			// The new columns stay NULL until the repository parses the free-text address into them.
+			sqlite: {
+				`ALTER TABLE contact_addresses ADD COLUMN street TEXT`,
+				`ALTER TABLE contact_addresses ADD COLUMN city TEXT`,
+				`ALTER TABLE contact_addresses ADD COLUMN region TEXT`,
+				`ALTER TABLE contact_addresses ADD COLUMN postal_code TEXT`,
+				`ALTER TABLE contact_addresses ADD COLUMN country_code TEXT`,
+				`CREATE INDEX idx_contact_addresses_city ON contact_addresses(lower(city))`,
+				`CREATE INDEX idx_contact_addresses_country ON contact_addresses(country_code)`,
+			},
+			mysql: {
+				`ALTER TABLE contact_addresses
+					ADD COLUMN street VARCHAR(255),
+					ADD COLUMN city VARCHAR(255),
+					ADD COLUMN region VARCHAR(255),
+					ADD COLUMN postal_code VARCHAR(16),
+					ADD COLUMN country_code CHAR(2)`,
+				`CREATE INDEX idx_contact_addresses_city ON contact_addresses((lower(city)))`,
+				`CREATE INDEX idx_contact_addresses_country ON contact_addresses(country_code)`,
+			},
+			postgres: {
+				`ALTER TABLE contact_addresses
+					ADD COLUMN street TEXT,
+					ADD COLUMN city TEXT,
+					ADD COLUMN region TEXT,
+					ADD COLUMN postal_code TEXT,
+					ADD COLUMN country_code TEXT`,
+				`CREATE INDEX idx_contact_addresses_city ON contact_addresses(lower(city))`,
+				`CREATE INDEX idx_contact_addresses_country ON contact_addresses(country_code)`,
+			},
+		},
+		Down: Statements{
+			sqlite: {
+				`DROP INDEX idx_contact_addresses_country`,
+				`DROP INDEX idx_contact_addresses_city`,
+				`ALTER TABLE contact_addresses DROP COLUMN country_code`,
+				`ALTER TABLE contact_addresses DROP COLUMN postal_code`,
+				`ALTER TABLE contact_addresses DROP COLUMN region`,
+				`ALTER TABLE contact_addresses DROP COLUMN city`,
+				`ALTER TABLE contact_addresses DROP COLUMN street`,
+			},
+			mysql: {
+				`DROP INDEX idx_contact_addresses_country ON contact_addresses`,
+				`DROP INDEX idx_contact_addresses_city ON contact_addresses`,
+				`ALTER TABLE contact_addresses
+					DROP COLUMN country_code,
+					DROP COLUMN postal_code,
+					DROP COLUMN region,
+					DROP COLUMN city,
+					DROP COLUMN street`,
+			},
+			postgres: {
+				`DROP INDEX idx_contact_addresses_country`,
+				`DROP INDEX idx_contact_addresses_city`,
+				`ALTER TABLE contact_addresses
+					DROP COLUMN country_code,
+					DROP COLUMN postal_code,
+					DROP COLUMN region,
+					DROP COLUMN city,
+					DROP COLUMN street`,
+			},
+		},
+	},
+	{
+		Version:     4,
+		Description: "add e164 phone numbers",
+		Up: Statements{
+			// e164 stays NULL until the repository normalizes the numbers stored before it existed.
+			sqlite: {
+				`ALTER TABLE contact_phones ADD COLUMN e164 TEXT`,
+				`CREATE INDEX idx_contact_phones_e164 ON contact_phones(e164)`,
+			},
+			mysql: {
+				`ALTER TABLE contact_phones ADD COLUMN e164 VARCHAR(16)`,
+				`CREATE INDEX idx_contact_phones_e164 ON contact_phones(e164)`,
+			},
+			postgres: {
+				`ALTER TABLE contact_phones ADD COLUMN e164 TEXT`,
+				`CREATE INDEX idx_contact_phones_e164 ON contact_phones(e164)`,
+			},
+		},
+		Down: Statements{
+			sqlite:   {`DROP INDEX idx_contact_phones_e164`, `ALTER TABLE contact_phones DROP COLUMN e164`},
+			mysql:    {`DROP INDEX idx_contact_phones_e164 ON contact_phones`, `ALTER TABLE contact_phones DROP COLUMN e164`},
+			postgres: {`DROP INDEX idx_contact_phones_e164`, `ALTER TABLE contact_phones DROP COLUMN e164`},
+		},
+	},
+	{
+		Version:     5,
+		Description: "add contact groups",
+		Up: Statements{
+			sqlite: {
+				`CREATE TABLE contact_groups (
+					id TEXT PRIMARY KEY,
+					name TEXT NOT NULL UNIQUE
+				)`,
+				`CREATE TABLE contact_group_members (
+					group_id TEXT NOT NULL,
+					contact_id TEXT NOT NULL,
+					PRIMARY KEY (group_id, contact_id)
+				)`,
+				`CREATE INDEX idx_contact_group_members_contact ON contact_group_members(contact_id)`,
+			},
+			mysql: {
+				`CREATE TABLE contact_groups (
+					id VARCHAR(36) NOT NULL PRIMARY KEY,
+					name VARCHAR(64) NOT NULL,
+					UNIQUE INDEX idx_contact_groups_name (name)
+				) DEFAULT CHARSET=utf8mb4`,
+				`CREATE TABLE contact_group_members (
+					group_id VARCHAR(36) NOT NULL,
+					contact_id VARCHAR(36) NOT NULL,
+					PRIMARY KEY (group_id, contact_id),
+					INDEX idx_contact_group_members_contact (contact_id)
+				) DEFAULT CHARSET=utf8mb4`,
+			},
+			postgres: {
+				`CREATE TABLE contact_groups (
+					id TEXT PRIMARY KEY,
+					name TEXT NOT NULL UNIQUE
+				)`,
+				`CREATE TABLE contact_group_members (
+					group_id TEXT NOT NULL,
+					contact_id TEXT NOT NULL,
+					PRIMARY KEY (group_id, contact_id)
+				)`,
+				`CREATE INDEX idx_contact_group_members_contact ON contact_group_members(contact_id)`,
+			},
+		},
+		Down: Statements{
+			sqlite:   {`DROP TABLE contact_group_members`, `DROP TABLE contact_groups`},
+			mysql:    {`DROP TABLE contact_group_members`, `DROP TABLE contact_groups`},
+			postgres: {`DROP TABLE contact_group_members`, `DROP TABLE contact_groups`},
+		},
+	},
+	{
+		Version:     6,
+		Description: "add smart group queries",
+		Up: Statements{
+			sqlite:   {`ALTER TABLE contact_groups ADD COLUMN smart_query TEXT`},
+			mysql:    {`ALTER TABLE contact_groups ADD COLUMN smart_query TEXT`},
+			postgres: {`ALTER TABLE contact_groups ADD COLUMN smart_query TEXT`},
+		},
+		Down: Statements{
+			sqlite:   {`ALTER TABLE contact_groups DROP COLUMN smart_query`},
+			mysql:    {`ALTER TABLE contact_groups DROP COLUMN smart_query`},
+			postgres: {`ALTER TABLE contact_groups DROP COLUMN smart_query`},
+		},
+	},
+	{
+		Version:     7,
+		Description: "add phonetic name keys",
+		Up: Statements{
+			// The keys stay NULL until the repository computes them for the contacts stored before they existed.
+			sqlite: {
+				`ALTER TABLE contacts ADD COLUMN firstname_key TEXT`,
+				`ALTER TABLE contacts ADD COLUMN lastname_key TEXT`,
+				`CREATE INDEX idx_contacts_firstname_key ON contacts(firstname_key)`,
+				`CREATE INDEX idx_contacts_lastname_key ON contacts(lastname_key)`,
+			},
+			mysql: {
+				`ALTER TABLE contacts ADD COLUMN firstname_key VARCHAR(64), ADD COLUMN lastname_key VARCHAR(64)`,
+				`CREATE INDEX idx_contacts_firstname_key ON contacts(firstname_key)`,
+				`CREATE INDEX idx_contacts_lastname_key ON contacts(lastname_key)`,
+			},
+			postgres: {
+				`ALTER TABLE contacts ADD COLUMN firstname_key TEXT, ADD COLUMN lastname_key TEXT`,
+				`CREATE INDEX idx_contacts_firstname_key ON contacts(firstname_key)`,
+				`CREATE INDEX idx_contacts_lastname_key ON contacts(lastname_key)`,
+			},
+		},
+		Down: Statements{
+			sqlite: {
+				`DROP INDEX idx_contacts_lastname_key`,
+				`DROP INDEX idx_contacts_firstname_key`,
+				`ALTER TABLE contacts DROP COLUMN lastname_key`,
+				`ALTER TABLE contacts DROP COLUMN firstname_key`,
+			},
+			mysql: {`ALTER TABLE contacts DROP COLUMN lastname_key, DROP COLUMN firstname_key`},
+			postgres: {
+				`DROP INDEX idx_contacts_lastname_key`,
+				`DROP INDEX idx_contacts_firstname_key`,
+				`ALTER TABLE contacts DROP COLUMN lastname_key, DROP COLUMN firstname_key`,
+			},
+		},
+	},
+	{
+		Version:     8,
+		Description: "add folded search keys and locale sort keys",
+		Up: Statements{
+			// The keys stay NULL or missing until the repository computes them for the contacts stored before they existed.
+			sqlite: {
+				`ALTER TABLE contacts ADD COLUMN firstname_folded TEXT`,
+				`ALTER TABLE contacts ADD COLUMN lastname_folded TEXT`,
+				`ALTER TABLE contact_addresses ADD COLUMN address_folded TEXT`,
+				`CREATE TABLE contact_sort_keys (
+					contact_id TEXT NOT NULL,
+					locale TEXT NOT NULL,
+					sort_key TEXT NOT NULL,
+					PRIMARY KEY (contact_id, locale)
+				)`,
+				`CREATE INDEX idx_contact_sort_keys_locale ON contact_sort_keys(locale, sort_key)`,
+			},
+			mysql: {
+				`ALTER TABLE contacts ADD COLUMN firstname_folded VARCHAR(255), ADD COLUMN lastname_folded VARCHAR(255)`,
+				`ALTER TABLE contact_addresses ADD COLUMN address_folded TEXT`,
+				`CREATE TABLE contact_sort_keys (
+					contact_id VARCHAR(36) NOT NULL,
+					locale VARCHAR(8) NOT NULL,
+					sort_key VARCHAR(1024) CHARACTER SET ascii COLLATE ascii_bin NOT NULL,
+					PRIMARY KEY (contact_id, locale),
+					INDEX idx_contact_sort_keys_locale (locale, sort_key)
+				) DEFAULT CHARSET=utf8mb4`,
+			},
+			postgres: {
+				`ALTER TABLE contacts ADD COLUMN firstname_folded TEXT, ADD COLUMN lastname_folded TEXT`,
+				`ALTER TABLE contact_addresses ADD COLUMN address_folded TEXT`,
+				// The tsvectors are rebuilt over the folded columns, so accents don't matter to the fullText search.
+				`ALTER TABLE contacts DROP COLUMN search_vector`,
+				`ALTER TABLE contacts ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+					to_tsvector('simple', coalesce(firstname_folded, '') || ' ' || coalesce(lastname_folded, '') || ' ' || coalesce(phone, ''))
+				) STORED`,
+				`CREATE INDEX idx_search_vector ON contacts USING GIN (search_vector)`,
+				`ALTER TABLE contact_addresses DROP COLUMN search_vector`,
+				`ALTER TABLE contact_addresses ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+					to_tsvector('simple', coalesce(address_folded, ''))
+				) STORED`,
+				`CREATE INDEX idx_contact_addresses_search ON contact_addresses USING GIN (search_vector)`,
+				`CREATE TABLE contact_sort_keys (
+					contact_id TEXT NOT NULL,
+					locale TEXT NOT NULL,
+					sort_key TEXT COLLATE "C" NOT NULL,
+					PRIMARY KEY (contact_id, locale)
+				)`,
+				`CREATE INDEX idx_contact_sort_keys_locale ON contact_sort_keys(locale, sort_key)`,
+			},
+		},
+		Down: Statements{
+			sqlite: {
+				`DROP TABLE contact_sort_keys`,
+				`ALTER TABLE contact_addresses DROP COLUMN address_folded`,
+				`ALTER TABLE contacts DROP COLUMN lastname_folded`,
+				`ALTER TABLE contacts DROP COLUMN firstname_folded`,
+			},
+			mysql: {
+				`DROP TABLE contact_sort_keys`,
+				`ALTER TABLE contact_addresses DROP COLUMN address_folded`,
+				`ALTER TABLE contacts DROP COLUMN lastname_folded, DROP COLUMN firstname_folded`,
+			},
+			postgres: {
+				`DROP TABLE contact_sort_keys`,
+				`ALTER TABLE contact_addresses DROP COLUMN search_vector`,
+				`ALTER TABLE contact_addresses ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (to_tsvector('simple', address)) STORED`,
+				`CREATE INDEX idx_contact_addresses_search ON contact_addresses USING GIN (search_vector)`,
+				`ALTER TABLE contact_addresses DROP COLUMN address_folded`,
+				`ALTER TABLE contacts DROP COLUMN search_vector`,
+				`ALTER TABLE contacts ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+					to_tsvector('simple', coalesce(firstname, '') || ' ' || coalesce(lastname, '') || ' ' || coalesce(phone, ''))
+				) STORED`,
+				`CREATE INDEX idx_search_vector ON contacts USING GIN (search_vector)`,
+				`ALTER TABLE contacts DROP COLUMN lastname_folded, DROP COLUMN firstname_folded`,
+			},
+		},
+	},
+	{
+		Version:     9,
+		Description: "add contact versions",
+		Up: Statements{
+			// Every write bumps the version, it is the ETag conditional requests compare.
+			sqlite:   {`ALTER TABLE contacts ADD COLUMN version INTEGER NOT NULL DEFAULT 1`},
+			mysql:    {`ALTER TABLE contacts ADD COLUMN version BIGINT NOT NULL DEFAULT 1`},
+			postgres: {`ALTER TABLE contacts ADD COLUMN version BIGINT NOT NULL DEFAULT 1`},
+		},
+		Down: Statements{
+			sqlite:   {`ALTER TABLE contacts DROP COLUMN version`},
+			mysql:    {`ALTER TABLE contacts DROP COLUMN version`},
+			postgres: {`ALTER TABLE contacts DROP COLUMN version`},
+		},
+	},
+	{
+		Version:     10,
+		Description: "add contact timestamps",
+		Up: Statements{
+			// SQLite can't add a column defaulting to the current time, contacts stored before the
+			// timestamps were kept are stamped with the time of the migration instead.
+			sqlite: {
+				`ALTER TABLE contacts ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00'`,
+				`ALTER TABLE contacts ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00'`,
+				`ALTER TABLE contacts ADD COLUMN created_by TEXT NOT NULL DEFAULT ''`,
+				`ALTER TABLE contacts ADD COLUMN updated_by TEXT NOT NULL DEFAULT ''`,
+				`UPDATE contacts SET created_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP`,
+				`CREATE INDEX idx_contacts_created_at ON contacts(created_at)`,
+				`CREATE INDEX idx_contacts_updated_at ON contacts(updated_at)`,
+			},
+			mysql: {
+				`ALTER TABLE contacts
+					ADD COLUMN created_at DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+					ADD COLUMN updated_at DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+					ADD COLUMN created_by VARCHAR(255) NOT NULL DEFAULT '',
+					ADD COLUMN updated_by VARCHAR(255) NOT NULL DEFAULT ''`,
+				`CREATE INDEX idx_contacts_created_at ON contacts(created_at)`,
+				`CREATE INDEX idx_contacts_updated_at ON contacts(updated_at)`,
+			},
+			postgres: {
+				`ALTER TABLE contacts
+					ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+					ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+					ADD COLUMN created_by TEXT NOT NULL DEFAULT '',
+					ADD COLUMN updated_by TEXT NOT NULL DEFAULT ''`,
+				`CREATE INDEX idx_contacts_created_at ON contacts(created_at)`,
+				`CREATE INDEX idx_contacts_updated_at ON contacts(updated_at)`,
+			},
+		},
+		Down: Statements{
+			sqlite: {
+				`DROP INDEX idx_contacts_updated_at`,
+				`DROP INDEX idx_contacts_created_at`,
+				`ALTER TABLE contacts DROP COLUMN updated_by`,
+				`ALTER TABLE contacts DROP COLUMN created_by`,
+				`ALTER TABLE contacts DROP COLUMN updated_at`,
+				`ALTER TABLE contacts DROP COLUMN created_at`,
+			},
+			mysql: {
+				`DROP INDEX idx_contacts_updated_at ON contacts`,
+				`DROP INDEX idx_contacts_created_at ON contacts`,
+				`ALTER TABLE contacts DROP COLUMN updated_by, DROP COLUMN created_by, DROP COLUMN updated_at, DROP COLUMN created_at`,
+			},
+			postgres: {
+				`DROP INDEX idx_contacts_updated_at`,
+				`DROP INDEX idx_contacts_created_at`,
+				`ALTER TABLE contacts DROP COLUMN updated_by, DROP COLUMN created_by, DROP COLUMN updated_at, DROP COLUMN created_at`,
+			},
+		},
+	},
+	{
+		Version:     11,
+		Description: "rewrite smart group queries",
+		// Only the stored queries change, in Rewrite. Down leaves them in the search language, the only
+		// form the service reads.
+		Up:      Statements{sqlite: {}, mysql: {}, postgres: {}},
+		Down:    Statements{sqlite: {}, mysql: {}, postgres: {}},
+		Rewrite: rewriteSmartQueries,
+	},
+}
@@ -0,0 +1,75 @@
+// Package patch applies the two standard ways of changing part of a JSON document: a merge patch
+// (RFC 7386), a partial document where null removes a member, and a JSON Patch (RFC 6902), a list
+// of operations on the members addressed by JSON Pointers (RFC 6901).
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// The media types of the patches, as a client sends them in Content-Type.
+const (
+	MergePatchType = "application/merge-patch+json"
+	JSONPatchType  = "application/json-patch+json"
+)
+
+// Patch changes a JSON document. Apply fails when the document doesn't have the members the patch
+// expects, the patch itself was checked when it was decoded.
+type Patch interface {
+	Apply(doc []byte) ([]byte, error)
+}
+
+// Decode reads a patch of one of the media types above.
+func Decode(mediaType string, data []byte) (Patch, error) {
+	switch mediaType {
+	case MergePatchType:
+		return decodeMergePatch(data)
+	case JSONPatchType:
+		return decodeJSONPatch(data)
+	default:
+		return nil, fmt.Errorf("patch.Decode: unsupported media type %q", mediaType)
+	}
+}
+
+// MergePatch is an RFC 7386 merge patch: its members replace the document's, objects are merged
+// recursively and null removes a member.
+type MergePatch struct {
+	patch interface{}
+}
+
+func decodeMergePatch(data []byte) (MergePatch, error) {
+	var p interface{}
+	if err := json.Unmarshal(data, &p); err != nil {
+		return MergePatch{}, fmt.Errorf("patch.Decode: invalid merge patch: %w", err)
+	}
+	return MergePatch{patch: p}, nil
+}
+
+func (p MergePatch) Apply(doc []byte) ([]byte, error) {
+	var target interface{}
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, fmt.Errorf("patch.MergePatch.Apply: %w", err)
+	}
+	return json.Marshal(merge(target, p.patch))
+}
+
+func merge(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+		} else {
+			targetObject[name] = merge(targetObject[name], value)
+		}
+	}
+	return targetObject
+}
@@ -0,0 +1,85 @@
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const doc = `{"firstName": "Shayna", "address": "Herzl 1", "phones": [{"number": "050-1"}, {"number": "050-2"}], "a/b": {"c~d": 1}}`
+
+func apply(t *testing.T, mediaType, p string) (string, error) {
+	t.Helper()
+	decoded, err := Decode(mediaType, []byte(p))
+	require.NoError(t, err)
+	patched, err := decoded.Apply([]byte(doc))
+	return string(patched), err
+}
+
+func TestMergePatch(t *testing.T) {
+	patched, err := apply(t, MergePatchType, `{"lastName": "Segal", "address": null, "a/b": {"e": 2}, "phones": [{"number": "052-3"}]}`)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"firstName": "Shayna", "lastName": "Segal", "phones": [{"number": "052-3"}], "a/b": {"c~d": 1, "e": 2}}`, patched)
+
+	_, err = Decode(MergePatchType, []byte(`{`))
+	assert.Error(t, err)
+}
+
+func TestJSONPatch(t *testing.T) {
+	for name, tc := range map[string]struct {
+		patch    string
+		expected string
+	}{
+		"add and replace": {
+			`[{"op": "add", "path": "/lastName", "value": "Segal"}, {"op": "replace", "path": "/firstName", "value": "Shay"}]`,
+			`{"firstName": "Shay", "lastName": "Segal", "address": "Herzl 1", "phones": [{"number": "050-1"}, {"number": "050-2"}], "a/b": {"c~d": 1}}`,
+		},
+		"insert, append and remove array items": {
+			`[{"op": "add", "path": "/phones/0", "value": {"number": "052-0"}}, {"op": "add", "path": "/phones/-", "value": {"number": "052-9"}}, {"op": "remove", "path": "/phones/1"}]`,
+			`{"firstName": "Shayna", "address": "Herzl 1", "phones": [{"number": "052-0"}, {"number": "050-2"}, {"number": "052-9"}], "a/b": {"c~d": 1}}`,
+		},
+		"escaped pointers": {
+			`[{"op": "replace", "path": "/a~1b/c~0d", "value": 2}]`,
+			`{"firstName": "Shayna", "address": "Herzl 1", "phones": [{"number": "050-1"}, {"number": "050-2"}], "a/b": {"c~d": 2}}`,
+		},
+		"move, copy and test": {
+			`[{"op": "test", "path": "/address", "value": "Herzl 1"}, {"op": "copy", "from": "/phones/0", "path": "/phones/-"}, {"op": "move", "from": "/address", "path": "/street"}]`,
+			`{"firstName": "Shayna", "street": "Herzl 1", "phones": [{"number": "050-1"}, {"number": "050-2"}, {"number": "050-1"}], "a/b": {"c~d": 1}}`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			patched, err := apply(t, JSONPatchType, tc.patch)
+			require.NoError(t, err)
+			assert.JSONEq(t, tc.expected, patched)
+		})
+	}
+
+	t.Run("fails as a whole", func(t *testing.T) {
+		for _, p := range []string{
+			`[{"op": "replace", "path": "/firstName", "value": "Shay"}, {"op": "test", "path": "/address", "value": "Herzl 2"}]`,
+			`[{"op": "remove", "path": "/lastName"}]`,
+			`[{"op": "replace", "path": "/phones/2", "value": {}}]`,
+			`[{"op": "add", "path": "/phones/01", "value": {}}]`,
+			`[{"op": "move", "from": "/phones", "path": "/phones/0"}]`,
+		} {
+			_, err := apply(t, JSONPatchType, p)
+			assert.Error(t, err, p)
+		}
+	})
+
+	t.Run("rejects invalid operations", func(t *testing.T) {
+		for _, p := range []string{
+			`{"op": "add"}`,
+			`[{"op": "merge", "path": "/firstName"}]`,
+			`[{"op": "add", "path": "/firstName"}]`,
+			`[{"op": "remove", "path": "firstName"}]`,
+			`[{"op": "copy", "from": "phones", "path": "/x"}]`,
+		} {
+			_, err := Decode(JSONPatchType, []byte(p))
+			assert.Error(t, err, p)
+		}
+		_, err := Decode("application/json", []byte(`{}`))
+		assert.Error(t, err)
+	})
+}
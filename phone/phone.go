@@ -0,0 +1,162 @@
+package phone
+
+import (
+	"fmt"
+	"strings"
+)
+
+// region describes how numbers are dialled inside a country: its calling code, the trunk prefix
+// dropped when dialling from abroad, the international call prefix and the length of the national
+// significant number.
+type region struct {
+	callingCode string
+	trunk       string
+	intl        string
+	minLength   int
+	maxLength   int
+}
+
+// regions covers the countries we have contacts in. Numbers of other countries are still accepted
+// in international form, they are only checked against the E.164 length limits.
+var regions = map[string]region{
+	"AU": {callingCode: "61", trunk: "0", intl: "0011", minLength: 9, maxLength: 9},
+	"CA": {callingCode: "1", trunk: "1", intl: "011", minLength: 10, maxLength: 10},
+	"CH": {callingCode: "41", trunk: "0", intl: "00", minLength: 9, maxLength: 9},
+	"DE": {callingCode: "49", trunk: "0", intl: "00", minLength: 6, maxLength: 13},
+	"ES": {callingCode: "34", intl: "00", minLength: 9, maxLength: 9},
+	"FR": {callingCode: "33", trunk: "0", intl: "00", minLength: 9, maxLength: 9},
+	"GB": {callingCode: "44", trunk: "0", intl: "00", minLength: 9, maxLength: 10},
+	"IL": {callingCode: "972", trunk: "0", intl: "00", minLength: 8, maxLength: 9},
+	"IN": {callingCode: "91", trunk: "0", intl: "00", minLength: 10, maxLength: 10},
+	"IT": {callingCode: "39", intl: "00", minLength: 6, maxLength: 11},
+	"NL": {callingCode: "31", trunk: "0", intl: "00", minLength: 9, maxLength: 9},
+	"US": {callingCode: "1", trunk: "1", intl: "011", minLength: 10, maxLength: 10},
+}
+
+const (
+	minE164Digits = 8
+	maxE164Digits = 15
+)
+
+// ValidRegion reports whether numbers can be normalized for the ISO 3166-1 alpha-2 region.
+func ValidRegion(code string) bool {
+	_, ok := regions[strings.ToUpper(code)]
+	return ok
+}
+
+// Normalize returns the E.164 form of a number, e.g. "+972501234567". Numbers starting with "+"
+// or the region's international prefix are read as international, anything else as a national
+// number of the default region. Spaces, dashes, dots and parentheses are ignored.
+func Normalize(number, defaultRegion string) (string, error) {
+	digits, international, err := split(number)
+	if err != nil {
+		return "", err
+	}
+
+	r, ok := regions[strings.ToUpper(defaultRegion)]
+	if !ok {
+		return "", fmt.Errorf("phone.Normalize: unsupported region %q", defaultRegion)
+	}
+
+	if !international && r.intl != "" && strings.HasPrefix(digits, r.intl) {
+		digits, international = digits[len(r.intl):], true
+	}
+
+	if international {
+		return checkInternational(number, digits)
+	}
+
+	national := strings.TrimPrefix(digits, r.trunk)
+	if len(national) < r.minLength || len(national) > r.maxLength {
+		return "", fmt.Errorf("phone.Normalize: %q is not a valid %s number", number, strings.ToUpper(defaultRegion))
+	}
+
+	return "+" + r.callingCode + national, nil
+}
+
+// SearchDigits returns the digits of a phone-like query in the form they take inside an E.164 number,
+// so "050-123" finds "+97250123..." and "+972 50" finds it too. It returns "" when the query
+// isn't phone-like.
+func SearchDigits(query, defaultRegion string) string {
+	digits, international, err := split(query)
+	if err != nil || len(digits) < 3 {
+		return ""
+	}
+
+	r, ok := regions[strings.ToUpper(defaultRegion)]
+	if !ok || international {
+		return digits
+	}
+
+	if r.intl != "" && strings.HasPrefix(digits, r.intl) {
+		return digits[len(r.intl):]
+	}
+
+	return strings.TrimPrefix(digits, r.trunk)
+}
+
+// E164Prefix returns the start of the E.164 numbers a partial number can begin, "050*" in Israel
+// is "+97250". It returns "" when the value isn't a number.
+func E164Prefix(value, defaultRegion string) string {
+	digits, international, err := split(value)
+	if err != nil {
+		return ""
+	}
+
+	r, ok := regions[strings.ToUpper(defaultRegion)]
+	switch {
+	case international || !ok:
+		return "+" + digits
+	case r.intl != "" && strings.HasPrefix(digits, r.intl):
+		return "+" + digits[len(r.intl):]
+	default:
+		return "+" + r.callingCode + strings.TrimPrefix(digits, r.trunk)
+	}
+}
+
+// split strips the formatting from a number and reports whether it was written with a leading "+".
+func split(number string) (string, bool, error) {
+	number = strings.TrimSpace(number)
+	international := strings.HasPrefix(number, "+")
+
+	var b strings.Builder
+	for _, ch := range strings.TrimPrefix(number, "+") {
+		switch {
+		case ch >= '0' && ch <= '9':
+			b.WriteRune(ch)
+		case ch == ' ' || ch == '-' || ch == '.' || ch == '(' || ch == ')' || ch == '/':
+		default:
+			return "", false, fmt.Errorf("phone.Normalize: %q contains %q", number, ch)
+		}
+	}
+
+	if b.Len() == 0 {
+		return "", false, fmt.Errorf("phone.Normalize: %q has no digits", number)
+	}
+
+	return b.String(), international, nil
+}
+
+// checkInternational validates the length of an international number against its calling code
+// when we know the country, or against the E.164 limits otherwise.
+func checkInternational(number, digits string) (string, error) {
+	if len(digits) < minE164Digits || len(digits) > maxE164Digits {
+		return "", fmt.Errorf("phone.Normalize: %q is not a valid international number", number)
+	}
+
+	for _, r := range regions {
+		if !strings.HasPrefix(digits, r.callingCode) {
+			continue
+		}
+		national := digits[len(r.callingCode):]
+		if len(national) >= r.minLength && len(national) <= r.maxLength {
+			return "+" + digits, nil
+		}
+		// Calling codes can share a prefix with a longer one we don't know, "1" with "1264" for instance.
+		if len(r.callingCode) > 1 {
+			return "", fmt.Errorf("phone.Normalize: %q is not a valid international number", number)
+		}
+	}
+
+	return "+" + digits, nil
+}
@@ -0,0 +1,55 @@
+package phone
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	for _, tc := range []struct {
+		number, region, want string
+	}{
+		{"0501234567", "IL", "+972501234567"},
+		{"050-1234567", "IL", "+972501234567"},
+		{"+972 50 123 4567", "IL", "+972501234567"},
+		{"00972501234567", "IL", "+972501234567"},
+		{"04-8123456", "IL", "+97248123456"},
+		{"(415) 555-2671", "US", "+14155552671"},
+		{"1 415 555 2671", "us", "+14155552671"},
+		{"011 44 20 7946 0958", "US", "+442079460958"},
+		{"+44 20 7946 0958", "IL", "+442079460958"},
+		{"+886 2 2345 6789", "IL", "+886223456789"},
+	} {
+		got, err := Normalize(tc.number, tc.region)
+		require.NoError(t, err, tc.number)
+		assert.Equal(t, tc.want, got, tc.number)
+	}
+}
+
+func TestNormalize_RejectsInvalidNumbers(t *testing.T) {
+	for _, number := range []string{"", "abc", "050-12", "+972 50 123", "+1234", "12345678901234567", "0501234567 ext 2"} {
+		_, err := Normalize(number, "IL")
+		assert.Error(t, err, number)
+	}
+
+	_, err := Normalize("0501234567", "XX")
+	assert.Error(t, err)
+}
+
+func TestE164Prefix(t *testing.T) {
+	assert.Equal(t, "+97250", E164Prefix("050", "IL"))
+	assert.Equal(t, "+97250", E164Prefix("+972 50", "IL"))
+	assert.Equal(t, "+4420", E164Prefix("00 44 20", "IL"))
+	assert.Equal(t, "+1415", E164Prefix("1-415", "US"))
+	assert.Equal(t, "", E164Prefix("segal", "IL"))
+}
+
+func TestSearchDigits(t *testing.T) {
+	assert.Equal(t, "50123", SearchDigits("050-123", "IL"))
+	assert.Equal(t, "97250", SearchDigits("+972 50", "IL"))
+	assert.Equal(t, "97250", SearchDigits("00972-50", "IL"))
+	assert.Equal(t, "", SearchDigits("shayna", "IL"))
+	assert.Equal(t, "", SearchDigits("05", "IL"))
+}
@@ -0,0 +1,93 @@
+package query
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenPhrase
+	tokenField
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenMinus
+	tokenLParen
+	tokenRParen
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// lex splits a query into tokens. A known field followed by ':' is a field, the value is the next
+// token; any other word with a ':', such as 10:30, is a bare word.
+func lex(input string) ([]token, error) {
+	var tokens []token
+	pos := 0
+	for pos < len(input) {
+		r, size := utf8.DecodeRuneInString(input[pos:])
+		switch {
+		case unicode.IsSpace(r):
+			pos += size
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenLParen, text: "(", pos: pos})
+			pos++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenRParen, text: ")", pos: pos})
+			pos++
+		case r == '-' && startsTerm(tokens):
+			tokens = append(tokens, token{kind: tokenMinus, text: "-", pos: pos})
+			pos++
+		case r == '"':
+			end := strings.IndexByte(input[pos+1:], '"')
+			if end < 0 {
+				return nil, &SyntaxError{Pos: pos, Msg: "unterminated phrase"}
+			}
+			tokens = append(tokens, token{kind: tokenPhrase, text: input[pos+1 : pos+1+end], pos: pos})
+			pos += end + 2
+		default:
+			start := pos
+			for pos < len(input) {
+				r, size := utf8.DecodeRuneInString(input[pos:])
+				if unicode.IsSpace(r) || r == '(' || r == ')' || r == '"' {
+					break
+				}
+				if r == ':' && fields[input[start:pos]] {
+					break
+				}
+				pos += size
+			}
+
+			word := input[start:pos]
+			switch {
+			case pos < len(input) && input[pos] == ':':
+				tokens = append(tokens, token{kind: tokenField, text: word, pos: start})
+				pos++
+			case word == "AND":
+				tokens = append(tokens, token{kind: tokenAnd, text: word, pos: start})
+			case word == "OR":
+				tokens = append(tokens, token{kind: tokenOr, text: word, pos: start})
+			case word == "NOT":
+				tokens = append(tokens, token{kind: tokenNot, text: word, pos: start})
+			default:
+				tokens = append(tokens, token{kind: tokenWord, text: word, pos: start})
+			}
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, pos: len(input)}), nil
+}
+
+// startsTerm reports whether a '-' negates the next term. Inside a word it is kept, "on-call",
+// and so is a value starting with it, "last:-".
+func startsTerm(tokens []token) bool {
+	return len(tokens) == 0 || tokens[len(tokens)-1].kind != tokenField
+}
@@ -0,0 +1,160 @@
+package query
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Parse turns a query into its syntax tree. An empty query returns nil.
+//
+//	or      = and { "OR" and }
+//	and     = unary { ["AND"] unary }
+//	unary   = ("NOT" | "-") unary | primary
+//	primary = "(" or ")" | [field ":"] (word | phrase)
+func Parse(input string) (*Node, error) {
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokenEOF {
+		return nil, nil
+	}
+
+	n, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, &SyntaxError{Pos: t.pos, Msg: "unexpected " + describe(t)}
+	}
+
+	return n, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) or() (*Node, error) {
+	first, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+
+	children := []*Node{first}
+	for p.peek().kind == tokenOr {
+		p.next()
+		n, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, n)
+	}
+
+	return combine(OpOr, children), nil
+}
+
+func (p *parser) and() (*Node, error) {
+	first, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+
+	children := []*Node{first}
+	for {
+		switch p.peek().kind {
+		case tokenAnd:
+			p.next()
+		case tokenOr, tokenRParen, tokenEOF:
+			return combine(OpAnd, children), nil
+		}
+
+		n, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, n)
+	}
+}
+
+func (p *parser) unary() (*Node, error) {
+	t := p.peek()
+	if t.kind != tokenNot && t.kind != tokenMinus {
+		return p.primary()
+	}
+
+	p.next()
+	n, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Node{Op: OpNot, Children: []*Node{n}, Pos: t.pos}, nil
+}
+
+func (p *parser) primary() (*Node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenLParen:
+		n, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokenRParen {
+			return nil, &SyntaxError{Pos: closing.pos, Msg: "expected ) to close ( at position " + strconv.Itoa(t.pos)}
+		}
+		return n, nil
+	case tokenField:
+		value := p.next()
+		if value.kind != tokenWord && value.kind != tokenPhrase {
+			return nil, &SyntaxError{Pos: value.pos, Msg: "expected a value for " + t.text}
+		}
+		n := term(value)
+		n.Field, n.Pos = t.text, t.pos
+		return n, nil
+	case tokenWord, tokenPhrase:
+		return term(t), nil
+	default:
+		return nil, &SyntaxError{Pos: t.pos, Msg: "unexpected " + describe(t)}
+	}
+}
+
+func term(t token) *Node {
+	n := &Node{Op: OpTerm, Value: t.text, Phrase: t.kind == tokenPhrase, Pos: t.pos}
+	if !n.Phrase && len(n.Value) > 1 && strings.HasSuffix(n.Value, "*") {
+		n.Value, n.Prefix = strings.TrimSuffix(n.Value, "*"), true
+	}
+
+	return n
+}
+
+func combine(op Op, children []*Node) *Node {
+	if len(children) == 1 {
+		return children[0]
+	}
+
+	return &Node{Op: op, Children: children, Pos: children[0].Pos}
+}
+
+func describe(t token) string {
+	if t.kind == tokenEOF {
+		return "end of query"
+	}
+
+	return "'" + t.text + "'"
+}
@@ -0,0 +1,65 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	n, err := Parse(`last:segal phone:+97250* -tag:archived "exact phrase"`)
+	require.NoError(t, err)
+	assert.Equal(t, &Node{Op: OpAnd, Children: []*Node{
+		{Op: OpTerm, Field: FieldLast, Value: "segal"},
+		{Op: OpTerm, Field: FieldPhone, Value: "+97250", Prefix: true, Pos: 11},
+		{Op: OpNot, Children: []*Node{{Op: OpTerm, Field: FieldTag, Value: "archived", Pos: 26}}, Pos: 25},
+		{Op: OpTerm, Value: "exact phrase", Phrase: true, Pos: 39},
+	}}, n)
+}
+
+func TestParse_Precedence(t *testing.T) {
+	n, err := Parse(`a b OR NOT c AND (tag:on-call OR city:"Tel Aviv")`)
+	require.NoError(t, err)
+
+	assert.Equal(t, OpOr, n.Op)
+	require.Len(t, n.Children, 2)
+	assert.Equal(t, OpAnd, n.Children[0].Op)
+	right := n.Children[1]
+	assert.Equal(t, OpAnd, right.Op)
+	assert.Equal(t, OpNot, right.Children[0].Op)
+	assert.Equal(t, OpOr, right.Children[1].Op)
+	assert.Equal(t, "on-call", right.Children[1].Children[0].Value)
+	assert.Equal(t, "Tel Aviv", right.Children[1].Children[1].Value)
+}
+
+func TestParse_Empty(t *testing.T) {
+	n, err := Parse("   ")
+	require.NoError(t, err)
+	assert.Nil(t, n)
+}
+
+func TestParse_UnknownFieldIsAWord(t *testing.T) {
+	n, err := Parse(`10:30 owner:me`)
+	require.NoError(t, err)
+	assert.Equal(t, &Node{Op: OpAnd, Children: []*Node{
+		{Op: OpTerm, Value: "10:30"},
+		{Op: OpTerm, Value: "owner:me", Pos: 6},
+	}}, n)
+}
+
+func TestParse_SyntaxErrors(t *testing.T) {
+	for input, pos := range map[string]int{
+		`"open`:         0,
+		`(a OR b`:       7,
+		`a OR`:          4,
+		`a)`:            1,
+		`segal AND AND`: 10,
+		`NOT`:           3,
+	} {
+		_, err := Parse(input)
+		var syntaxErr *SyntaxError
+		require.ErrorAs(t, err, &syntaxErr, input)
+		assert.Equal(t, pos, syntaxErr.Pos, input)
+	}
+}
@@ -0,0 +1,71 @@
+// Package query parses the search language of GET /contacts, e.g.
+//
+//	last:segal phone:+97250* -tag:archived "exact phrase"
+//
+// Terms next to each other are ANDed. AND, OR and NOT (upper-case) combine terms, '-' negates
+// a single term and parentheses group. A term is a bare word, a "quoted phrase" or field:value;
+// a trailing '*' makes a value a prefix.
+package query
+
+import "fmt"
+
+type Op string
+
+const (
+	OpAnd  Op = "and"
+	OpOr   Op = "or"
+	OpNot  Op = "not"
+	OpTerm Op = "term"
+)
+
+// Fields a term can be qualified with.
+const (
+	FieldFirst      = "first"
+	FieldLast       = "last"
+	FieldName       = "name"
+	FieldPhone      = "phone"
+	FieldEmail      = "email"
+	FieldAddress    = "address"
+	FieldCity       = "city"
+	FieldRegion     = "region"
+	FieldPostalCode = "postalCode"
+	FieldCountry    = "country"
+	FieldTag        = "tag"
+)
+
+var fields = map[string]bool{
+	FieldFirst: true, FieldLast: true, FieldName: true, FieldPhone: true, FieldEmail: true, FieldAddress: true,
+	FieldCity: true, FieldRegion: true, FieldPostalCode: true, FieldCountry: true, FieldTag: true,
+}
+
+// Node is a single struct for every kind of node so a parsed query can be hashed as JSON.
+// And, Or and Not have Children; a term has a Value and, when qualified, a Field.
+// Normalized is left for the caller, e.g. the E.164 form of a phone value.
+type Node struct {
+	Op         Op      `json:"op"`
+	Children   []*Node `json:"children,omitempty"`
+	Field      string  `json:"field,omitempty"`
+	Value      string  `json:"value,omitempty"`
+	Prefix     bool    `json:"prefix,omitempty"`
+	Phrase     bool    `json:"phrase,omitempty"`
+	Normalized string  `json:"normalized,omitempty"`
+	Pos        int     `json:"-"`
+}
+
+// SyntaxError points at the offending byte of the query, Pos is 0-based.
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at position %d: %s", e.Pos, e.Msg)
+}
+
+// Walk calls fn for every node of the tree, parents first.
+func (n *Node) Walk(fn func(n *Node)) {
+	fn(n)
+	for _, child := range n.Children {
+		child.Walk(fn)
+	}
+}
@@ -0,0 +1,52 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	"github.com/ShaynaSegal45/phonebook-api/contact"
+	"github.com/ShaynaSegal45/phonebook-api/errors"
+)
+
+type legacyAddress struct {
+	contactID string
+	position  int
+	address   string
+}
+
+// UpgradeLegacyAddresses parses the free-text addresses stored before addresses were structured
+// into their street, city, region, postal code and country columns. It returns how many it upgraded
+// and is safe to run on every start, upgraded rows are never picked up again.
+func (r *ContactsRepo) UpgradeLegacyAddresses(ctx context.Context) (int, *errors.Error) {
+	errMsg := "ContactsRepo.UpgradeLegacyAddresses"
+
+	var legacy []legacyAddress
+	err := r.queryDetails(ctx, r.db, `SELECT contact_id, position, address FROM contact_addresses WHERE country_code IS NULL AND city IS NULL`, nil,
+		func(rows *sql.Rows) error {
+			var a legacyAddress
+			if err := rows.Scan(&a.contactID, &a.position, &a.address); err != nil {
+				return err
+			}
+			legacy = append(legacy, a)
+			return nil
+		})
+	if err != nil {
+		log.Printf("%s: failed to load legacy addresses: %v", errMsg, err)
+		return 0, errors.CreateError(operationName, errMsg, err, errors.InternalError)
+	}
+
+	// Empty strings mark a row as upgraded even when the parser couldn't place anything.
+	for _, l := range legacy {
+		a := contact.ParseAddress(l.address)
+		query := `UPDATE contact_addresses SET street = ?, city = ?, region = ?, postal_code = ?, country_code = ?
+			WHERE contact_id = ? AND position = ?`
+		if err := r.exec(ctx, r.db, query, a.Street, a.City, a.Region, a.PostalCode, a.CountryCode, l.contactID, l.position); err != nil {
+			log.Printf("%s: failed to upgrade address of contact id %s: %v", errMsg, l.contactID, err)
+			return 0, errors.CreateError(operationName, errMsg, err, errors.InternalError)
+		}
+		r.invalidateContact(ctx, l.contactID)
+	}
+
+	return len(legacy), nil
+}
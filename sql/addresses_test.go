@@ -0,0 +1,38 @@
+package sql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ShaynaSegal45/phonebook-api/cache"
+	"github.com/ShaynaSegal45/phonebook-api/contact"
+)
+
+func TestContactsRepo_UpgradeLegacyAddresses(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t, SQLite)
+	repo := NewContactsRepo(db, SQLite, cache.NewNoop(), DefaultCacheTTL)
+
+	_, err := db.Exec(`INSERT INTO contacts (id, firstname, lastname, address, phone) VALUES ('1', 'Shayna', 'Segal', '12 Herzl St, Haifa, Israel', '')`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO contact_addresses (contact_id, position, label, address, is_primary) VALUES ('1', 0, 'home', '12 Herzl St, Haifa, Israel', 1)`)
+	require.NoError(t, err)
+
+	upgraded, upgradeErr := repo.UpgradeLegacyAddresses(ctx)
+	require.Nil(t, upgradeErr)
+	assert.Equal(t, 1, upgraded)
+
+	c, getErr := repo.GetContact(ctx, "1")
+	require.Nil(t, getErr)
+	assert.Equal(t, []contact.Address{{
+		Label: contact.LabelHome, Address: "12 Herzl St, Haifa, Israel",
+		Street: "12 Herzl St", City: "Haifa", CountryCode: "IL", Primary: true,
+	}}, c.Addresses)
+
+	upgraded, upgradeErr = repo.UpgradeLegacyAddresses(ctx)
+	require.Nil(t, upgradeErr)
+	assert.Equal(t, 0, upgraded)
+}
@@ -0,0 +1,54 @@
+package sql
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ShaynaSegal45/phonebook-api/contact"
+)
+
+// contactCacheVersion is part of every cache key. Bump it whenever a change to contact.Contact
+// can't be read by older replicas, so old and new entries never share a key.
+const contactCacheVersion = 7
+
+// cachedContact is the cache entry for one id. NotFound entries record that the id doesn't exist.
+// ContactVersion carries the contact's version, which its JSON leaves out.
+type cachedContact struct {
+	Version        int             `json:"v"`
+	Contact        contact.Contact `json:"contact"`
+	ContactVersion int64           `json:"contactVersion,omitempty"`
+	NotFound       bool            `json:"notFound,omitempty"`
+}
+
+func contactCacheKey(id string) string {
+	return fmt.Sprintf("contact:v%d:%s", contactCacheVersion, id)
+}
+
+func encodeContact(c contact.Contact) (string, error) {
+	data, err := json.Marshal(cachedContact{Version: contactCacheVersion, Contact: c, ContactVersion: c.Version})
+	if err != nil {
+		return "", fmt.Errorf("encodeContact: %w", err)
+	}
+
+	return string(data), nil
+}
+
+func encodeNotFound() string {
+	data, _ := json.Marshal(cachedContact{Version: contactCacheVersion, NotFound: true})
+	return string(data)
+}
+
+// decodeContact reports found=false for a cached miss.
+func decodeContact(data string) (contact.Contact, bool, error) {
+	var cached cachedContact
+	if err := json.Unmarshal([]byte(data), &cached); err != nil {
+		return contact.Contact{}, false, fmt.Errorf("decodeContact: %w", err)
+	}
+
+	if cached.Version != contactCacheVersion {
+		return contact.Contact{}, false, fmt.Errorf("decodeContact: unsupported cache version %d", cached.Version)
+	}
+
+	cached.Contact.Version = cached.ContactVersion
+	return cached.Contact, !cached.NotFound, nil
+}
@@ -0,0 +1,36 @@
+package sql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ShaynaSegal45/phonebook-api/contact"
+)
+
+func TestContactCodec_RoundTrip(t *testing.T) {
+	c := contact.Contact{ID: "1", FirstName: "Shayna", LastName: "Segal", Phone: "050-1234567", Address: "12 Herzl St, Haifa, Israel", Version: 3}
+
+	encoded, err := encodeContact(c)
+	require.NoError(t, err)
+
+	decoded, found, err := decodeContact(encoded)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, c, decoded)
+
+	_, found, err = decodeContact(encodeNotFound())
+	require.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestContactCodec_RejectsLegacyAndForeignEntries(t *testing.T) {
+	for _, data := range []string{
+		"1,Shayna,Segal,12 Herzl St, Haifa,0501234567",
+		`{"v":99,"contact":{"id":"1"}}`,
+	} {
+		_, _, err := decodeContact(data)
+		assert.Error(t, err, data)
+	}
+}
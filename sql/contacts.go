@@ -5,51 +5,144 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
-	"strings"
+	"slices"
 	"time"
 
-	"github.com/go-redis/redis/v8"
+	"golang.org/x/sync/singleflight"
 
+	"github.com/ShaynaSegal45/phonebook-api/cache"
 	"github.com/ShaynaSegal45/phonebook-api/contact"
 	"github.com/ShaynaSegal45/phonebook-api/errors"
 )
 
-const (
-	operationName = "contactsmanaging"
-	ttl           = 300 * time.Second
-)
+const operationName = "contactsmanaging"
+
+// CacheTTL sets how long the repo keeps each kind of cache entry.
+type CacheTTL struct {
+	Contact  time.Duration
+	NotFound time.Duration
+	Search   time.Duration
+}
+
+var DefaultCacheTTL = CacheTTL{
+	Contact:  300 * time.Second,
+	NotFound: 30 * time.Second,
+	Search:   300 * time.Second,
+}
 
 type ContactsRepo struct {
-	db    *sql.DB
-	cache *redis.Client
+	db      *sql.DB
+	dialect Dialect
+	cache   cache.Cache
+	ttl     CacheTTL
+	loads   singleflight.Group
+	// fts is set once EnableFullTextIndex built the SQLite FTS5 index.
+	fts bool
 }
 
-func NewContactsRepo(db *sql.DB, cache *redis.Client) *ContactsRepo {
+func NewContactsRepo(db *sql.DB, dialect Dialect, c cache.Cache, ttl CacheTTL) *ContactsRepo {
 	return &ContactsRepo{
-		db:    db,
-		cache: cache,
+		db:      db,
+		dialect: dialect,
+		cache:   c,
+		ttl:     ttl,
 	}
 }
 
+// contactColumns are the columns of a contact every read selects, in the order of contactDest.
+const contactColumns = `id, firstname, lastname, address, phone, created_at, updated_at, created_by, updated_by`
+
+// contactDest is where rows.Scan reads contactColumns into.
+func contactDest(c *contact.Contact) []interface{} {
+	return []interface{}{&c.ID, &c.FirstName, &c.LastName, &c.Address, &c.Phone, &c.CreatedAt, &c.UpdatedAt, &c.CreatedBy, &c.UpdatedBy}
+}
+
+// stamp passes the time of a write to the database, NULL when it is unset so the statement falls
+// back to the current time: MySQL rejects a zero date.
+func stamp(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC()
+}
+
+// InsertContact stores c with the timestamps and actors it was stamped with.
 func (r *ContactsRepo) InsertContact(ctx context.Context, c contact.Contact) *errors.Error {
-	query := `INSERT INTO contacts (id, firstname, lastname, address, phone) VALUES (?, ?, ?, ?, ?)`
-	_, err := r.db.ExecContext(ctx, query, c.ID, c.FirstName, c.LastName, c.Address, c.Phone)
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		query := `INSERT INTO contacts (id, firstname, lastname, address, phone, created_at, updated_at, created_by, updated_by)
+			VALUES (?, ?, ?, ?, ?, COALESCE(?, CURRENT_TIMESTAMP), COALESCE(?, CURRENT_TIMESTAMP), ?, ?)`
+		args := []interface{}{c.ID, c.FirstName, c.LastName, c.Address, c.Phone, stamp(c.CreatedAt), stamp(c.UpdatedAt), c.CreatedBy, c.UpdatedBy}
+		if err := r.exec(ctx, tx, query, args...); err != nil {
+			return err
+		}
+		if err := r.writeSearchKeys(ctx, tx, c.ID); err != nil {
+			return err
+		}
+		return r.replaceDetails(ctx, tx, c)
+	})
 	if err != nil {
 		errMsg := fmt.Sprintf("ContactsRepo.InsertContact: failed to create contact with id %s", c.ID)
 		log.Printf("%s: %v", errMsg, err)
 		return errors.CreateError(operationName, errMsg, err, errors.InternalError)
 	}
 
+	r.invalidateContact(ctx, c.ID)
 	return nil
 }
 
 func (r *ContactsRepo) SearchContacts(ctx context.Context, f contact.Filters) ([]contact.Contact, *errors.Error) {
-	queryLike := `%` + f.FullText + `%`
-	sqlQuery := `SELECT id, firstname, lastname, phone, address FROM contacts WHERE ? = "" 
-                OR firstname LIKE ? OR lastname LIKE ? OR phone LIKE ?
-				ORDER BY lastname, firstname 
-                LIMIT ? OFFSET ?`
-	rows, err := r.db.QueryContext(ctx, sqlQuery, f.FullText, queryLike, queryLike, queryLike, f.Limit, f.Offset)
+	generation := r.searchGeneration(ctx)
+	if contacts, ok := r.cachedContacts(ctx, generation, searchCacheKey(generation, f)); ok {
+		return contacts, nil
+	}
+
+	contacts, err := r.searchContacts(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	r.cacheContacts(ctx, generation, searchCacheKey(generation, f), contacts)
+	return contacts, nil
+}
+
+func (r *ContactsRepo) searchContacts(ctx context.Context, f contact.Filters) ([]contact.Contact, *errors.Error) {
+	if f.Match == contact.MatchFuzzy && f.FullText != "" {
+		return r.searchFuzzy(ctx, f)
+	}
+
+	from, args, order, ranked := r.ranked(f)
+	join, joinArgs, byName := sortKeyOrder(f)
+	from += join
+	args = append(args, joinArgs...)
+	where, whereArgs := r.whereClause(f)
+	switch {
+	case f.After != nil || f.Before != nil:
+		condition, conditionArgs, pageOrder := keyset(f)
+		if condition != "" {
+			if where == "" {
+				where = ` WHERE ` + condition
+			} else {
+				where += ` AND ` + condition
+			}
+			whereArgs = append(whereArgs, conditionArgs...)
+		}
+		order = pageOrder
+	case len(f.Sort) > 0:
+		order = sortOrder(f.Sort)
+	case ranked:
+		order += `, ` + byName
+	default:
+		order = byName
+	}
+	columns := contactColumns
+	if ranked {
+		columns += `, fts_firstname, fts_lastname, fts_phones, fts_emails, fts_addresses`
+	}
+	sqlQuery := `SELECT ` + columns + ` FROM ` + from + where + `
+				ORDER BY ` + order + `
+				LIMIT ? OFFSET ?`
+	args = append(append(args, whereArgs...), f.Limit, f.Offset)
+	rows, err := r.db.QueryContext(ctx, r.dialect.rebind(sqlQuery), args...)
 	if err != nil {
 		errMsg := "ContactsRepo.SearchContacts"
 		if err == sql.ErrNoRows {
@@ -62,30 +155,81 @@ func (r *ContactsRepo) SearchContacts(ctx context.Context, f contact.Filters) ([
 	defer rows.Close()
 
 	var contacts []contact.Contact
+	var highlights []ftsHighlights
 	for rows.Next() {
 		var c contact.Contact
-		if err := rows.Scan(&c.ID, &c.FirstName, &c.LastName, &c.Address, &c.Phone); err != nil {
+		var h ftsHighlights
+		dest := contactDest(&c)
+		if ranked {
+			dest = append(dest, &h.firstName, &h.lastName, &h.phones, &h.emails, &h.addresses)
+		}
+		if err := rows.Scan(dest...); err != nil {
 			errMsg := "ContactsRepo.SearchContacts error scanning rows"
 			log.Printf("%s: failed to scan contact: %v", errMsg, err)
 			return nil, errors.CreateError(operationName, errMsg, err, errors.InternalError)
 
 		}
 		contacts = append(contacts, c)
+		highlights = append(highlights, h)
+	}
+
+	if f.Before != nil {
+		slices.Reverse(contacts)
+		slices.Reverse(highlights)
+	}
+
+	if !needsDetails(f) {
+		return contacts, nil
+	}
+	if err := r.loadDetails(ctx, r.db, contacts); err != nil {
+		errMsg := "ContactsRepo.SearchContacts"
+		log.Printf("%s: %v", errMsg, err)
+		return nil, errors.CreateError(operationName, errMsg, err, errors.InternalError)
+	}
+
+	if f.Highlight {
+		for i := range contacts {
+			contacts[i].Highlights = highlights[i].fields()
+			if len(contacts[i].Highlights) == 0 {
+				contacts[i].Highlights = highlightMatches(contacts[i], f.FullText)
+			}
+		}
 	}
 
 	return contacts, nil
 }
 
-func (r *ContactsRepo) CountContacts(ctx context.Context, query string) (int, *errors.Error) {
-	queryLike := `%` + query + `%`
-	sqlQuery := `SELECT count(id) FROM contacts WHERE ? = "" OR
-                  (firstname LIKE ? OR lastname LIKE ? OR phone LIKE ?)`
+// CountContacts counts every contact matching the filters, ignoring their order, fields and page.
+func (r *ContactsRepo) CountContacts(ctx context.Context, f contact.Filters) (int, *errors.Error) {
+	f.Locale, f.Sort, f.Fields, f.After, f.Before, f.Limit, f.Offset = "", nil, nil, nil, nil, 0, 0
+
+	generation := r.searchGeneration(ctx)
+	if count, ok := r.cachedCount(ctx, generation, f); ok {
+		return count, nil
+	}
+
+	count, err := r.countContacts(ctx, f)
+	if err != nil {
+		return 0, err
+	}
+
+	r.cacheCount(ctx, generation, f, count)
+	return count, nil
+}
+
+func (r *ContactsRepo) countContacts(ctx context.Context, f contact.Filters) (int, *errors.Error) {
+	if f.Match == contact.MatchFuzzy && f.FullText != "" {
+		return r.countFuzzy(ctx, f)
+	}
+
+	where, args := r.whereClause(f)
+	sqlQuery := `SELECT count(id) FROM contacts` + where
 
 	var count int
-	err := r.db.QueryRowContext(ctx, sqlQuery, query, queryLike, queryLike, queryLike).Scan(&count)
+	err := r.db.QueryRowContext(ctx, r.dialect.rebind(sqlQuery), args...).Scan(&count)
 	if err != nil {
 		errMsg := "ContactsRepo.CountContacts"
-		log.Printf("%s: failed to count contacts with query %s: %v", errMsg, query, err)
+		log.Printf("%s: failed to count contacts with query %s: %v", errMsg, f.FullText, err)
 		return 0, errors.CreateError(operationName, errMsg, err, errors.InternalError)
 
 	}
@@ -93,36 +237,100 @@ func (r *ContactsRepo) CountContacts(ctx context.Context, query string) (int, *e
 	return count, nil
 }
 
-func (r *ContactsRepo) UpdateContact(ctx context.Context, c contact.Contact) *errors.Error {
-	query, args := buildUpdateQuery(c)
-
-	_, err := r.db.ExecContext(ctx, query, args...)
+// UpdateContact replaces every column and collection of a stored contact with c's: an empty
+// field clears the stored one and a nil collection empties it. When c.Version is set the update
+// only applies to that version, and fails with a PreconditionFailedError once another write
+// replaced it. c.UpdatedAt and c.UpdatedBy stamp the update, the creation stamps are kept. It
+// returns the contact's new version.
+func (r *ContactsRepo) UpdateContact(ctx context.Context, c contact.Contact) (int64, *errors.Error) {
+	c.Phones = emptyIfNil(c.Phones)
+	c.Emails = emptyIfNil(c.Emails)
+	c.Addresses = emptyIfNil(c.Addresses)
+
+	errMsg := "ContactsRepo.UpdateContact"
+	var version int64
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		query := `UPDATE contacts SET firstname = ?, lastname = ?, address = ?, phone = ?, updated_at = COALESCE(?, CURRENT_TIMESTAMP),
+			updated_by = ?, version = version + 1 WHERE id = ?`
+		args := []interface{}{c.FirstName, c.LastName, c.Address, c.Phone, stamp(c.UpdatedAt), c.UpdatedBy, c.ID}
+		if c.Version != 0 {
+			query += ` AND version = ?`
+			args = append(args, c.Version)
+		}
+		if err := r.execOne(ctx, tx, query, args...); err != nil {
+			return err
+		}
+		if err := tx.QueryRowContext(ctx, r.dialect.rebind(`SELECT version FROM contacts WHERE id = ?`), c.ID).Scan(&version); err != nil {
+			return err
+		}
+		if err := r.writeSearchKeys(ctx, tx, c.ID); err != nil {
+			return err
+		}
+		return r.replaceDetails(ctx, tx, c)
+	})
+	if err == sql.ErrNoRows {
+		return 0, r.missedWrite(ctx, errMsg, c.ID, c.Version)
+	}
 	if err != nil {
-		errMsg := "ContactsRepo.UpdateContact"
 		log.Printf("%s: failed to update contact with id %s: %v", errMsg, c.ID, err)
-		return errors.CreateError("UpdateContact", errMsg, err, errors.InternalError)
+		return 0, errors.CreateError("UpdateContact", errMsg, err, errors.InternalError)
 	}
 
-	return nil
+	r.invalidateContact(ctx, c.ID)
+	return version, nil
+}
+
+func emptyIfNil[T any](items []T) []T {
+	if items == nil {
+		return []T{}
+	}
+	return items
 }
 
-func (r *ContactsRepo) DeleteContact(ctx context.Context, id string) *errors.Error {
-	query := `DELETE FROM contacts WHERE id = ?`
-	_, err := r.db.ExecContext(ctx, query, id)
+// DeleteContact deletes a contact and its collections. When version is set only that version is
+// deleted, as UpdateContact updates it.
+func (r *ContactsRepo) DeleteContact(ctx context.Context, id string, version int64) *errors.Error {
+	errMsg := "ContactsRepo.DeleteContact"
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		if version != 0 {
+			if err := r.execOne(ctx, tx, `DELETE FROM contacts WHERE id = ? AND version = ?`, id, version); err != nil {
+				return err
+			}
+		} else if err := r.exec(ctx, tx, `DELETE FROM contacts WHERE id = ?`, id); err != nil {
+			return err
+		}
+		return r.deleteDetails(ctx, tx, id)
+	})
+	if err == sql.ErrNoRows {
+		return r.missedWrite(ctx, errMsg, id, version)
+	}
 	if err != nil {
-		errMsg := "ContactsRepo.DeleteContact"
 		log.Printf("%s: failed to delete contact with id %s: %v", errMsg, id, err)
 		return errors.CreateError(operationName, errMsg, err, errors.InternalError)
 
 	}
 
+	r.invalidateContact(ctx, id)
 	return nil
 }
 
+// missedWrite explains a write that found no row to change: the contact is gone, or it expected a
+// version that another write already replaced.
+func (r *ContactsRepo) missedWrite(ctx context.Context, errMsg, id string, version int64) *errors.Error {
+	r.invalidateContact(ctx, id)
+	if version == 0 {
+		return errors.CreateError(operationName, errMsg, fmt.Errorf("contact with id %s not found", id), errors.NotFoundError)
+	}
+
+	err := fmt.Errorf("contact with id %s is no longer at version %d", id, version)
+	log.Printf("%s: %v", errMsg, err)
+	return errors.CreateError(operationName, errMsg, err, errors.PreconditionFailedError)
+}
+
 func (r *ContactsRepo) ContactExists(ctx context.Context, firstName, lastName string) (bool, *errors.Error) {
-	query := `SELECT 1 FROM contacts WHERE firstName = ? AND lastName = ?`
+	query := `SELECT 1 FROM contacts WHERE firstname = ? AND lastname = ?`
 	var exists int
-	err := r.db.QueryRowContext(ctx, query, firstName, lastName).Scan(&exists)
+	err := r.db.QueryRowContext(ctx, r.dialect.rebind(query), firstName, lastName).Scan(&exists)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return false, nil
@@ -135,73 +343,118 @@ func (r *ContactsRepo) ContactExists(ctx context.Context, firstName, lastName st
 	return true, nil
 }
 
-func buildUpdateQuery(c contact.Contact) (string, []interface{}) {
-	query := `UPDATE contacts SET`
-	var args []interface{}
-
-	if c.FirstName != "" {
-		query += ` firstname = ?,`
-		args = append(args, c.FirstName)
-	}
-	if c.LastName != "" {
-		query += ` lastname = ?,`
-		args = append(args, c.LastName)
-	}
-	if c.Address != "" {
-		query += ` address = ?,`
-		args = append(args, c.Address)
+func (r *ContactsRepo) GetContact(ctx context.Context, id string) (contact.Contact, *errors.Error) {
+	cachedContact, err := r.cache.Get(ctx, contactCacheKey(id))
+	if err == nil {
+		c, found, decodeErr := decodeContact(cachedContact)
+		if decodeErr == nil {
+			if !found {
+				errMsg := fmt.Sprintf("ContactsRepo.GetContact: failed to get contact with id %s", id)
+				return contact.Contact{}, errors.CreateError(operationName, errMsg, sql.ErrNoRows, errors.NotFoundError)
+			}
+			return c, nil
+		}
+		log.Printf("ContactsRepo.GetContact: dropping unreadable cache entry for contact id %s: %v", id, decodeErr)
+	} else if err != cache.ErrMiss {
+		return contact.Contact{}, errors.CreateError(operationName, "failed to get cache", err, errors.InternalError)
 	}
-	if c.Phone != "" {
-		query += ` phone = ?,`
-		args = append(args, c.Phone)
+
+	// Concurrent misses for the same id share a single database read. The read outlives
+	// the first caller's cancellation because the other callers are waiting on it too.
+	result, _, _ := r.loads.Do(id, func() (interface{}, error) {
+		c, err := r.loadContact(context.WithoutCancel(ctx), id)
+		return loadedContact{contact: c, err: err}, nil
+	})
+
+	loaded := result.(loadedContact)
+	if loaded.err != nil {
+		// Every caller wraps the error on its way up, so each needs its own copy.
+		errCopy := *loaded.err
+		errCopy.Wrapper = append([]string(nil), loaded.err.Wrapper...)
+		return contact.Contact{}, &errCopy
 	}
 
-	query = query[:len(query)-1] + ` WHERE id = ?`
-	args = append(args, c.ID)
+	return loaded.contact, nil
+}
 
-	return query, args
+// GetCurrentContact reads a contact from the database past the cache, for the conditional requests
+// a stale cached version would answer wrongly. The cache is refreshed with what it reads.
+func (r *ContactsRepo) GetCurrentContact(ctx context.Context, id string) (contact.Contact, *errors.Error) {
+	return r.loadContact(ctx, id)
 }
 
-func (r *ContactsRepo) GetContact(ctx context.Context, id string) (contact.Contact, *errors.Error) {
-	cachedContact, err := r.cache.Get(ctx, id).Result()
-	if err == redis.Nil {
+type loadedContact struct {
+	contact contact.Contact
+	err     *errors.Error
+}
 
-		var c contact.Contact
-		query := `SELECT id, firstname, lastname, address, phone FROM contacts WHERE id = ?`
-		err = r.db.QueryRowContext(ctx, query, id).Scan(&c.ID, &c.FirstName, &c.LastName, &c.Address, &c.Phone)
-		if err != nil {
-			errMsg := fmt.Sprintf("ContactsRepo.GetContact: failed to get contact with id %s", id)
-			if err == sql.ErrNoRows {
-				log.Printf("%s: contact not found", errMsg)
-				return contact.Contact{}, errors.CreateError(operationName, errMsg, err, errors.NotFoundError)
-			}
-			log.Printf("%s: %v", errMsg, err)
-			return contact.Contact{}, errors.CreateError(operationName, errMsg, err, errors.InternalError)
+// loadContact reads a contact from the database and caches the outcome, including a miss.
+func (r *ContactsRepo) loadContact(ctx context.Context, id string) (contact.Contact, *errors.Error) {
+	var c contact.Contact
+	query := `SELECT ` + contactColumns + `, version FROM contacts WHERE id = ?`
+	err := r.db.QueryRowContext(ctx, r.dialect.rebind(query), id).Scan(append(contactDest(&c), &c.Version)...)
+	if err != nil {
+		errMsg := fmt.Sprintf("ContactsRepo.GetContact: failed to get contact with id %s", id)
+		if err == sql.ErrNoRows {
+			log.Printf("%s: contact not found", errMsg)
+			r.cacheContact(ctx, id, encodeNotFound(), nil, r.ttl.NotFound)
+			return contact.Contact{}, errors.CreateError(operationName, errMsg, err, errors.NotFoundError)
 		}
+		log.Printf("%s: %v", errMsg, err)
+		return contact.Contact{}, errors.CreateError(operationName, errMsg, err, errors.InternalError)
+	}
 
-		err = r.cache.Set(ctx, id, fmt.Sprintf("%s,%s,%s,%s,%s", c.ID, c.FirstName, c.LastName, c.Address, c.Phone), ttl).Err()
-		if err != nil {
-			log.Printf("Failed to set cache for contact id %s: %v", id, err)
-		}
+	contacts := []contact.Contact{c}
+	if err := r.loadDetails(ctx, r.db, contacts); err != nil {
+		errMsg := fmt.Sprintf("ContactsRepo.GetContact: failed to get details of contact with id %s", id)
+		log.Printf("%s: %v", errMsg, err)
+		return contact.Contact{}, errors.CreateError(operationName, errMsg, err, errors.InternalError)
+	}
+	c = contacts[0]
 
+	encoded, err := encodeContact(c)
+	if err != nil {
+		log.Printf("Failed to encode contact id %s for cache: %v", id, err)
 		return c, nil
+	}
+	r.cacheContact(ctx, id, encoded, &c.Version, r.ttl.Contact)
 
-	} else if err != nil {
-		return contact.Contact{}, errors.CreateError(operationName, "failed to get cache", err, errors.InternalError)
+	return c, nil
+}
+
+// cacheContact caches a loaded contact, or its absence when version is nil. A write that commits
+// while the load is in flight may invalidate the entry before it is set, so the stored version is
+// read again after the set, and the entry deleted when it no longer matches; a write committing
+// after that read invalidates the entry itself.
+func (r *ContactsRepo) cacheContact(ctx context.Context, id, encoded string, version *int64, ttl time.Duration) {
+	if err := r.cache.Set(ctx, contactCacheKey(id), encoded, ttl); err != nil {
+		log.Printf("Failed to set cache for contact id %s: %v", id, err)
+		return
 	}
 
-	contact := DeserializeContact(cachedContact)
-	return contact, nil
+	var stored int64
+	err := r.db.QueryRowContext(ctx, r.dialect.rebind(`SELECT version FROM contacts WHERE id = ?`), id).Scan(&stored)
+	switch {
+	case err == sql.ErrNoRows && version == nil, err == nil && version != nil && stored == *version:
+		return
+	case err != nil && err != sql.ErrNoRows:
+		log.Printf("Failed to check the cached version of contact id %s: %v", id, err)
+	}
+	if err := r.cache.Delete(ctx, contactCacheKey(id)); err != nil {
+		log.Printf("Failed to drop the stale cache of contact id %s: %v", id, err)
+	}
 }
 
-func DeserializeContact(data string) contact.Contact {
+func (r *ContactsRepo) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-	fields := strings.Split(data, ",")
-	return contact.Contact{
-		ID:        fields[0],
-		FirstName: fields[1],
-		LastName:  fields[2],
-		Address:   fields[3],
-		Phone:     fields[4],
+	if err := fn(tx); err != nil {
+		return err
 	}
+
+	return tx.Commit()
 }
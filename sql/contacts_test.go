@@ -0,0 +1,301 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ShaynaSegal45/phonebook-api/cache"
+	"github.com/ShaynaSegal45/phonebook-api/contact"
+	"github.com/ShaynaSegal45/phonebook-api/errors"
+	"github.com/ShaynaSegal45/phonebook-api/migrations"
+)
+
+// openTestDB returns a fresh database for the dialect. SQLite runs in memory,
+// the server-backed dialects only run when their DSN is provided through the environment.
+func openTestDB(t *testing.T, dialect Dialect) *sql.DB {
+	t.Helper()
+
+	driver, dsn := dialect.Name(), ":memory:"
+	if dialect != SQLite {
+		env := "PHONEBOOK_TEST_" + strings.ToUpper(dialect.Name()) + "_DSN"
+		dsn = os.Getenv(env)
+		if dsn == "" {
+			t.Skipf("%s not set", env)
+		}
+	}
+
+	db, err := sql.Open(driver, dsn)
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	migrator := migrations.New(db, dialect.Name())
+	t.Cleanup(func() {
+		migrator.Down(context.Background(), len(migrations.All()))
+		db.Close()
+	})
+
+	_, err = migrator.Up(context.Background())
+	require.NoError(t, err)
+	return db
+}
+
+func TestContactsRepo(t *testing.T) {
+	for _, dialect := range []Dialect{SQLite, MySQL, Postgres} {
+		t.Run(dialect.Name(), func(t *testing.T) {
+			repo := NewContactsRepo(openTestDB(t, dialect), dialect, cache.NewNoop(), DefaultCacheTTL)
+			testContactsRepo(t, repo)
+		})
+	}
+}
+
+func testContactsRepo(t *testing.T, repo *ContactsRepo) {
+	ctx := context.Background()
+	created := time.Date(2026, time.October, 1, 8, 0, 0, 0, time.UTC)
+	contacts := []contact.Contact{
+		{
+			ID: "1", FirstName: "Shayna", LastName: "Segal",
+			Phones:    []contact.Phone{{Label: contact.LabelMobile, Number: "0501234567", Primary: true}, {Label: contact.LabelWork, Number: "048123456"}},
+			Emails:    []contact.Email{{Label: contact.LabelWork, Email: "shayna@example.com", Primary: true}},
+			Addresses: []contact.Address{{Label: contact.LabelHome, Address: "12 Herzl St, Haifa 3303123, Israel", Primary: true}},
+			Groups:    []string{},
+		},
+		{
+			ID: "2", FirstName: "John", LastName: "Doe",
+			Phones:    []contact.Phone{{Label: contact.LabelMobile, Number: "0529876543", Primary: true}},
+			Emails:    []contact.Email{},
+			Addresses: []contact.Address{{Label: contact.LabelWork, Street: "1 Main St", City: "Springfield", Region: "IL", CountryCode: "us", Primary: true}},
+			Groups:    []string{},
+		},
+		{
+			ID: "3", FirstName: "Jane", LastName: "Doe",
+			Phones:    []contact.Phone{{Label: contact.LabelMobile, Number: "0530000000", Primary: true}},
+			Emails:    []contact.Email{},
+			Addresses: []contact.Address{},
+			Groups:    []string{},
+		},
+	}
+	for i := range contacts {
+		contacts[i].CreatedAt, contacts[i].CreatedBy = created.Add(time.Duration(i)*time.Hour), "shayna"
+		contacts[i].UpdatedAt, contacts[i].UpdatedBy = contacts[i].CreatedAt, "shayna"
+		contacts[i].Normalize()
+		require.Nil(t, repo.InsertContact(ctx, contacts[i]))
+	}
+
+	t.Run("get returns every collection", func(t *testing.T) {
+		c, err := repo.GetContact(ctx, "1")
+		require.Nil(t, err)
+		expected := contacts[0]
+		expected.Version = 1
+		assert.Equal(t, expected, c)
+	})
+
+	t.Run("search orders by last name and paginates", func(t *testing.T) {
+		found, err := repo.SearchContacts(ctx, contact.Filters{Limit: 2})
+		require.Nil(t, err)
+		assert.Equal(t, []contact.Contact{contacts[2], contacts[1]}, found)
+
+		found, err = repo.SearchContacts(ctx, contact.Filters{Limit: 2, Offset: 2})
+		require.Nil(t, err)
+		assert.Equal(t, []contact.Contact{contacts[0]}, found)
+	})
+
+	t.Run("search and count match name, phones, emails and addresses", func(t *testing.T) {
+		found, err := repo.SearchContacts(ctx, contact.Filters{FullText: "doe", Limit: 10})
+		require.Nil(t, err)
+		assert.Len(t, found, 2)
+
+		for _, query := range []string{"0501", "048123", "shayna@example", "herzl"} {
+			count, err := repo.CountContacts(ctx, contact.Filters{FullText: query})
+			require.Nil(t, err)
+			assert.Equal(t, 1, count, query)
+		}
+
+		for _, query := range []string{"%", "0_01"} {
+			count, err := repo.CountContacts(ctx, contact.Filters{FullText: query})
+			require.Nil(t, err)
+			assert.Zero(t, count, "%q is not a wildcard", query)
+		}
+
+		count, err := repo.CountContacts(ctx, contact.Filters{})
+		require.Nil(t, err)
+		assert.Equal(t, 3, count)
+	})
+
+	t.Run("address filters match a single address", func(t *testing.T) {
+		for _, tc := range []struct {
+			filters contact.Filters
+			ids     []string
+		}{
+			{contact.Filters{City: "haifa"}, []string{"1"}},
+			{contact.Filters{Country: "IL"}, []string{"1"}},
+			{contact.Filters{Country: "israel"}, []string{"1"}},
+			{contact.Filters{Region: "il", Country: "US"}, []string{"2"}},
+			{contact.Filters{PostalCode: "3303123"}, []string{"1"}},
+			{contact.Filters{City: "Springfield", Country: "IL"}, nil},
+			{contact.Filters{FullText: "doe", Country: "us"}, []string{"2"}},
+		} {
+			tc.filters.Limit = 10
+			found, err := repo.SearchContacts(ctx, tc.filters)
+			require.Nil(t, err)
+			var ids []string
+			for _, c := range found {
+				ids = append(ids, c.ID)
+			}
+			assert.Equal(t, tc.ids, ids, "%+v", tc.filters)
+
+			count, err := repo.CountContacts(ctx, tc.filters)
+			require.Nil(t, err)
+			assert.Equal(t, len(tc.ids), count, "%+v", tc.filters)
+		}
+	})
+
+	t.Run("contact exists", func(t *testing.T) {
+		exists, err := repo.ContactExists(ctx, "John", "Doe")
+		require.Nil(t, err)
+		assert.True(t, exists)
+
+		exists, err = repo.ContactExists(ctx, "John", "Smith")
+		require.Nil(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("update replaces every field and collection", func(t *testing.T) {
+		update := contact.Contact{ID: "2", FirstName: "John", Phones: []contact.Phone{{Label: contact.LabelHome, Number: "0521111111"}},
+			UpdatedAt: created.Add(24 * time.Hour), UpdatedBy: "sync-job"}
+		update.Normalize()
+		_, updateErr := repo.UpdateContact(ctx, update)
+		require.Nil(t, updateErr)
+
+		c, err := repo.GetContact(ctx, "2")
+		require.Nil(t, err)
+		assert.Equal(t, "John", c.FirstName)
+		assert.Empty(t, c.LastName)
+		assert.Equal(t, "0521111111", c.Phone)
+		assert.Equal(t, []contact.Phone{{Label: contact.LabelHome, Number: "0521111111", Primary: true}}, c.Phones)
+		assert.Empty(t, c.Address)
+		assert.Empty(t, c.Addresses)
+		assert.True(t, c.CreatedAt.Equal(contacts[1].CreatedAt), "the creation is kept")
+		assert.Equal(t, "shayna", c.CreatedBy)
+		assert.True(t, c.UpdatedAt.Equal(update.UpdatedAt))
+		assert.Equal(t, "sync-job", c.UpdatedBy)
+	})
+
+	t.Run("filters by update and creation time", func(t *testing.T) {
+		for _, tc := range []struct {
+			filters contact.Filters
+			ids     []string
+		}{
+			{contact.Filters{UpdatedSince: created.Add(time.Hour)}, []string{"2", "3"}},
+			{contact.Filters{UpdatedSince: created.Add(2 * time.Hour).In(time.FixedZone("IDT", 3*60*60))}, []string{"2", "3"}},
+			{contact.Filters{UpdatedSince: created.Add(3 * time.Hour)}, []string{"2"}},
+			{contact.Filters{CreatedBefore: created.Add(time.Hour)}, []string{"1"}},
+			{contact.Filters{UpdatedSince: created.Add(time.Hour), CreatedBefore: created.Add(2 * time.Hour)}, []string{"2"}},
+			{contact.Filters{Sort: []contact.SortField{{Field: contact.SortUpdatedAt, Desc: true}}}, []string{"2", "3", "1"}},
+		} {
+			tc.filters.Limit = 10
+			found, err := repo.SearchContacts(ctx, tc.filters)
+			require.Nil(t, err)
+			var ids []string
+			for _, c := range found {
+				ids = append(ids, c.ID)
+			}
+			assert.Equal(t, tc.ids, ids, "%+v", tc.filters)
+
+			count, err := repo.CountContacts(ctx, tc.filters)
+			require.Nil(t, err)
+			assert.Equal(t, len(tc.ids), count, "%+v", tc.filters)
+		}
+	})
+
+	t.Run("delete removes the contact and its collections", func(t *testing.T) {
+		require.Nil(t, repo.DeleteContact(ctx, "1", 0))
+
+		count, err := repo.CountContacts(ctx, contact.Filters{})
+		require.Nil(t, err)
+		assert.Equal(t, 2, count)
+
+		count, err = repo.CountContacts(ctx, contact.Filters{FullText: "shayna@example"})
+		require.Nil(t, err)
+		assert.Equal(t, 0, count)
+	})
+}
+
+func TestPostgresRebind(t *testing.T) {
+	query := Postgres.rebind(`SELECT id FROM contacts WHERE firstname = ? AND address = '?' LIMIT ? OFFSET ?`)
+	assert.Equal(t, `SELECT id FROM contacts WHERE firstname = $1 AND address = '?' LIMIT $2 OFFSET $3`, query)
+}
+
+func TestPrefixTSQuery(t *testing.T) {
+	assert.Equal(t, "shay:* & seg:*", prefixTSQuery("Shay seg"))
+	assert.Equal(t, "o:* & brien:*", prefixTSQuery("o'brien & !"))
+	assert.Equal(t, "", prefixTSQuery("&|!"))
+}
+
+func TestContactsRepo_Versions(t *testing.T) {
+	ctx := context.Background()
+	repo := NewContactsRepo(openTestDB(t, SQLite), SQLite, cache.NewLRU(10), DefaultCacheTTL)
+	require.Nil(t, repo.InsertContact(ctx, contact.Contact{ID: "1", FirstName: "Shayna", LastName: "Segal"}))
+	require.Nil(t, repo.InsertGroup(ctx, contact.Group{ID: "g1", Name: "family"}))
+
+	version := func() int64 {
+		t.Helper()
+		c, err := repo.GetContact(ctx, "1")
+		require.Nil(t, err)
+		return c.Version
+	}
+	assert.Equal(t, int64(1), version())
+	assert.Equal(t, int64(1), version(), "the cached copy keeps the version")
+
+	updated, err := repo.UpdateContact(ctx, contact.Contact{ID: "1", FirstName: "Shayna", LastName: "Cohen"})
+	require.Nil(t, err)
+	assert.Equal(t, int64(2), updated)
+	assert.Equal(t, int64(2), version())
+
+	t.Run("a write expecting a replaced version fails", func(t *testing.T) {
+		_, err := repo.UpdateContact(ctx, contact.Contact{ID: "1", LastName: "Levi", Version: 1})
+		require.NotNil(t, err)
+		assert.Equal(t, errors.PreconditionFailedError, err.StatusCode)
+		c, _ := repo.GetContact(ctx, "1")
+		assert.Equal(t, "Cohen", c.LastName)
+
+		err = repo.DeleteContact(ctx, "1", 1)
+		require.NotNil(t, err)
+		assert.Equal(t, errors.PreconditionFailedError, err.StatusCode)
+
+		updated, err := repo.UpdateContact(ctx, contact.Contact{ID: "1", LastName: "Levi", Version: 2})
+		require.Nil(t, err)
+		assert.Equal(t, int64(3), updated)
+
+		_, err = repo.UpdateContact(ctx, contact.Contact{ID: "2", LastName: "Levi"})
+		require.NotNil(t, err)
+		assert.Equal(t, errors.NotFoundError, err.StatusCode)
+	})
+
+	t.Run("group changes bump the version", func(t *testing.T) {
+		change := contact.Change{At: time.Date(2026, time.October, 17, 2, 0, 0, 0, time.UTC), By: "sync-job"}
+		require.Nil(t, repo.AddGroupMember(ctx, "g1", "1", change))
+		assert.Equal(t, int64(4), version())
+		c, err := repo.GetContact(ctx, "1")
+		require.Nil(t, err)
+		assert.True(t, c.UpdatedAt.Equal(change.At), "the change stamps the contact")
+		assert.Equal(t, "sync-job", c.UpdatedBy)
+		require.Nil(t, repo.UpdateGroup(ctx, contact.Group{ID: "g1", Name: "relatives"}, contact.Change{}))
+		assert.Equal(t, int64(5), version())
+		require.Nil(t, repo.RemoveGroupMember(ctx, "g1", "1", contact.Change{}))
+		assert.Equal(t, int64(6), version())
+	})
+
+	require.Nil(t, repo.DeleteContact(ctx, "1", 6))
+	_, err = repo.GetContact(ctx, "1")
+	require.NotNil(t, err)
+	assert.Equal(t, errors.NotFoundError, err.StatusCode)
+}
@@ -0,0 +1,191 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/ShaynaSegal45/phonebook-api/collation"
+	"github.com/ShaynaSegal45/phonebook-api/contact"
+)
+
+// A contact's phones, emails and addresses live in child tables keyed by (contact_id, position),
+// position keeping the order the client sent them in. Group membership is loaded alongside them.
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// replaceDetails rewrites every collection of c that is not nil. A nil collection is left untouched,
+// an empty one is cleared.
+func (r *ContactsRepo) replaceDetails(ctx context.Context, tx execer, c contact.Contact) error {
+	if c.Phones != nil {
+		if err := r.exec(ctx, tx, `DELETE FROM contact_phones WHERE contact_id = ?`, c.ID); err != nil {
+			return err
+		}
+		for i, p := range c.Phones {
+			query := `INSERT INTO contact_phones (contact_id, position, label, number, e164, is_primary) VALUES (?, ?, ?, ?, ?, ?)`
+			if err := r.exec(ctx, tx, query, c.ID, i, p.Label, p.Number, p.E164, p.Primary); err != nil {
+				return err
+			}
+		}
+	}
+
+	if c.Emails != nil {
+		if err := r.exec(ctx, tx, `DELETE FROM contact_emails WHERE contact_id = ?`, c.ID); err != nil {
+			return err
+		}
+		for i, e := range c.Emails {
+			query := `INSERT INTO contact_emails (contact_id, position, label, email, is_primary) VALUES (?, ?, ?, ?, ?)`
+			if err := r.exec(ctx, tx, query, c.ID, i, e.Label, e.Email, e.Primary); err != nil {
+				return err
+			}
+		}
+	}
+
+	if c.Addresses != nil {
+		if err := r.exec(ctx, tx, `DELETE FROM contact_addresses WHERE contact_id = ?`, c.ID); err != nil {
+			return err
+		}
+		for i, a := range c.Addresses {
+			query := `INSERT INTO contact_addresses (contact_id, position, label, address, address_folded, street, city, region, postal_code, country_code, is_primary)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+			err := r.exec(ctx, tx, query, c.ID, i, a.Label, a.Address, collation.Fold(a.Address), a.Street, a.City, a.Region, a.PostalCode, a.CountryCode, a.Primary)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *ContactsRepo) deleteDetails(ctx context.Context, tx execer, id string) error {
+	for _, table := range []string{"contact_phones", "contact_emails", "contact_addresses", "contact_group_members", "contact_sort_keys"} {
+		if err := r.exec(ctx, tx, `DELETE FROM `+table+` WHERE contact_id = ?`, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loadDetails fills the collections of every contact with one query per child table.
+func (r *ContactsRepo) loadDetails(ctx context.Context, db queryer, contacts []contact.Contact) error {
+	if len(contacts) == 0 {
+		return nil
+	}
+
+	byID := make(map[string]*contact.Contact, len(contacts))
+	ids := make([]interface{}, 0, len(contacts))
+	for i := range contacts {
+		c := &contacts[i]
+		c.Phones, c.Emails, c.Addresses, c.Groups = []contact.Phone{}, []contact.Email{}, []contact.Address{}, []string{}
+		byID[c.ID] = c
+		ids = append(ids, c.ID)
+	}
+	in := placeholders(len(ids))
+
+	err := r.queryDetails(ctx, db, `SELECT contact_id, label, number, COALESCE(e164, ''), is_primary
+		FROM contact_phones WHERE contact_id IN `+in+` ORDER BY contact_id, position`, ids,
+		func(rows *sql.Rows) error {
+			var id string
+			var p contact.Phone
+			if err := rows.Scan(&id, &p.Label, &p.Number, &p.E164, &p.Primary); err != nil {
+				return err
+			}
+			byID[id].Phones = append(byID[id].Phones, p)
+			return nil
+		})
+	if err != nil {
+		return fmt.Errorf("failed to load phones: %w", err)
+	}
+
+	err = r.queryDetails(ctx, db, `SELECT contact_id, label, email, is_primary FROM contact_emails WHERE contact_id IN `+in+` ORDER BY contact_id, position`, ids,
+		func(rows *sql.Rows) error {
+			var id string
+			var e contact.Email
+			if err := rows.Scan(&id, &e.Label, &e.Email, &e.Primary); err != nil {
+				return err
+			}
+			byID[id].Emails = append(byID[id].Emails, e)
+			return nil
+		})
+	if err != nil {
+		return fmt.Errorf("failed to load emails: %w", err)
+	}
+
+	// Rows written before the address was structured may still hold NULLs until they are upgraded,
+	// the same goes for phones without an E.164 number above.
+	err = r.queryDetails(ctx, db, `SELECT contact_id, label, address, COALESCE(street, ''), COALESCE(city, ''), COALESCE(region, ''),
+			COALESCE(postal_code, ''), COALESCE(country_code, ''), is_primary
+		FROM contact_addresses WHERE contact_id IN `+in+` ORDER BY contact_id, position`, ids,
+		func(rows *sql.Rows) error {
+			var id string
+			var a contact.Address
+			if err := rows.Scan(&id, &a.Label, &a.Address, &a.Street, &a.City, &a.Region, &a.PostalCode, &a.CountryCode, &a.Primary); err != nil {
+				return err
+			}
+			byID[id].Addresses = append(byID[id].Addresses, a)
+			return nil
+		})
+	if err != nil {
+		return fmt.Errorf("failed to load addresses: %w", err)
+	}
+
+	err = r.queryDetails(ctx, db, `SELECT m.contact_id, g.name FROM contact_group_members m JOIN contact_groups g ON g.id = m.group_id
+		WHERE m.contact_id IN `+in+` ORDER BY m.contact_id, g.name`, ids,
+		func(rows *sql.Rows) error {
+			var id, name string
+			if err := rows.Scan(&id, &name); err != nil {
+				return err
+			}
+			byID[id].Groups = append(byID[id].Groups, name)
+			return nil
+		})
+	if err != nil {
+		return fmt.Errorf("failed to load groups: %w", err)
+	}
+
+	return nil
+}
+
+// queryDetails runs a child table query and hands every row to scan.
+func (r *ContactsRepo) queryDetails(ctx context.Context, db queryer, query string, args []interface{}, scan func(rows *sql.Rows) error) error {
+	rows, err := db.QueryContext(ctx, r.dialect.rebind(query), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (r *ContactsRepo) exec(ctx context.Context, tx execer, query string, args ...interface{}) error {
+	_, err := tx.ExecContext(ctx, r.dialect.rebind(query), args...)
+	return err
+}
+
+// execOne runs a write that must change a row, and returns sql.ErrNoRows when it changed none.
+func (r *ContactsRepo) execOne(ctx context.Context, tx execer, query string, args ...interface{}) error {
+	result, err := tx.ExecContext(ctx, r.dialect.rebind(query), args...)
+	if err != nil {
+		return err
+	}
+	if changed, err := result.RowsAffected(); err != nil {
+		return err
+	} else if changed == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
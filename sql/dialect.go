@@ -0,0 +1,65 @@
+package sql
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/ShaynaSegal45/phonebook-api/config"
+)
+
+// Dialect hides the differences between the SQL backends ContactsRepo can run on.
+// Queries are written once with `?` placeholders and rebound per dialect.
+type Dialect interface {
+	Name() string
+	rebind(query string) string
+	fullTextCondition(query string) (string, []interface{})
+	// caseInsensitiveLike matches column against a `?` pattern escaped with likeEscape.
+	caseInsensitiveLike(column string) string
+}
+
+var (
+	SQLite   Dialect = sqliteDialect{}
+	MySQL    Dialect = mysqlDialect{}
+	Postgres Dialect = postgresDialect{}
+)
+
+func DialectFor(adapter string) (Dialect, error) {
+	switch adapter {
+	case config.AdapterSQLite:
+		return SQLite, nil
+	case config.AdapterMySQL:
+		return MySQL, nil
+	case config.AdapterPostgres:
+		return Postgres, nil
+	default:
+		return nil, fmt.Errorf("sql.DialectFor: unsupported adapter %q", adapter)
+	}
+}
+
+// searchWords splits free text into its words, dropping punctuation and any operators the caller typed.
+func searchWords(query string) []string {
+	return strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// likeEscape escapes the wildcards of a value, so the patterns built around it are taken literally.
+func likeEscape(value string) string {
+	return strings.NewReplacer(`!`, `!!`, `%`, `!%`, `_`, `!_`).Replace(value)
+}
+
+// likeCondition matches the query, folded by the repo, as a substring of the folded name or address,
+// or of any phone or email; an empty query matches every contact. A '%' or '_' in it is taken literally.
+func likeCondition(query string) (string, []interface{}) {
+	if query == "" {
+		return "", nil
+	}
+
+	queryLike := `%` + likeEscape(query) + `%`
+	return `(firstname_folded LIKE ? ESCAPE '!' OR lastname_folded LIKE ? ESCAPE '!' OR phone LIKE ? ESCAPE '!'
+			OR id IN (SELECT contact_id FROM contact_phones WHERE number LIKE ? ESCAPE '!')
+			OR id IN (SELECT contact_id FROM contact_emails WHERE email LIKE ? ESCAPE '!')
+			OR id IN (SELECT contact_id FROM contact_addresses WHERE address_folded LIKE ? ESCAPE '!'))`,
+		[]interface{}{queryLike, queryLike, queryLike, queryLike, queryLike, queryLike}
+}
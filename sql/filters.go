@@ -0,0 +1,153 @@
+package sql
+
+import (
+	"strings"
+
+	"github.com/ShaynaSegal45/phonebook-api/contact"
+)
+
+// whereClause combines the full-text condition and the search query with the address, tag and time filters. All address filters must
+// match the same address, so "city=Haifa&country=IL" doesn't find a contact with a home in Haifa,
+// Florida and an office in Tel Aviv.
+func (r *ContactsRepo) whereClause(f contact.Filters) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if condition, conditionArgs := r.textCondition(f); condition != "" {
+		conditions = append(conditions, condition)
+		args = append(args, conditionArgs...)
+	}
+
+	if f.Query != nil {
+		condition, queryArgs := r.compileQuery(f.Query)
+		conditions = append(conditions, condition)
+		args = append(args, queryArgs...)
+	}
+
+	var addressConditions []string
+	for _, filter := range []struct{ condition, value string }{
+		{`lower(city) = ?`, strings.ToLower(strings.TrimSpace(f.City))},
+		{`lower(region) = ?`, strings.ToLower(strings.TrimSpace(f.Region))},
+		{`postal_code = ?`, strings.ToUpper(strings.TrimSpace(f.PostalCode))},
+		{`country_code = ?`, countryFilter(f.Country)},
+	} {
+		if filter.value != "" {
+			addressConditions = append(addressConditions, filter.condition)
+			args = append(args, filter.value)
+		}
+	}
+	if len(addressConditions) > 0 {
+		conditions = append(conditions, `id IN (SELECT contact_id FROM contact_addresses WHERE `+strings.Join(addressConditions, ` AND `)+`)`)
+	}
+
+	if tags := groupNames(f.AnyTags); len(tags) > 0 {
+		conditions = append(conditions, `id IN (SELECT m.contact_id FROM contact_group_members m JOIN contact_groups g ON g.id = m.group_id
+			WHERE g.name IN `+placeholders(len(tags))+`)`)
+		args = append(args, tags...)
+	}
+
+	if tags := groupNames(f.AllTags); len(tags) > 0 {
+		conditions = append(conditions, `id IN (SELECT m.contact_id FROM contact_group_members m JOIN contact_groups g ON g.id = m.group_id
+			WHERE g.name IN `+placeholders(len(tags))+` GROUP BY m.contact_id HAVING COUNT(*) = ?)`)
+		args = append(args, tags...)
+		args = append(args, len(tags))
+	}
+
+	if !f.UpdatedSince.IsZero() {
+		conditions = append(conditions, `updated_at >= ?`)
+		args = append(args, f.UpdatedSince.UTC())
+	}
+	if !f.CreatedBefore.IsZero() {
+		conditions = append(conditions, `created_at < ?`)
+		args = append(args, f.CreatedBefore.UTC())
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+
+	return ` WHERE ` + strings.Join(conditions, ` AND `), args
+}
+
+// textCondition matches the fullText, or the E.164 numbers containing its PhoneDigits.
+func (r *ContactsRepo) textCondition(f contact.Filters) (string, []interface{}) {
+	condition, args := r.fullTextCondition(f.FullText)
+	if condition != "" && f.PhoneDigits != "" {
+		condition = `(` + condition + ` OR id IN (SELECT contact_id FROM contact_phones WHERE e164 LIKE ?))`
+		args = append(args, `%`+f.PhoneDigits+`%`)
+	}
+
+	return condition, args
+}
+
+// groupNames normalizes tag filters and drops duplicates, so "all of" can compare counts.
+func groupNames(tags []string) []interface{} {
+	seen := make(map[string]bool, len(tags))
+	var names []interface{}
+	for _, tag := range tags {
+		name := contact.NormalizeGroupName(tag)
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+func placeholders(n int) string {
+	return `(` + strings.TrimSuffix(strings.Repeat("?, ", n), ", ") + `)`
+}
+
+// countryFilter accepts a code or a country name. An unknown country is kept as typed so it matches nothing.
+func countryFilter(country string) string {
+	country = strings.TrimSpace(country)
+	if code := contact.CountryCode(country); code != "" {
+		return code
+	}
+
+	return country
+}
+
+// sortColumns are the columns behind the sortable fields. Names sort by their folded form, so case
+// and accents don't matter to the order.
+var sortColumns = map[string]string{
+	contact.SortID:        `id`,
+	contact.SortFirstName: `firstname_folded`,
+	contact.SortLastName:  `lastname_folded`,
+	contact.SortCreatedAt: `created_at`,
+	contact.SortUpdatedAt: `updated_at`,
+}
+
+// sortOrder turns a requested sort into an ORDER BY list, ending with the id so pages are stable.
+func sortOrder(sort []contact.SortField) string {
+	order := make([]string, 0, len(sort)+1)
+	byID := false
+	for _, s := range sort {
+		column := sortColumns[s.Field]
+		byID = byID || column == `id`
+		if s.Desc {
+			column += ` DESC`
+		}
+		order = append(order, column)
+	}
+	if !byID {
+		order = append(order, `id`)
+	}
+
+	return strings.Join(order, `, `)
+}
+
+// needsDetails tells whether a search must load the phones, emails, addresses and groups, which
+// it can skip when none of them is among the fields returned.
+func needsDetails(f contact.Filters) bool {
+	if f.Highlight {
+		return true
+	}
+	for _, field := range []string{"phones", "emails", "addresses", "groups"} {
+		if contact.HasField(f.Fields, field) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package sql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ShaynaSegal45/phonebook-api/cache"
+	"github.com/ShaynaSegal45/phonebook-api/contact"
+)
+
+func TestContactsRepo_SortAndFields(t *testing.T) {
+	ctx := context.Background()
+	repo := NewContactsRepo(openTestDB(t, SQLite), SQLite, cache.NewNoop(), DefaultCacheTTL)
+
+	for _, c := range []contact.Contact{
+		{ID: "1", FirstName: "Shayna", LastName: "Segal", Phone: "050-1234567",
+			Phones: []contact.Phone{{Label: contact.LabelMobile, Number: "050-1234567", E164: "+972501234567", Primary: true}}},
+		{ID: "2", FirstName: "avi", LastName: "Segal"},
+		{ID: "3", FirstName: "Éli", LastName: "Cohen"},
+	} {
+		require.Nil(t, repo.InsertContact(ctx, c))
+	}
+
+	search := func(f contact.Filters) []contact.Contact {
+		t.Helper()
+		f.Limit = 10
+		found, err := repo.SearchContacts(ctx, f)
+		require.Nil(t, err)
+		return found
+	}
+	ids := func(contacts []contact.Contact) []string {
+		ids := []string{}
+		for _, c := range contacts {
+			ids = append(ids, c.ID)
+		}
+		return ids
+	}
+
+	t.Run("orders by the requested fields", func(t *testing.T) {
+		assert.Equal(t, []string{"3", "2", "1"}, ids(search(contact.Filters{})))
+		assert.Equal(t, []string{"2", "3", "1"}, ids(search(contact.Filters{Sort: []contact.SortField{{Field: contact.SortFirstName}}})))
+		assert.Equal(t, []string{"2", "1", "3"}, ids(search(contact.Filters{Sort: []contact.SortField{
+			{Field: contact.SortLastName, Desc: true}, {Field: contact.SortFirstName},
+		}})))
+		assert.Equal(t, []string{"1", "2"}, ids(search(contact.Filters{FullText: "segal", Sort: []contact.SortField{{Field: contact.SortID}}})))
+		assert.Equal(t, []string{"2", "1"}, ids(search(contact.Filters{FullText: "segal", Match: contact.MatchFuzzy,
+			Sort: []contact.SortField{{Field: contact.SortFirstName}}})))
+	})
+
+	t.Run("skips the details no field needs", func(t *testing.T) {
+		found := search(contact.Filters{FullText: "shayna", Fields: []string{"id", "phone"}})
+		require.Len(t, found, 1)
+		assert.Equal(t, "050-1234567", found[0].Phone)
+		assert.Nil(t, found[0].Phones)
+
+		found = search(contact.Filters{FullText: "shayna", Fields: []string{"id", "phones"}})
+		require.Len(t, found, 1)
+		assert.Len(t, found[0].Phones, 1)
+	})
+}
@@ -0,0 +1,174 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/ShaynaSegal45/phonebook-api/collation"
+	"github.com/ShaynaSegal45/phonebook-api/config"
+	"github.com/ShaynaSegal45/phonebook-api/contact"
+)
+
+// contacts_fts indexes every contact's name, phones, emails and addresses in one FTS5 row. It is
+// keyed by contact_id rather than rowid, since VACUUM may renumber the rowids of contacts.
+const (
+	ftsCreateTable = `CREATE VIRTUAL TABLE IF NOT EXISTS contacts_fts USING fts5(
+		contact_id UNINDEXED, firstname, lastname, phones, emails, addresses,
+		tokenize = 'unicode61 remove_diacritics 2')`
+
+	ftsRows = `INSERT INTO contacts_fts (contact_id, firstname, lastname, phones, emails, addresses)
+		SELECT c.id, c.firstname, c.lastname,
+			(SELECT group_concat(number || ' ' || coalesce(e164, ''), ' ') FROM contact_phones WHERE contact_id = c.id),
+			(SELECT group_concat(email, ' ') FROM contact_emails WHERE contact_id = c.id),
+			(SELECT group_concat(address, ' ') FROM contact_addresses WHERE contact_id = c.id)
+		FROM contacts c`
+
+	// bm25 weights of contact_id, firstname, lastname, phones, emails and addresses.
+	ftsRank = `bm25(contacts_fts, 0.0, 10.0, 10.0, 5.0, 2.0, 1.0)`
+
+	highlightOpen  = `<mark>`
+	highlightClose = `</mark>`
+
+	// The FTS5 index marks matches with control characters rather than tags, since it returns the
+	// text unescaped; ftsHighlights.fields escapes the text before turning them into tags.
+	ftsMarkOpen  = "\x02"
+	ftsMarkClose = "\x03"
+)
+
+// ftsTriggers keep contacts_fts in sync with contacts and their child tables. Any write
+// rebuilds the contact's row from scratch.
+func ftsTriggers() []string {
+	refresh := func(id string) string {
+		return `DELETE FROM contacts_fts WHERE contact_id = ` + id + `;
+			` + ftsRows + ` WHERE c.id = ` + id + `;`
+	}
+
+	triggers := []string{
+		`CREATE TRIGGER IF NOT EXISTS contacts_fts_insert AFTER INSERT ON contacts BEGIN ` + refresh(`NEW.id`) + ` END`,
+		`CREATE TRIGGER IF NOT EXISTS contacts_fts_update AFTER UPDATE ON contacts BEGIN ` + refresh(`NEW.id`) + ` END`,
+		`CREATE TRIGGER IF NOT EXISTS contacts_fts_delete AFTER DELETE ON contacts BEGIN
+			DELETE FROM contacts_fts WHERE contact_id = OLD.id; END`,
+	}
+	for _, table := range []string{"contact_phones", "contact_emails", "contact_addresses"} {
+		triggers = append(triggers,
+			`CREATE TRIGGER IF NOT EXISTS `+table+`_fts_insert AFTER INSERT ON `+table+` BEGIN `+refresh(`NEW.contact_id`)+` END`,
+			`CREATE TRIGGER IF NOT EXISTS `+table+`_fts_update AFTER UPDATE ON `+table+` BEGIN `+refresh(`NEW.contact_id`)+` END`,
+			`CREATE TRIGGER IF NOT EXISTS `+table+`_fts_delete AFTER DELETE ON `+table+` BEGIN `+refresh(`OLD.contact_id`)+` END`,
+		)
+	}
+
+	return triggers
+}
+
+// EnableFullTextIndex creates and fills the SQLite FTS5 index, and switches the fullText search to it:
+// words match as prefixes and results are ranked with BM25. The index isn't a migration because
+// FTS5 is only compiled into go-sqlite3 with the sqlite_fts5 build tag; without it, or on other
+// backends, it returns false and the search keeps its dialect's condition.
+//
+// A binary without FTS5 drops the triggers a binary with it left behind, as they would fail every
+// write with "no such module: fts5". The table itself can't be dropped without the module, so it is
+// refilled the next time the index is enabled.
+func (r *ContactsRepo) EnableFullTextIndex(ctx context.Context) (bool, error) {
+	if r.dialect.Name() != config.AdapterSQLite {
+		return false, nil
+	}
+
+	var available bool
+	if err := r.db.QueryRowContext(ctx, `SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&available); err != nil {
+		return false, fmt.Errorf("ContactsRepo.EnableFullTextIndex: %w", err)
+	}
+	if !available {
+		err := r.inTx(ctx, func(tx *sql.Tx) error { return r.dropFullTextTriggers(ctx, tx) })
+		if err != nil {
+			return false, fmt.Errorf("ContactsRepo.EnableFullTextIndex: %w", err)
+		}
+		return false, nil
+	}
+
+	// Without its triggers the index, if any, missed the writes made since it was last enabled.
+	var synced int
+	query := `SELECT count(*) FROM sqlite_master WHERE type = 'trigger' AND name = 'contacts_fts_insert'`
+	if err := r.db.QueryRowContext(ctx, query).Scan(&synced); err != nil {
+		return false, fmt.Errorf("ContactsRepo.EnableFullTextIndex: %w", err)
+	}
+
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		for _, statement := range append([]string{ftsCreateTable}, ftsTriggers()...) {
+			if err := r.exec(ctx, tx, statement); err != nil {
+				return err
+			}
+		}
+		if synced == 0 {
+			if err := r.exec(ctx, tx, `DELETE FROM contacts_fts`); err != nil {
+				return err
+			}
+			return r.exec(ctx, tx, ftsRows)
+		}
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("ContactsRepo.EnableFullTextIndex: %w", err)
+	}
+
+	r.fts = true
+	return true, nil
+}
+
+func (r *ContactsRepo) dropFullTextTriggers(ctx context.Context, tx *sql.Tx) error {
+	for _, table := range []string{"contacts", "contact_phones", "contact_emails", "contact_addresses"} {
+		for _, event := range []string{"insert", "update", "delete"} {
+			if err := r.exec(ctx, tx, `DROP TRIGGER IF EXISTS `+table+`_fts_`+event); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// fullTextCondition uses the FTS5 index once it is enabled, and the dialect's condition over the
+// folded columns otherwise. The FTS5 tokenizer folds case and diacritics itself.
+func (r *ContactsRepo) fullTextCondition(query string) (string, []interface{}) {
+	if !r.fts || query == "" {
+		return r.dialect.fullTextCondition(collation.Fold(query))
+	}
+
+	match := ftsMatch(query)
+	if match == "" {
+		return `1 = 0`, nil
+	}
+
+	return `id IN (SELECT contact_id FROM contacts_fts WHERE contacts_fts MATCH ?)`, []interface{}{match}
+}
+
+// ranked joins the BM25 rank and the highlighted fields of the contacts matching the fullText,
+// when the FTS5 index is enabled. Contacts matched another way, e.g. by their E.164 number, sort last.
+func (r *ContactsRepo) ranked(f contact.Filters) (from string, args []interface{}, order string, ok bool) {
+	match := ftsMatch(f.FullText)
+	if !r.fts || match == "" {
+		return `contacts`, nil, ``, false
+	}
+
+	from = `contacts LEFT JOIN (SELECT contact_id AS fts_id, ` + ftsRank + ` AS fts_rank,
+			highlight(contacts_fts, 1, '` + ftsMarkOpen + `', '` + ftsMarkClose + `') AS fts_firstname,
+			highlight(contacts_fts, 2, '` + ftsMarkOpen + `', '` + ftsMarkClose + `') AS fts_lastname,
+			snippet(contacts_fts, 3, '` + ftsMarkOpen + `', '` + ftsMarkClose + `', '…', 8) AS fts_phones,
+			snippet(contacts_fts, 4, '` + ftsMarkOpen + `', '` + ftsMarkClose + `', '…', 8) AS fts_emails,
+			snippet(contacts_fts, 5, '` + ftsMarkOpen + `', '` + ftsMarkClose + `', '…', 8) AS fts_addresses
+		FROM contacts_fts WHERE contacts_fts MATCH ?) fts ON fts.fts_id = contacts.id`
+
+	return from, []interface{}{match}, `fts_rank IS NULL, fts_rank`, true
+}
+
+// ftsMatch turns free text into an FTS5 query matching every word as a prefix, e.g. `"shay"* "seg"*`.
+func ftsMatch(query string) string {
+	words := searchWords(query)
+	terms := make([]string, 0, len(words))
+	for _, w := range words {
+		terms = append(terms, `"`+w+`"*`)
+	}
+
+	return strings.Join(terms, " ")
+}
@@ -0,0 +1,184 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ShaynaSegal45/phonebook-api/cache"
+	"github.com/ShaynaSegal45/phonebook-api/contact"
+)
+
+// Run with -tags sqlite_fts5 to cover the FTS5 index.
+func TestContactsRepo_FullTextIndex(t *testing.T) {
+	ctx := context.Background()
+	repo := NewContactsRepo(openTestDB(t, SQLite), SQLite, cache.NewNoop(), DefaultCacheTTL)
+
+	// Contacts written before the index exists are indexed when it is built.
+	require.Nil(t, repo.InsertContact(ctx, contact.Contact{ID: "1", FirstName: "Segal", LastName: "Aharon"}))
+
+	enabled, err := repo.EnableFullTextIndex(ctx)
+	require.NoError(t, err)
+	if !enabled {
+		t.Skip("go-sqlite3 was built without the sqlite_fts5 tag")
+	}
+
+	require.Nil(t, repo.InsertContact(ctx, contact.Contact{ID: "2", FirstName: "Shayna", LastName: "Segal",
+		Phones: []contact.Phone{{Number: "050-1234567", E164: "+972501234567", Primary: true}}}))
+	require.Nil(t, repo.InsertContact(ctx, contact.Contact{ID: "3", FirstName: "Dan", LastName: "Cohen",
+		Addresses: []contact.Address{{Address: "7 Segal St, Haifa", Primary: true}}}))
+	require.Nil(t, repo.InsertContact(ctx, contact.Contact{ID: "4", FirstName: "Tzipi", LastName: "Levi"}))
+
+	search := func(f contact.Filters) []contact.Contact {
+		f.Limit = 10
+		found, err := repo.SearchContacts(ctx, f)
+		require.Nil(t, err)
+		return found
+	}
+	ids := func(text string) []string {
+		var ids []string
+		for _, c := range search(contact.Filters{FullText: text}) {
+			ids = append(ids, c.ID)
+		}
+		return ids
+	}
+
+	t.Run("ranks name matches over address matches", func(t *testing.T) {
+		assert.Equal(t, []string{"1", "2", "3"}, ids("segal"))
+	})
+
+	t.Run("matches words as prefixes", func(t *testing.T) {
+		assert.Equal(t, []string{"2"}, ids("shay seg"))
+		assert.Equal(t, []string{"2"}, ids("97250"))
+		assert.Empty(t, ids("hayna"))
+	})
+
+	t.Run("highlights the matches", func(t *testing.T) {
+		found := search(contact.Filters{FullText: "shay", Highlight: true})
+		require.Len(t, found, 1)
+		assert.Equal(t, map[string]string{"firstName": "<mark>Shayna</mark>"}, found[0].Highlights)
+
+		found = search(contact.Filters{FullText: "haifa"})
+		require.Len(t, found, 1)
+		assert.Nil(t, found[0].Highlights)
+	})
+
+	t.Run("escapes the highlighted fields", func(t *testing.T) {
+		require.Nil(t, repo.InsertContact(ctx, contact.Contact{ID: "6", FirstName: "<script>Ben", LastName: "Tom & Jerry"}))
+		defer repo.DeleteContact(ctx, "6", 0)
+
+		found := search(contact.Filters{FullText: "ben jerry", Highlight: true})
+		require.Len(t, found, 1)
+		assert.Equal(t, map[string]string{
+			"firstName": "&lt;script&gt;<mark>Ben</mark>",
+			"lastName":  "Tom &amp; <mark>Jerry</mark>",
+		}, found[0].Highlights)
+	})
+
+	t.Run("triggers keep the index in sync", func(t *testing.T) {
+		_, updateErr := repo.UpdateContact(ctx, contact.Contact{ID: "4", LastName: "Livni",
+			Emails: []contact.Email{{Email: "tzipi@example.com", Primary: true}}})
+		require.Nil(t, updateErr)
+		assert.Equal(t, []string{"4"}, ids("livni"))
+		assert.Equal(t, []string{"4"}, ids("tzipi@example"))
+		assert.Empty(t, ids("levi"))
+
+		require.Nil(t, repo.DeleteContact(ctx, "2", 0))
+		assert.Empty(t, ids("shayna"))
+		var indexed int
+		require.NoError(t, repo.db.QueryRow(`SELECT count(*) FROM contacts_fts`).Scan(&indexed))
+		assert.Equal(t, 3, indexed)
+	})
+
+	t.Run("refills the index a binary without FTS5 stopped syncing", func(t *testing.T) {
+		require.NoError(t, repo.inTx(ctx, func(tx *sql.Tx) error { return repo.dropFullTextTriggers(ctx, tx) }))
+		require.Nil(t, repo.InsertContact(ctx, contact.Contact{ID: "5", FirstName: "Golda", LastName: "Meir"}))
+		assert.Empty(t, ids("golda"))
+
+		enabled, err := repo.EnableFullTextIndex(ctx)
+		require.NoError(t, err)
+		require.True(t, enabled)
+		assert.Equal(t, []string{"5"}, ids("golda"))
+		assert.Equal(t, []string{"4"}, ids("livni"))
+	})
+}
+
+func TestHighlightMatches(t *testing.T) {
+	c := contact.Contact{
+		FirstName: "Shayna",
+		LastName:  "Segal",
+		Phones:    []contact.Phone{{Number: "050-1234567"}, {Number: "03-7654321"}},
+		Addresses: []contact.Address{{Address: "7 Segal St, Haifa"}},
+	}
+
+	assert.Equal(t, map[string]string{
+		"lastName":  "<mark>Segal</mark>",
+		"phones":    "<mark>050</mark>-1234567",
+		"addresses": "7 <mark>Segal</mark> St, Haifa",
+	}, highlightMatches(c, "SEGAL 050"))
+	assert.Equal(t, map[string]string{
+		"firstName": "&lt;script&gt;<mark>Ben</mark>",
+		"lastName":  "Tom &amp; <mark>Jerry</mark> &lt;b&gt;",
+	}, highlightMatches(contact.Contact{FirstName: "<script>Ben", LastName: "Tom & Jerry <b>"}, "ben jerry amp"))
+	assert.Empty(t, highlightMatches(c, "cohen"))
+	assert.Nil(t, highlightMatches(c, "  "))
+
+	assert.Equal(t, map[string]string{
+		"firstName": "<mark>José</mark>",
+		"lastName":  "<mark>Ñúñez</mark>-Straße",
+	}, highlightMatches(contact.Contact{FirstName: "José", LastName: "Ñúñez-Straße"}, "jose NUNEZ"))
+	assert.Equal(t, map[string]string{
+		"firstName": "<mark>Jose\u0301</mark> &amp; <mark>JOSÉ</mark>",
+		"lastName":  "<mark>Straß</mark>e",
+	}, highlightMatches(contact.Contact{FirstName: "Jose\u0301 & JOSÉ", LastName: "Straße"}, "josé strass"))
+}
+
+// Run without -tags sqlite_fts5: a database a binary with FTS5 indexed stays writable.
+func TestContactsRepo_FullTextIndexWithoutFTS5(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t, SQLite)
+	repo := NewContactsRepo(db, SQLite, cache.NewNoop(), DefaultCacheTTL)
+
+	var available bool
+	require.NoError(t, db.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&available))
+	if available {
+		t.Skip("go-sqlite3 was built with the sqlite_fts5 tag")
+	}
+
+	// Leave the schema a binary with FTS5 would: the virtual table, which only the module can
+	// create, is written into sqlite_master directly, and the schema reloaded.
+	_, err := db.Exec(`PRAGMA writable_schema = ON`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO sqlite_master (type, name, tbl_name, rootpage, sql) VALUES ('table', 'contacts_fts', 'contacts_fts', 0, ?)`,
+		strings.Replace(ftsCreateTable, "IF NOT EXISTS ", "", 1))
+	require.NoError(t, err)
+	var version int
+	require.NoError(t, db.QueryRow(`PRAGMA schema_version`).Scan(&version))
+	_, err = db.Exec(fmt.Sprintf(`PRAGMA schema_version = %d`, version+1))
+	require.NoError(t, err)
+	_, err = db.Exec(`PRAGMA writable_schema = OFF`)
+	require.NoError(t, err)
+	for _, trigger := range ftsTriggers() {
+		_, err = db.Exec(trigger)
+		require.NoError(t, err)
+	}
+	require.ErrorContains(t, repo.InsertContact(ctx, contact.Contact{ID: "1", FirstName: "Shayna"}), "fts5")
+
+	enabled, err := repo.EnableFullTextIndex(ctx)
+	require.NoError(t, err)
+	assert.False(t, enabled)
+
+	require.Nil(t, repo.InsertContact(ctx, contact.Contact{ID: "1", FirstName: "Shayna", LastName: "Segal",
+		Phones: []contact.Phone{{Number: "050-1234567", Primary: true}}}))
+	_, updateErr := repo.UpdateContact(ctx, contact.Contact{ID: "1", FirstName: "Shay"})
+	require.Nil(t, updateErr)
+	found, searchErr := repo.SearchContacts(ctx, contact.Filters{FullText: "shay", Limit: 10})
+	require.Nil(t, searchErr)
+	require.Len(t, found, 1)
+	require.Nil(t, repo.DeleteContact(ctx, "1", 0))
+}
@@ -0,0 +1,184 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ShaynaSegal45/phonebook-api/collation"
+	"github.com/ShaynaSegal45/phonebook-api/contact"
+	"github.com/ShaynaSegal45/phonebook-api/errors"
+	"github.com/ShaynaSegal45/phonebook-api/fuzzy"
+)
+
+// minFuzzyScore drops the candidates that only share their first sound with the query.
+const minFuzzyScore = 0.6
+
+type fuzzyHit struct {
+	id, firstName, lastName string
+	createdAt, updatedAt    time.Time
+	score                   float64
+}
+
+// fuzzyHits returns every contact matching the filters whose name is spelled like the fullText, best
+// first. The candidates are the regular fullText matches, which are always kept, and the contacts with
+// a name key starting with the same sound as a word of the query and about as long, found through the
+// key indexes. Edit distance can't be computed in SQL on every backend, so they are scored here.
+func (r *ContactsRepo) fuzzyHits(ctx context.Context, f contact.Filters) ([]fuzzyHit, error) {
+	exact, exactArgs := r.textCondition(f)
+	candidates := []string{exact}
+	candidateArgs := append([]interface{}{}, exactArgs...)
+	for _, word := range fuzzy.Words(f.FullText) {
+		key := fuzzy.Key(word)
+		if key == "" {
+			continue
+		}
+		for _, column := range []string{`firstname_key`, `lastname_key`} {
+			candidates = append(candidates, `(`+column+` >= ? AND `+column+` < ? AND length(`+column+`) BETWEEN ? AND ?)`)
+			candidateArgs = append(candidateArgs, key[:1], string(key[0]+1), len(key)-1, len(key)+1)
+		}
+	}
+
+	text := f.FullText
+	f.FullText, f.PhoneDigits = "", ""
+	where, whereArgs := r.whereClause(f)
+	if where == "" {
+		where = ` WHERE `
+	} else {
+		where += ` AND `
+	}
+	query := `SELECT id, firstname, lastname, created_at, updated_at, ` + exact + ` FROM contacts` + where + `(` + strings.Join(candidates, ` OR `) + `)`
+	args := append(append(exactArgs, whereArgs...), candidateArgs...)
+
+	var hits []fuzzyHit
+	err := r.queryDetails(ctx, r.db, query, args, func(rows *sql.Rows) error {
+		var h fuzzyHit
+		var firstName, lastName sql.NullString
+		var matched bool
+		if err := rows.Scan(&h.id, &firstName, &lastName, &h.createdAt, &h.updatedAt, &matched); err != nil {
+			return err
+		}
+		h.firstName, h.lastName = firstName.String, lastName.String
+		h.score = fuzzy.Score(text, h.firstName, h.lastName)
+		if matched || h.score >= minFuzzyScore {
+			hits = append(hits, h)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		a, b := hits[i], hits[j]
+		if len(f.Sort) > 0 {
+			return sortedBefore(f.Sort, a, b)
+		}
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		if a.lastName != b.lastName {
+			return a.lastName < b.lastName
+		}
+		if a.firstName != b.firstName {
+			return a.firstName < b.firstName
+		}
+		return a.id < b.id
+	})
+
+	return hits, nil
+}
+
+// sortedBefore orders two hits by a requested sort the way sortOrder does in SQL.
+func sortedBefore(sort []contact.SortField, a, b fuzzyHit) bool {
+	for _, s := range sort {
+		var order int
+		switch s.Field {
+		case contact.SortFirstName:
+			order = strings.Compare(collation.Fold(a.firstName), collation.Fold(b.firstName))
+		case contact.SortLastName:
+			order = strings.Compare(collation.Fold(a.lastName), collation.Fold(b.lastName))
+		case contact.SortCreatedAt:
+			order = a.createdAt.Compare(b.createdAt)
+		case contact.SortUpdatedAt:
+			order = a.updatedAt.Compare(b.updatedAt)
+		default:
+			order = strings.Compare(a.id, b.id)
+		}
+		if order != 0 {
+			return order < 0 != s.Desc
+		}
+	}
+	return a.id < b.id
+}
+
+func (r *ContactsRepo) searchFuzzy(ctx context.Context, f contact.Filters) ([]contact.Contact, *errors.Error) {
+	errMsg := "ContactsRepo.SearchContacts"
+	hits, err := r.fuzzyHits(ctx, f)
+	if err != nil {
+		log.Printf("%s: failed to fuzzy search contacts with query %s: %v", errMsg, f.FullText, err)
+		return nil, errors.CreateError(operationName, errMsg, err, errors.InternalError)
+	}
+
+	hits = hits[min(f.Offset, len(hits)):min(f.Offset+f.Limit, len(hits))]
+	if len(hits) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]interface{}, len(hits))
+	for i, h := range hits {
+		ids[i] = h.id
+	}
+	found := make(map[string]contact.Contact, len(hits))
+	query := `SELECT ` + contactColumns + ` FROM contacts WHERE id IN ` + placeholders(len(ids))
+	err = r.queryDetails(ctx, r.db, query, ids, func(rows *sql.Rows) error {
+		var c contact.Contact
+		if err := rows.Scan(contactDest(&c)...); err != nil {
+			return err
+		}
+		found[c.ID] = c
+		return nil
+	})
+	if err != nil {
+		log.Printf("%s: failed to load fuzzy matches: %v", errMsg, err)
+		return nil, errors.CreateError(operationName, errMsg, err, errors.InternalError)
+	}
+
+	contacts := make([]contact.Contact, 0, len(hits))
+	for _, h := range hits {
+		if c, ok := found[h.id]; ok {
+			c.Score = h.score
+			contacts = append(contacts, c)
+		}
+	}
+
+	if !needsDetails(f) {
+		return contacts, nil
+	}
+	if err := r.loadDetails(ctx, r.db, contacts); err != nil {
+		log.Printf("%s: %v", errMsg, err)
+		return nil, errors.CreateError(operationName, errMsg, err, errors.InternalError)
+	}
+
+	if f.Highlight {
+		for i := range contacts {
+			contacts[i].Highlights = highlightMatches(contacts[i], f.FullText)
+		}
+	}
+
+	return contacts, nil
+}
+
+func (r *ContactsRepo) countFuzzy(ctx context.Context, f contact.Filters) (int, *errors.Error) {
+	hits, err := r.fuzzyHits(ctx, f)
+	if err != nil {
+		errMsg := "ContactsRepo.CountContacts"
+		log.Printf("%s: failed to fuzzy count contacts with query %s: %v", errMsg, f.FullText, err)
+		return 0, errors.CreateError(operationName, errMsg, err, errors.InternalError)
+	}
+
+	return len(hits), nil
+}
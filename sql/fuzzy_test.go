@@ -0,0 +1,98 @@
+package sql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ShaynaSegal45/phonebook-api/cache"
+	"github.com/ShaynaSegal45/phonebook-api/contact"
+)
+
+func TestContactsRepo_FuzzySearch(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t, SQLite)
+	repo := NewContactsRepo(db, SQLite, cache.NewNoop(), DefaultCacheTTL)
+
+	for i, c := range []contact.Contact{
+		{ID: "1", FirstName: "Shayna", LastName: "Segal"},
+		{ID: "2", FirstName: "John", LastName: "Segal"},
+		{ID: "3", FirstName: "John", LastName: "Doe"},
+		{ID: "4", FirstName: "Sarah", LastName: "Stein"},
+	} {
+		c.CreatedAt = time.Date(2026, time.October, i+1, 0, 0, 0, 0, time.UTC)
+		require.Nil(t, repo.InsertContact(ctx, c))
+	}
+	require.Nil(t, repo.InsertGroup(ctx, contact.Group{ID: "g1", Name: "family"}))
+	require.Nil(t, repo.AddGroupMember(ctx, "g1", "1", contact.Change{}))
+
+	type hit struct {
+		id    string
+		score float64
+	}
+	search := func(f contact.Filters) []hit {
+		t.Helper()
+		f.Match, f.Limit = contact.MatchFuzzy, 10
+		found, err := repo.SearchContacts(ctx, f)
+		require.Nil(t, err)
+		count, err := repo.CountContacts(ctx, f)
+		require.Nil(t, err)
+		assert.Len(t, found, count)
+
+		hits := []hit{}
+		for _, c := range found {
+			hits = append(hits, hit{c.ID, c.Score})
+		}
+		return hits
+	}
+
+	ids := func(hits []hit) []string {
+		ids := []string{}
+		for _, h := range hits {
+			ids = append(ids, h.id)
+		}
+		return ids
+	}
+
+	t.Run("finds misspelled names best first", func(t *testing.T) {
+		hits := search(contact.Filters{FullText: "jon segel"})
+		require.Len(t, hits, 1)
+		assert.Equal(t, "2", hits[0].id)
+		assert.InDelta(t, 0.8375, hits[0].score, 0.001)
+
+		hits = search(contact.Filters{FullText: "segel"})
+		assert.Equal(t, []string{"2", "1"}, ids(hits))
+		assert.InDelta(t, 0.8, hits[0].score, 0.001)
+	})
+
+	t.Run("keeps exact matches and combines with the other filters", func(t *testing.T) {
+		assert.Equal(t, []hit{{"1", 1}}, search(contact.Filters{FullText: "segal", AnyTags: []string{"family"}}))
+		assert.Equal(t, []string{"1"}, ids(search(contact.Filters{FullText: "hayn"})))
+		assert.Empty(t, search(contact.Filters{FullText: "xyz"}))
+	})
+
+	t.Run("sorts the hits by creation", func(t *testing.T) {
+		assert.Equal(t, []string{"2", "1"}, ids(search(contact.Filters{FullText: "segal"})))
+		sort := []contact.SortField{{Field: contact.SortCreatedAt}}
+		assert.Equal(t, []string{"1", "2"}, ids(search(contact.Filters{FullText: "segal", Sort: sort})))
+	})
+
+	t.Run("pages the scored hits", func(t *testing.T) {
+		found, err := repo.SearchContacts(ctx, contact.Filters{FullText: "segal", Match: contact.MatchFuzzy, Limit: 1, Offset: 1})
+		require.Nil(t, err)
+		require.Len(t, found, 1)
+		assert.Equal(t, "1", found[0].ID)
+	})
+
+	t.Run("computes the keys of legacy contacts", func(t *testing.T) {
+		_, err := db.Exec(`UPDATE contacts SET firstname_key = NULL, lastname_key = NULL WHERE id = '4'`)
+		require.NoError(t, err)
+		upgraded, upgradeErr := repo.UpgradeLegacySearchKeys(ctx)
+		require.Nil(t, upgradeErr)
+		assert.Equal(t, 1, upgraded)
+		assert.Equal(t, []string{"4"}, ids(search(contact.Filters{FullText: "Stien"})))
+	})
+}
@@ -0,0 +1,187 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+
+	"github.com/ShaynaSegal45/phonebook-api/contact"
+	"github.com/ShaynaSegal45/phonebook-api/errors"
+)
+
+// Groups share the contacts' cache: every group write invalidates the contacts it touches,
+// since their cached copies list their groups, and resets the search generation for tag filters.
+// For the same reason it bumps their versions and stamps them with the change, so a sync of the
+// contacts updated since some time picks up their new groups.
+
+const (
+	bumpVersion        = `UPDATE contacts SET version = version + 1, updated_at = COALESCE(?, CURRENT_TIMESTAMP), updated_by = ? WHERE id = ?`
+	bumpMemberVersions = `UPDATE contacts SET version = version + 1, updated_at = COALESCE(?, CURRENT_TIMESTAMP), updated_by = ?
+		WHERE id IN (SELECT contact_id FROM contact_group_members WHERE group_id = ?)`
+)
+
+func (r *ContactsRepo) InsertGroup(ctx context.Context, g contact.Group) *errors.Error {
+	query := `INSERT INTO contact_groups (id, name, smart_query) VALUES (?, ?, ?)`
+	if err := r.exec(ctx, r.db, query, g.ID, g.Name, g.Query); err != nil {
+		errMsg := fmt.Sprintf("ContactsRepo.InsertGroup: failed to create group with id %s", g.ID)
+		log.Printf("%s: %v", errMsg, err)
+		return errors.CreateError(operationName, errMsg, err, errors.InternalError)
+	}
+
+	return nil
+}
+
+func (r *ContactsRepo) GetGroup(ctx context.Context, id string) (contact.Group, *errors.Error) {
+	var g contact.Group
+	query := `SELECT id, name, COALESCE(smart_query, '') FROM contact_groups WHERE id = ?`
+	err := r.db.QueryRowContext(ctx, r.dialect.rebind(query), id).Scan(&g.ID, &g.Name, &g.Query)
+	if err != nil {
+		errMsg := fmt.Sprintf("ContactsRepo.GetGroup: failed to get group with id %s", id)
+		if err == sql.ErrNoRows {
+			return contact.Group{}, errors.CreateError(operationName, errMsg, err, errors.NotFoundError)
+		}
+		log.Printf("%s: %v", errMsg, err)
+		return contact.Group{}, errors.CreateError(operationName, errMsg, err, errors.InternalError)
+	}
+
+	return g, nil
+}
+
+func (r *ContactsRepo) ListGroups(ctx context.Context) ([]contact.Group, *errors.Error) {
+	errMsg := "ContactsRepo.ListGroups"
+	rows, err := r.db.QueryContext(ctx, `SELECT id, name, COALESCE(smart_query, '') FROM contact_groups ORDER BY name`)
+	if err != nil {
+		log.Printf("%s: failed to list groups: %v", errMsg, err)
+		return nil, errors.CreateError(operationName, errMsg, err, errors.InternalError)
+	}
+	defer rows.Close()
+
+	groups := []contact.Group{}
+	for rows.Next() {
+		var g contact.Group
+		if err := rows.Scan(&g.ID, &g.Name, &g.Query); err != nil {
+			log.Printf("%s: failed to scan group: %v", errMsg, err)
+			return nil, errors.CreateError(operationName, errMsg, err, errors.InternalError)
+		}
+		groups = append(groups, g)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("%s: failed to read groups: %v", errMsg, err)
+		return nil, errors.CreateError(operationName, errMsg, err, errors.InternalError)
+	}
+
+	return groups, nil
+}
+
+func (r *ContactsRepo) GroupExists(ctx context.Context, name string) (bool, *errors.Error) {
+	query := `SELECT 1 FROM contact_groups WHERE name = ?`
+	var exists int
+	err := r.db.QueryRowContext(ctx, r.dialect.rebind(query), name).Scan(&exists)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		errMsg := "ContactsRepo.GroupExists"
+		log.Printf("%s: %v", errMsg, err)
+		return false, errors.CreateError(operationName, errMsg, err, errors.InternalError)
+	}
+
+	return true, nil
+}
+
+func (r *ContactsRepo) UpdateGroup(ctx context.Context, g contact.Group, change contact.Change) *errors.Error {
+	errMsg := fmt.Sprintf("ContactsRepo.UpdateGroup: failed to update group with id %s", g.ID)
+	members, err := r.groupMembers(ctx, g.ID)
+	if err == nil {
+		err = r.inTx(ctx, func(tx *sql.Tx) error {
+			if err := r.exec(ctx, tx, `UPDATE contact_groups SET name = ?, smart_query = ? WHERE id = ?`, g.Name, g.Query, g.ID); err != nil {
+				return err
+			}
+			return r.exec(ctx, tx, bumpMemberVersions, stamp(change.At), change.By, g.ID)
+		})
+	}
+	if err != nil {
+		log.Printf("%s: %v", errMsg, err)
+		return errors.CreateError(operationName, errMsg, err, errors.InternalError)
+	}
+
+	r.invalidateContacts(ctx, members)
+	return nil
+}
+
+func (r *ContactsRepo) DeleteGroup(ctx context.Context, id string, change contact.Change) *errors.Error {
+	errMsg := fmt.Sprintf("ContactsRepo.DeleteGroup: failed to delete group with id %s", id)
+	members, err := r.groupMembers(ctx, id)
+	if err == nil {
+		err = r.inTx(ctx, func(tx *sql.Tx) error {
+			if err := r.exec(ctx, tx, bumpMemberVersions, stamp(change.At), change.By, id); err != nil {
+				return err
+			}
+			if err := r.exec(ctx, tx, `DELETE FROM contact_group_members WHERE group_id = ?`, id); err != nil {
+				return err
+			}
+			return r.exec(ctx, tx, `DELETE FROM contact_groups WHERE id = ?`, id)
+		})
+	}
+	if err != nil {
+		log.Printf("%s: %v", errMsg, err)
+		return errors.CreateError(operationName, errMsg, err, errors.InternalError)
+	}
+
+	r.invalidateContacts(ctx, members)
+	return nil
+}
+
+// AddGroupMember is idempotent, adding a contact that is already a member changes nothing.
+func (r *ContactsRepo) AddGroupMember(ctx context.Context, groupID, contactID string, change contact.Change) *errors.Error {
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		if err := r.exec(ctx, tx, `DELETE FROM contact_group_members WHERE group_id = ? AND contact_id = ?`, groupID, contactID); err != nil {
+			return err
+		}
+		if err := r.exec(ctx, tx, `INSERT INTO contact_group_members (group_id, contact_id) VALUES (?, ?)`, groupID, contactID); err != nil {
+			return err
+		}
+		return r.exec(ctx, tx, bumpVersion, stamp(change.At), change.By, contactID)
+	})
+	if err != nil {
+		errMsg := fmt.Sprintf("ContactsRepo.AddGroupMember: failed to add contact id %s to group id %s", contactID, groupID)
+		log.Printf("%s: %v", errMsg, err)
+		return errors.CreateError(operationName, errMsg, err, errors.InternalError)
+	}
+
+	r.invalidateContact(ctx, contactID)
+	return nil
+}
+
+func (r *ContactsRepo) RemoveGroupMember(ctx context.Context, groupID, contactID string, change contact.Change) *errors.Error {
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		if err := r.exec(ctx, tx, `DELETE FROM contact_group_members WHERE group_id = ? AND contact_id = ?`, groupID, contactID); err != nil {
+			return err
+		}
+		return r.exec(ctx, tx, bumpVersion, stamp(change.At), change.By, contactID)
+	})
+	if err != nil {
+		errMsg := fmt.Sprintf("ContactsRepo.RemoveGroupMember: failed to remove contact id %s from group id %s", contactID, groupID)
+		log.Printf("%s: %v", errMsg, err)
+		return errors.CreateError(operationName, errMsg, err, errors.InternalError)
+	}
+
+	r.invalidateContact(ctx, contactID)
+	return nil
+}
+
+func (r *ContactsRepo) groupMembers(ctx context.Context, groupID string) ([]string, error) {
+	var ids []string
+	err := r.queryDetails(ctx, r.db, `SELECT contact_id FROM contact_group_members WHERE group_id = ?`, []interface{}{groupID},
+		func(rows *sql.Rows) error {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				return err
+			}
+			ids = append(ids, id)
+			return nil
+		})
+
+	return ids, err
+}
@@ -0,0 +1,80 @@
+package sql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ShaynaSegal45/phonebook-api/cache"
+	"github.com/ShaynaSegal45/phonebook-api/contact"
+)
+
+func TestContactsRepo_Groups(t *testing.T) {
+	ctx := context.Background()
+	repo := NewContactsRepo(openTestDB(t, SQLite), SQLite, cache.NewLRU(100), DefaultCacheTTL)
+
+	for _, c := range []contact.Contact{
+		{ID: "1", FirstName: "Shayna", LastName: "Segal"},
+		{ID: "2", FirstName: "John", LastName: "Doe"},
+		{ID: "3", FirstName: "Jane", LastName: "Doe"},
+	} {
+		require.Nil(t, repo.InsertContact(ctx, c))
+	}
+	for _, g := range []contact.Group{{ID: "g1", Name: "family"}, {ID: "g2", Name: "on-call"}, {ID: "g3", Name: "vendors"}} {
+		require.Nil(t, repo.InsertGroup(ctx, g))
+	}
+	for _, m := range [][2]string{{"g1", "1"}, {"g1", "2"}, {"g2", "2"}, {"g2", "3"}, {"g1", "1"}} {
+		require.Nil(t, repo.AddGroupMember(ctx, m[0], m[1], contact.Change{}))
+	}
+
+	ids := func(f contact.Filters) []string {
+		f.Limit = 10
+		found, err := repo.SearchContacts(ctx, f)
+		require.Nil(t, err)
+		var ids []string
+		for _, c := range found {
+			ids = append(ids, c.ID)
+		}
+		return ids
+	}
+
+	t.Run("contacts list their groups", func(t *testing.T) {
+		c, err := repo.GetContact(ctx, "2")
+		require.Nil(t, err)
+		assert.Equal(t, []string{"family", "on-call"}, c.Groups)
+	})
+
+	t.Run("tag filters", func(t *testing.T) {
+		assert.Equal(t, []string{"3", "2", "1"}, ids(contact.Filters{AnyTags: []string{"family", "on-call"}}))
+		assert.Equal(t, []string{"2"}, ids(contact.Filters{AllTags: []string{"family", "On-Call", "family"}}))
+		assert.Equal(t, []string{"2"}, ids(contact.Filters{FullText: "john", AnyTags: []string{"family"}}))
+		assert.Empty(t, ids(contact.Filters{AnyTags: []string{"vendors"}}))
+	})
+
+	t.Run("renaming and removing invalidate cached contacts and searches", func(t *testing.T) {
+		require.Nil(t, repo.UpdateGroup(ctx, contact.Group{ID: "g2", Name: "pager"}, contact.Change{}))
+		c, err := repo.GetContact(ctx, "3")
+		require.Nil(t, err)
+		assert.Equal(t, []string{"pager"}, c.Groups)
+		assert.Empty(t, ids(contact.Filters{AnyTags: []string{"on-call"}}))
+
+		require.Nil(t, repo.RemoveGroupMember(ctx, "g1", "1", contact.Change{}))
+		assert.Equal(t, []string{"2"}, ids(contact.Filters{AnyTags: []string{"family"}}))
+
+		require.Nil(t, repo.DeleteGroup(ctx, "g1", contact.Change{}))
+		c, err = repo.GetContact(ctx, "2")
+		require.Nil(t, err)
+		assert.Equal(t, []string{"pager"}, c.Groups)
+
+		groups, err := repo.ListGroups(ctx)
+		require.Nil(t, err)
+		assert.Equal(t, []contact.Group{{ID: "g2", Name: "pager"}, {ID: "g3", Name: "vendors"}}, groups)
+	})
+
+	t.Run("deleting a contact removes its memberships", func(t *testing.T) {
+		require.Nil(t, repo.DeleteContact(ctx, "3", 0))
+		assert.Equal(t, []string{"2"}, ids(contact.Filters{AnyTags: []string{"pager"}}))
+	})
+}
@@ -0,0 +1,124 @@
+package sql
+
+import (
+	"database/sql"
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ShaynaSegal45/phonebook-api/collation"
+	"github.com/ShaynaSegal45/phonebook-api/contact"
+)
+
+// ftsHighlights holds the highlighted fields the FTS5 index returned for a contact.
+type ftsHighlights struct {
+	firstName, lastName, phones, emails, addresses sql.NullString
+}
+
+func (h ftsHighlights) fields() map[string]string {
+	highlights := make(map[string]string)
+	for field, value := range map[string]sql.NullString{
+		"firstName": h.firstName,
+		"lastName":  h.lastName,
+		"phones":    h.phones,
+		"emails":    h.emails,
+		"addresses": h.addresses,
+	} {
+		if strings.Contains(value.String, ftsMarkOpen) {
+			highlights[field] = strings.NewReplacer(ftsMarkOpen, highlightOpen, ftsMarkClose, highlightClose).
+				Replace(html.EscapeString(value.String))
+		}
+	}
+
+	return highlights
+}
+
+// highlightMatches marks the words of the fullText wherever they appear in the contact, the way
+// the FTS5 index would. It covers the backends and the contacts the index doesn't highlight.
+// Words and values are compared folded, as the search does, so jose marks José.
+// The values are HTML-escaped around the marks, so only the marks are tags.
+func highlightMatches(c contact.Contact, text string) map[string]string {
+	words := searchWords(text)
+	if len(words) == 0 {
+		return nil
+	}
+	for i, w := range words {
+		words[i] = regexp.QuoteMeta(collation.Fold(w))
+	}
+	pattern := regexp.MustCompile(strings.Join(words, `|`))
+
+	highlights := make(map[string]string)
+	mark := func(field string, values ...string) {
+		var marked []string
+		for _, value := range values {
+			folded, starts, ends := foldText(value)
+			matches := pattern.FindAllStringIndex(folded, -1)
+			if len(matches) == 0 {
+				continue
+			}
+			var b strings.Builder
+			end := 0
+			for _, m := range matches {
+				start := max(starts[m[0]], end)
+				if ends[m[1]-1] <= end {
+					// Folded from the rune marked last, e.g. the second s of ß.
+					continue
+				}
+				b.WriteString(html.EscapeString(value[end:start]))
+				b.WriteString(highlightOpen + html.EscapeString(value[start:ends[m[1]-1]]) + highlightClose)
+				end = ends[m[1]-1]
+			}
+			b.WriteString(html.EscapeString(value[end:]))
+			marked = append(marked, b.String())
+		}
+		if len(marked) > 0 {
+			highlights[field] = strings.Join(marked, " … ")
+		}
+	}
+
+	mark("firstName", c.FirstName)
+	mark("lastName", c.LastName)
+	var phones, emails, addresses []string
+	for _, p := range c.Phones {
+		phones = append(phones, p.Number)
+	}
+	for _, e := range c.Emails {
+		emails = append(emails, e.Email)
+	}
+	for _, a := range c.Addresses {
+		addresses = append(addresses, a.Address)
+	}
+	mark("phones", phones...)
+	mark("emails", emails...)
+	mark("addresses", addresses...)
+
+	return highlights
+}
+
+// foldText folds a value rune by rune and maps every byte of the folded text back to the bytes of
+// the rune it came from. A rune folded away, such as a combining accent, joins the one before it.
+func foldText(value string) (folded string, starts, ends []int) {
+	var b strings.Builder
+	last := 0
+	for i := 0; i < len(value); {
+		_, size := utf8.DecodeRuneInString(value[i:])
+		f, end := collation.Fold(value[i:i+size]), i+size
+		if f == "" {
+			for j := last; j < len(ends); j++ {
+				ends[j] = end
+			}
+			i = end
+			continue
+		}
+		last = len(starts)
+		for j := 0; j < len(f); j++ {
+			starts = append(starts, i)
+			ends = append(ends, end)
+		}
+		b.WriteString(f)
+		i = end
+	}
+
+	return b.String(), starts, ends
+}
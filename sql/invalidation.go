@@ -0,0 +1,26 @@
+package sql
+
+import (
+	"context"
+	"log"
+)
+
+// invalidateContact drops the cached copy of a contact, or the cached miss for a new one,
+// and every cached search page after a write.
+// Failures are only logged: the write itself already succeeded and the entries still expire.
+func (r *ContactsRepo) invalidateContact(ctx context.Context, id string) {
+	r.invalidateContacts(ctx, []string{id})
+}
+
+// invalidateContacts does the same for every contact a group write touched, in a single delete.
+func (r *ContactsRepo) invalidateContacts(ctx context.Context, ids []string) {
+	keys := make([]string, 0, len(ids)+1)
+	for _, id := range ids {
+		keys = append(keys, contactCacheKey(id))
+	}
+	keys = append(keys, searchGenerationKey)
+
+	if err := r.cache.Delete(ctx, keys...); err != nil {
+		log.Printf("ContactsRepo.invalidateContacts: failed to delete cache for contact ids %v: %v", ids, err)
+	}
+}
@@ -0,0 +1,196 @@
+package sql
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ShaynaSegal45/phonebook-api/cache"
+	"github.com/ShaynaSegal45/phonebook-api/contact"
+	"github.com/ShaynaSegal45/phonebook-api/errors"
+)
+
+func TestContactsRepo_WritesInvalidateCache(t *testing.T) {
+	ctx := context.Background()
+	repo := NewContactsRepo(openTestDB(t, SQLite), SQLite, cache.NewLRU(10), DefaultCacheTTL)
+
+	require.Nil(t, repo.InsertContact(ctx, contact.Contact{ID: "1", FirstName: "Shayna", LastName: "Segal"}))
+	_, err := repo.GetContact(ctx, "1")
+	require.Nil(t, err)
+
+	_, updateErr := repo.UpdateContact(ctx, contact.Contact{ID: "1", LastName: "Cohen"})
+	require.Nil(t, updateErr)
+	c, err := repo.GetContact(ctx, "1")
+	require.Nil(t, err)
+	assert.Equal(t, "Cohen", c.LastName)
+
+	require.Nil(t, repo.DeleteContact(ctx, "1", 0))
+	_, err = repo.GetContact(ctx, "1")
+	require.NotNil(t, err)
+	assert.Equal(t, errors.NotFoundError, err.StatusCode)
+}
+
+func TestContactsRepo_CachesMisses(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t, SQLite)
+	repo := NewContactsRepo(db, SQLite, cache.NewLRU(10), DefaultCacheTTL)
+
+	_, err := repo.GetContact(ctx, "1")
+	require.NotNil(t, err)
+	assert.Equal(t, errors.NotFoundError, err.StatusCode)
+
+	// Written behind the repo's back, so only the cached miss can still hide it.
+	_, dbErr := db.Exec(`INSERT INTO contacts (id, firstname, lastname, address, phone) VALUES ('1', 'Shayna', 'Segal', '', '')`)
+	require.NoError(t, dbErr)
+	_, err = repo.GetContact(ctx, "1")
+	require.NotNil(t, err)
+	assert.Equal(t, errors.NotFoundError, err.StatusCode)
+
+	_, err = repo.GetContact(ctx, "2")
+	require.NotNil(t, err)
+	require.Nil(t, repo.InsertContact(ctx, contact.Contact{ID: "2", FirstName: "Dana", LastName: "Segal"}))
+	c, err := repo.GetContact(ctx, "2")
+	require.Nil(t, err, "inserting a contact must clear a cached miss for its id")
+	assert.Equal(t, "Dana", c.FirstName)
+}
+
+func TestContactsRepo_GetCurrentContactSkipsCache(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t, SQLite)
+	repo := NewContactsRepo(db, SQLite, cache.NewLRU(10), DefaultCacheTTL)
+	require.Nil(t, repo.InsertContact(ctx, contact.Contact{ID: "1", FirstName: "Shayna", LastName: "Segal"}))
+	_, err := repo.GetContact(ctx, "1")
+	require.Nil(t, err)
+
+	// Written behind the repo's back, so the cached contact is stale.
+	_, dbErr := db.Exec(`UPDATE contacts SET lastname = 'Cohen', version = version + 1 WHERE id = '1'`)
+	require.NoError(t, dbErr)
+	c, err := repo.GetContact(ctx, "1")
+	require.Nil(t, err)
+	assert.Equal(t, int64(1), c.Version)
+
+	c, err = repo.GetCurrentContact(ctx, "1")
+	require.Nil(t, err)
+	assert.Equal(t, int64(2), c.Version)
+	c, err = repo.GetContact(ctx, "1")
+	require.Nil(t, err)
+	assert.Equal(t, "Cohen", c.LastName, "the cache is refreshed")
+}
+
+// loadCountingCache misses every read and counts the contacts loaded from the database, which
+// each cache their outcome. Caching a load waits for release, so the load stays in flight.
+type loadCountingCache struct {
+	cache.Cache
+	misses  sync.WaitGroup
+	release chan struct{}
+	loads   atomic.Int32
+}
+
+func (c *loadCountingCache) Get(ctx context.Context, key string) (string, error) {
+	if strings.HasPrefix(key, "contact:") {
+		c.misses.Done()
+		return "", cache.ErrMiss
+	}
+	return c.Cache.Get(ctx, key)
+}
+
+func (c *loadCountingCache) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	if strings.HasPrefix(key, "contact:") {
+		c.loads.Add(1)
+		<-c.release
+	}
+	return c.Cache.Set(ctx, key, value, ttl)
+}
+
+func TestContactsRepo_ConcurrentMissesLoadOnce(t *testing.T) {
+	ctx := context.Background()
+	const callers = 10
+	counting := &loadCountingCache{Cache: cache.NewNoop(), release: make(chan struct{})}
+	repo := NewContactsRepo(openTestDB(t, SQLite), SQLite, counting, DefaultCacheTTL)
+	require.Nil(t, repo.InsertContact(ctx, contact.Contact{ID: "1", FirstName: "Shayna", LastName: "Segal"}))
+
+	counting.misses.Add(callers)
+	var wg sync.WaitGroup
+	found := make([]contact.Contact, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c, err := repo.GetContact(ctx, "1")
+			assert.Nil(t, err)
+			found[i] = c
+		}()
+	}
+
+	// Every caller missed the cache; give them time to join the load before it finishes.
+	counting.misses.Wait()
+	time.Sleep(50 * time.Millisecond)
+	close(counting.release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), counting.loads.Load())
+	for _, c := range found {
+		assert.Equal(t, "Shayna", c.FirstName)
+	}
+}
+
+// pausingCache holds the caching of a loaded contact until release while paused, signalling on
+// loaded once the load got there, so a write can commit and invalidate in between.
+type pausingCache struct {
+	cache.Cache
+	paused  atomic.Bool
+	loaded  chan struct{}
+	release chan struct{}
+}
+
+func (c *pausingCache) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	if strings.HasPrefix(key, "contact:") && c.paused.CompareAndSwap(true, false) {
+		c.loaded <- struct{}{}
+		<-c.release
+	}
+	return c.Cache.Set(ctx, key, value, ttl)
+}
+
+func TestContactsRepo_WriteDuringLoad(t *testing.T) {
+	ctx := context.Background()
+	paused := &pausingCache{Cache: cache.NewLRU(10), loaded: make(chan struct{}), release: make(chan struct{})}
+	repo := NewContactsRepo(openTestDB(t, SQLite), SQLite, paused, DefaultCacheTTL)
+	require.Nil(t, repo.InsertContact(ctx, contact.Contact{ID: "1", FirstName: "Shayna", LastName: "Segal"}))
+
+	// load reads the contact, lets write commit and invalidate, and only then caches what it read.
+	load := func(write func()) {
+		t.Helper()
+		paused.paused.Store(true)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			repo.GetContact(ctx, "1")
+		}()
+		<-paused.loaded
+		write()
+		paused.release <- struct{}{}
+		<-done
+	}
+
+	load(func() {
+		_, err := repo.UpdateContact(ctx, contact.Contact{ID: "1", FirstName: "Shayna", LastName: "Cohen"})
+		require.Nil(t, err)
+	})
+	c, err := repo.GetContact(ctx, "1")
+	require.Nil(t, err)
+	assert.Equal(t, "Cohen", c.LastName)
+	assert.Equal(t, int64(2), c.Version)
+
+	// The read above cached the contact again: drop it so the next read loads.
+	require.NoError(t, paused.Delete(ctx, contactCacheKey("1")))
+	load(func() { require.Nil(t, repo.DeleteContact(ctx, "1", 0)) })
+	_, err = repo.GetContact(ctx, "1")
+	require.NotNil(t, err)
+	assert.Equal(t, errors.NotFoundError, err.StatusCode)
+}
@@ -0,0 +1,18 @@
+package sql
+
+import "github.com/ShaynaSegal45/phonebook-api/config"
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string { return config.AdapterMySQL }
+
+func (mysqlDialect) rebind(query string) string { return query }
+
+// LIKE follows the column collation, utf8mb4's default one is case-insensitive.
+func (mysqlDialect) caseInsensitiveLike(column string) string {
+	return column + ` LIKE ? ESCAPE '!'`
+}
+
+func (mysqlDialect) fullTextCondition(query string) (string, []interface{}) {
+	return likeCondition(query)
+}
@@ -0,0 +1,105 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	"github.com/ShaynaSegal45/phonebook-api/contact"
+	"github.com/ShaynaSegal45/phonebook-api/errors"
+	"github.com/ShaynaSegal45/phonebook-api/phone"
+)
+
+type legacyPhone struct {
+	contactID string
+	position  int
+	number    string
+}
+
+// UpgradeLegacyPhones fills in the E.164 form of the numbers stored before it was kept, reading national
+// numbers in defaultRegion. It returns how many it upgraded and is safe to run on every start.
+func (r *ContactsRepo) UpgradeLegacyPhones(ctx context.Context, defaultRegion string) (int, *errors.Error) {
+	errMsg := "ContactsRepo.UpgradeLegacyPhones"
+
+	var legacy []legacyPhone
+	err := r.queryDetails(ctx, r.db, `SELECT contact_id, position, number FROM contact_phones WHERE e164 IS NULL`, nil,
+		func(rows *sql.Rows) error {
+			var p legacyPhone
+			if err := rows.Scan(&p.contactID, &p.position, &p.number); err != nil {
+				return err
+			}
+			legacy = append(legacy, p)
+			return nil
+		})
+	if err != nil {
+		log.Printf("%s: failed to load legacy phones: %v", errMsg, err)
+		return 0, errors.CreateError(operationName, errMsg, err, errors.InternalError)
+	}
+
+	// A number that can't be normalized keeps an empty e164, it is still found by its display form.
+	for _, l := range legacy {
+		e164, err := phone.Normalize(l.number, defaultRegion)
+		if err != nil {
+			log.Printf("%s: keeping phone of contact id %s as is: %v", errMsg, l.contactID, err)
+		}
+		query := `UPDATE contact_phones SET e164 = ? WHERE contact_id = ? AND position = ?`
+		if err := r.exec(ctx, r.db, query, e164, l.contactID, l.position); err != nil {
+			log.Printf("%s: failed to upgrade phone of contact id %s: %v", errMsg, l.contactID, err)
+			return 0, errors.CreateError(operationName, errMsg, err, errors.InternalError)
+		}
+		r.invalidateContact(ctx, l.contactID)
+	}
+
+	return len(legacy), nil
+}
+
+// FindContactsByPhone returns the contacts owning an E.164 number, looked up through the e164 index.
+// An empty result is cached as well, most inbound calls come from numbers nobody saved.
+func (r *ContactsRepo) FindContactsByPhone(ctx context.Context, e164 string) ([]contact.Contact, *errors.Error) {
+	generation := r.searchGeneration(ctx)
+	if contacts, ok := r.cachedContacts(ctx, generation, phoneCacheKey(generation, e164)); ok {
+		return contacts, nil
+	}
+
+	contacts, err := r.findContactsByPhone(ctx, e164)
+	if err != nil {
+		return nil, err
+	}
+
+	r.cacheContacts(ctx, generation, phoneCacheKey(generation, e164), contacts)
+	return contacts, nil
+}
+
+func (r *ContactsRepo) findContactsByPhone(ctx context.Context, e164 string) ([]contact.Contact, *errors.Error) {
+	errMsg := "ContactsRepo.FindContactsByPhone"
+	query := `SELECT ` + contactColumns + ` FROM contacts
+		WHERE id IN (SELECT contact_id FROM contact_phones WHERE e164 = ?)
+		ORDER BY lastname, firstname`
+	rows, err := r.db.QueryContext(ctx, r.dialect.rebind(query), e164)
+	if err != nil {
+		log.Printf("%s: failed to find contacts with phone %s: %v", errMsg, e164, err)
+		return nil, errors.CreateError(operationName, errMsg, err, errors.InternalError)
+	}
+	defer rows.Close()
+
+	contacts := []contact.Contact{}
+	for rows.Next() {
+		var c contact.Contact
+		if err := rows.Scan(contactDest(&c)...); err != nil {
+			log.Printf("%s: failed to scan contact: %v", errMsg, err)
+			return nil, errors.CreateError(operationName, errMsg, err, errors.InternalError)
+		}
+		contacts = append(contacts, c)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("%s: failed to read contacts: %v", errMsg, err)
+		return nil, errors.CreateError(operationName, errMsg, err, errors.InternalError)
+	}
+
+	if err := r.loadDetails(ctx, r.db, contacts); err != nil {
+		log.Printf("%s: %v", errMsg, err)
+		return nil, errors.CreateError(operationName, errMsg, err, errors.InternalError)
+	}
+
+	return contacts, nil
+}
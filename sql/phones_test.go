@@ -0,0 +1,92 @@
+package sql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ShaynaSegal45/phonebook-api/cache"
+	"github.com/ShaynaSegal45/phonebook-api/contact"
+)
+
+func TestContactsRepo_SearchMatchesE164(t *testing.T) {
+	ctx := context.Background()
+	repo := NewContactsRepo(openTestDB(t, SQLite), SQLite, cache.NewNoop(), DefaultCacheTTL)
+
+	c := contact.Contact{
+		ID: "1", FirstName: "Shayna", LastName: "Segal",
+		Phones: []contact.Phone{{Label: contact.LabelMobile, Number: "050 123 4567", E164: "+972501234567", Primary: true}},
+	}
+	c.Normalize()
+	require.Nil(t, repo.InsertContact(ctx, c))
+
+	for _, f := range []contact.Filters{
+		{FullText: "0501234567", PhoneDigits: "501234567"},
+		{FullText: "+972-50-123", PhoneDigits: "97250123"},
+	} {
+		count, err := repo.CountContacts(ctx, f)
+		require.Nil(t, err)
+		assert.Equal(t, 1, count, f.FullText)
+	}
+}
+
+func TestContactsRepo_UpgradeLegacyPhones(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t, SQLite)
+	repo := NewContactsRepo(db, SQLite, cache.NewNoop(), DefaultCacheTTL)
+
+	_, err := db.Exec(`INSERT INTO contacts (id, firstname, lastname, address, phone) VALUES ('1', 'Shayna', 'Segal', '', '050-1234567')`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO contact_phones (contact_id, position, label, number, is_primary) VALUES ('1', 0, 'mobile', '050-1234567', 1), ('1', 1, 'work', 'call reception', 0)`)
+	require.NoError(t, err)
+
+	upgraded, upgradeErr := repo.UpgradeLegacyPhones(ctx, "IL")
+	require.Nil(t, upgradeErr)
+	assert.Equal(t, 2, upgraded)
+
+	c, getErr := repo.GetContact(ctx, "1")
+	require.Nil(t, getErr)
+	assert.Equal(t, []contact.Phone{
+		{Label: contact.LabelMobile, Number: "050-1234567", E164: "+972501234567", Primary: true},
+		{Label: contact.LabelWork, Number: "call reception"},
+	}, c.Phones)
+
+	upgraded, upgradeErr = repo.UpgradeLegacyPhones(ctx, "IL")
+	require.Nil(t, upgradeErr)
+	assert.Equal(t, 0, upgraded)
+}
+
+func TestContactsRepo_FindContactsByPhone(t *testing.T) {
+	ctx := context.Background()
+	repo := NewContactsRepo(openTestDB(t, SQLite), SQLite, cache.NewLRU(10), DefaultCacheTTL)
+
+	shared := contact.Phone{Label: contact.LabelHome, Number: "04-8123456", E164: "+97248123456"}
+	contacts := []contact.Contact{
+		{ID: "1", FirstName: "Shayna", LastName: "Segal", Phones: []contact.Phone{shared}},
+		{ID: "2", FirstName: "Dan", LastName: "Segal", Phones: []contact.Phone{shared, {Number: "0501234567", E164: "+972501234567"}}},
+	}
+	for i := range contacts {
+		contacts[i].Normalize()
+		require.Nil(t, repo.InsertContact(ctx, contacts[i]))
+	}
+
+	found, err := repo.FindContactsByPhone(ctx, "+97248123456")
+	require.Nil(t, err)
+	assert.Len(t, found, 2)
+
+	found, err = repo.FindContactsByPhone(ctx, "+97248")
+	require.Nil(t, err)
+	assert.Empty(t, found, "only whole numbers match")
+
+	update := contact.Contact{ID: "1", Phones: []contact.Phone{}}
+	update.Normalize()
+	_, updateErr := repo.UpdateContact(ctx, update)
+	require.Nil(t, updateErr)
+
+	found, err = repo.FindContactsByPhone(ctx, "+97248123456")
+	require.Nil(t, err)
+	require.Len(t, found, 1, "a write invalidates the cached lookup")
+	assert.Equal(t, "2", found[0].ID)
+}
@@ -0,0 +1,68 @@
+package sql
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/ShaynaSegal45/phonebook-api/config"
+)
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string { return config.AdapterPostgres }
+
+// rebind rewrites `?` placeholders into Postgres' positional `$n` form, leaving quoted literals untouched.
+func (postgresDialect) rebind(query string) string {
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+
+	n, quoted := 0, false
+	for _, ch := range query {
+		switch {
+		case ch == '\'':
+			quoted = !quoted
+		case ch == '?' && !quoted:
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(ch)
+	}
+
+	return b.String()
+}
+
+func (postgresDialect) caseInsensitiveLike(column string) string {
+	return column + ` ILIKE ? ESCAPE '!'`
+}
+
+// fullTextCondition matches every word of the query as a prefix of a token of the name,
+// or of a single phone, email or address.
+func (postgresDialect) fullTextCondition(query string) (string, []interface{}) {
+	if query == "" {
+		return "", nil
+	}
+
+	tsQuery := prefixTSQuery(query)
+	if tsQuery == "" {
+		return `FALSE`, nil
+	}
+
+	return `(search_vector @@ to_tsquery('simple', ?)
+			OR id IN (SELECT contact_id FROM contact_phones WHERE search_vector @@ to_tsquery('simple', ?))
+			OR id IN (SELECT contact_id FROM contact_emails WHERE search_vector @@ to_tsquery('simple', ?))
+			OR id IN (SELECT contact_id FROM contact_addresses WHERE search_vector @@ to_tsquery('simple', ?)))`,
+		[]interface{}{tsQuery, tsQuery, tsQuery, tsQuery}
+}
+
+// prefixTSQuery turns free text into a to_tsquery expression such as `shay:* & seg:*`,
+// dropping any tsquery operators the caller typed.
+func prefixTSQuery(query string) string {
+	words := searchWords(query)
+	terms := make([]string, 0, len(words))
+	for _, w := range words {
+		terms = append(terms, strings.ToLower(w)+":*")
+	}
+
+	return strings.Join(terms, " & ")
+}
@@ -0,0 +1,102 @@
+package sql
+
+import (
+	"strings"
+
+	"github.com/ShaynaSegal45/phonebook-api/collation"
+	"github.com/ShaynaSegal45/phonebook-api/contact"
+	"github.com/ShaynaSegal45/phonebook-api/query"
+)
+
+// compileQuery turns a parsed search query into a parameterized condition. Field values match
+// whole (ignoring case and accents) unless they end with '*', bare words go through the dialect's
+// full-text condition and phrases match as a substring of any field.
+func (r *ContactsRepo) compileQuery(n *query.Node) (string, []interface{}) {
+	switch n.Op {
+	case query.OpAnd, query.OpOr:
+		conditions := make([]string, 0, len(n.Children))
+		var args []interface{}
+		for _, child := range n.Children {
+			condition, childArgs := r.compileQuery(child)
+			conditions = append(conditions, condition)
+			args = append(args, childArgs...)
+		}
+		return `(` + strings.Join(conditions, ` `+strings.ToUpper(string(n.Op))+` `) + `)`, args
+	case query.OpNot:
+		condition, args := r.compileQuery(n.Children[0])
+		return `NOT ` + condition, args
+	case query.OpTerm:
+		return r.compileTerm(n)
+	default:
+		// The parser only builds the nodes above.
+		return `1 = 0`, nil
+	}
+}
+
+func (r *ContactsRepo) compileTerm(n *query.Node) (string, []interface{}) {
+	like := r.dialect.caseInsensitiveLike
+	value := pattern(n)
+	folded := pattern(&query.Node{Value: collation.Fold(n.Value), Prefix: n.Prefix})
+
+	switch n.Field {
+	case "":
+		if n.Phrase {
+			substring := `%` + likeEscape(n.Value) + `%`
+			foldedSubstring := `%` + likeEscape(collation.Fold(n.Value)) + `%`
+			return `(` + like(`firstname_folded`) + ` OR ` + like(`lastname_folded`) +
+					` OR id IN (SELECT contact_id FROM contact_phones WHERE ` + like(`number`) + `)` +
+					` OR id IN (SELECT contact_id FROM contact_emails WHERE ` + like(`email`) + `)` +
+					` OR id IN (SELECT contact_id FROM contact_addresses WHERE ` + like(`address_folded`) + `))`,
+				[]interface{}{foldedSubstring, foldedSubstring, substring, substring, foldedSubstring}
+		}
+		condition, args := r.fullTextCondition(n.Value)
+		if n.Normalized != "" {
+			condition = `(` + condition + ` OR id IN (SELECT contact_id FROM contact_phones WHERE e164 LIKE ?))`
+			args = append(args, `%`+n.Normalized+`%`)
+		}
+		return condition, args
+	case query.FieldFirst:
+		return like(`firstname_folded`), []interface{}{folded}
+	case query.FieldLast:
+		return like(`lastname_folded`), []interface{}{folded}
+	case query.FieldName:
+		return `(` + like(`firstname_folded`) + ` OR ` + like(`lastname_folded`) + `)`, []interface{}{folded, folded}
+	case query.FieldPhone:
+		if n.Normalized == "" {
+			return `id IN (SELECT contact_id FROM contact_phones WHERE ` + like(`number`) + `)`,
+				[]interface{}{`%` + likeEscape(n.Value) + `%`}
+		}
+		if n.Prefix {
+			return `id IN (SELECT contact_id FROM contact_phones WHERE e164 LIKE ?)`, []interface{}{n.Normalized + `%`}
+		}
+		return `id IN (SELECT contact_id FROM contact_phones WHERE e164 = ?)`, []interface{}{n.Normalized}
+	case query.FieldEmail:
+		return `id IN (SELECT contact_id FROM contact_emails WHERE ` + like(`email`) + `)`, []interface{}{value}
+	case query.FieldAddress:
+		return `id IN (SELECT contact_id FROM contact_addresses WHERE ` + like(`address_folded`) + `)`,
+			[]interface{}{`%` + likeEscape(collation.Fold(n.Value)) + `%`}
+	case query.FieldCity:
+		return `id IN (SELECT contact_id FROM contact_addresses WHERE ` + like(`city`) + `)`, []interface{}{value}
+	case query.FieldRegion:
+		return `id IN (SELECT contact_id FROM contact_addresses WHERE ` + like(`region`) + `)`, []interface{}{value}
+	case query.FieldPostalCode:
+		return `id IN (SELECT contact_id FROM contact_addresses WHERE ` + like(`postal_code`) + `)`, []interface{}{value}
+	case query.FieldCountry:
+		return `id IN (SELECT contact_id FROM contact_addresses WHERE country_code = ?)`, []interface{}{countryFilter(n.Value)}
+	case query.FieldTag:
+		return `id IN (SELECT m.contact_id FROM contact_group_members m JOIN contact_groups g ON g.id = m.group_id
+			WHERE ` + like(`g.name`) + `)`, []interface{}{pattern(&query.Node{Value: contact.NormalizeGroupName(n.Value), Prefix: n.Prefix})}
+	default:
+		// The lexer only accepts the fields above.
+		return `1 = 0`, nil
+	}
+}
+
+// pattern matches a field value whole, or as a prefix when it ended with '*'.
+func pattern(n *query.Node) string {
+	if n.Prefix {
+		return likeEscape(n.Value) + `%`
+	}
+
+	return likeEscape(n.Value)
+}
@@ -0,0 +1,126 @@
+package sql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ShaynaSegal45/phonebook-api/cache"
+	"github.com/ShaynaSegal45/phonebook-api/contact"
+	"github.com/ShaynaSegal45/phonebook-api/query"
+)
+
+func TestContactsRepo_SearchQuery(t *testing.T) {
+	ctx := context.Background()
+	repo := NewContactsRepo(openTestDB(t, SQLite), SQLite, cache.NewNoop(), DefaultCacheTTL)
+
+	for _, c := range []contact.Contact{
+		{ID: "1", FirstName: "Shayna", LastName: "Segal", Phone: "050-1234567", Address: "12 Herzl St, Haifa",
+			Phones:    []contact.Phone{{Number: "050-1234567", E164: "+972501234567", Primary: true}},
+			Emails:    []contact.Email{{Email: "shayna@example.com", Primary: true}},
+			Addresses: []contact.Address{{Address: "12 Herzl St, Haifa", Street: "12 Herzl St", City: "Haifa", Primary: true}}},
+		{ID: "2", FirstName: "Dan", LastName: "Segalovich", Phone: "03-1234567",
+			Phones: []contact.Phone{{Number: "03-1234567", E164: "+97231234567", Primary: true}}},
+		{ID: "3", FirstName: "John", LastName: "Doe", Address: "5 Main St, Springfield",
+			Addresses: []contact.Address{{Address: "5 Main St, Springfield", Street: "5 Main St", City: "Springfield", Primary: true}}},
+	} {
+		require.Nil(t, repo.InsertContact(ctx, c))
+	}
+	require.Nil(t, repo.InsertGroup(ctx, contact.Group{ID: "g1", Name: "archived"}))
+	require.Nil(t, repo.AddGroupMember(ctx, "g1", "2", contact.Change{}))
+
+	ids := func(q string) []string {
+		t.Helper()
+		node, err := query.Parse(q)
+		require.NoError(t, err)
+		found, searchErr := repo.SearchContacts(ctx, contact.Filters{Query: node, Limit: 10})
+		require.Nil(t, searchErr)
+		count, countErr := repo.CountContacts(ctx, contact.Filters{Query: node})
+		require.Nil(t, countErr)
+		ids := []string{}
+		for _, c := range found {
+			ids = append(ids, c.ID)
+		}
+		assert.Len(t, ids, count)
+		return ids
+	}
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{`last:segal`, []string{"1"}},
+		{`last:SEGAL*`, []string{"1", "2"}},
+		{`last:segal* -tag:archived`, []string{"1"}},
+		{`first:john OR first:dan`, []string{"3", "2"}},
+		{`NOT last:doe`, []string{"1", "2"}},
+		{`"herzl st"`, []string{"1"}},
+		{`email:shayna@example.com`, []string{"1"}},
+		{`city:haifa`, []string{"1"}},
+		{`address:springfield`, []string{"3"}},
+		{`tag:arch*`, []string{"2"}},
+		{`segal (first:dan OR first:nobody)`, []string{"2"}},
+		{`last:seg_l`, []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			assert.Equal(t, test.expected, ids(test.query))
+		})
+	}
+
+	t.Run("phone values match their E.164 form", func(t *testing.T) {
+		node, err := query.Parse(`phone:+97250*`)
+		require.NoError(t, err)
+		node.Normalized = "+97250"
+		found, searchErr := repo.SearchContacts(ctx, contact.Filters{Query: node, Limit: 10})
+		require.Nil(t, searchErr)
+		require.Len(t, found, 1)
+		assert.Equal(t, "1", found[0].ID)
+
+		node, err = query.Parse(`phone:1234567`)
+		require.NoError(t, err)
+		found, searchErr = repo.SearchContacts(ctx, contact.Filters{Query: node, Limit: 10})
+		require.Nil(t, searchErr)
+		assert.Len(t, found, 2)
+	})
+}
+
+func TestContactsRepo_CompileQuery(t *testing.T) {
+	tests := []struct {
+		dialect  Dialect
+		query    string
+		expected string
+		args     []interface{}
+	}{
+		{SQLite, `last:50%_off!*`, `lastname_folded LIKE ? ESCAPE '!'`, []interface{}{`50!%!_off!!%`}},
+		{MySQL, `last:50%_off!*`, `lastname_folded LIKE ? ESCAPE '!'`, []interface{}{`50!%!_off!!%`}},
+		{Postgres, `last:50%_off!*`, `lastname_folded ILIKE $1 ESCAPE '!'`, []interface{}{`50!%!_off!!%`}},
+		{SQLite, `email:a_b@x.com`, `id IN (SELECT contact_id FROM contact_emails WHERE email LIKE ? ESCAPE '!')`,
+			[]interface{}{`a!_b@x.com`}},
+		{MySQL, `city:Tel%`, `id IN (SELECT contact_id FROM contact_addresses WHERE city LIKE ? ESCAPE '!')`,
+			[]interface{}{`Tel!%`}},
+		{Postgres, `first:Shay* -city:Haifa`,
+			`(firstname_folded ILIKE $1 ESCAPE '!' AND NOT id IN (SELECT contact_id FROM contact_addresses WHERE city ILIKE $2 ESCAPE '!'))`,
+			[]interface{}{`shay%`, `Haifa`}},
+		{Postgres, `"100% off!"`,
+			`(firstname_folded ILIKE $1 ESCAPE '!' OR lastname_folded ILIKE $2 ESCAPE '!'` +
+				` OR id IN (SELECT contact_id FROM contact_phones WHERE number ILIKE $3 ESCAPE '!')` +
+				` OR id IN (SELECT contact_id FROM contact_emails WHERE email ILIKE $4 ESCAPE '!')` +
+				` OR id IN (SELECT contact_id FROM contact_addresses WHERE address_folded ILIKE $5 ESCAPE '!'))`,
+			[]interface{}{`%100!% off!!%`, `%100!% off!!%`, `%100!% off!!%`, `%100!% off!!%`, `%100!% off!!%`}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.dialect.Name()+" "+test.query, func(t *testing.T) {
+			node, err := query.Parse(test.query)
+			require.NoError(t, err)
+
+			repo := &ContactsRepo{dialect: test.dialect}
+			condition, args := repo.compileQuery(node)
+			assert.Equal(t, test.expected, test.dialect.rebind(condition))
+			assert.Equal(t, test.args, args)
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package sql
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/google/uuid"
+
+	"github.com/ShaynaSegal45/phonebook-api/cache"
+	"github.com/ShaynaSegal45/phonebook-api/contact"
+)
+
+// Search pages and counts are cached under the current search generation. Every write deletes the
+// generation key and the next read mints a fresh one, so pages cached before the write are never
+// read again and simply expire. A fresh random generation can't collide with one an old page still uses.
+const (
+	searchGenerationKey = "contacts:search:generation"
+	searchCacheVersion  = 10
+)
+
+func (r *ContactsRepo) searchGeneration(ctx context.Context) string {
+	generation, err := r.cache.Get(ctx, searchGenerationKey)
+	if err == nil {
+		return generation
+	}
+	if err != cache.ErrMiss {
+		log.Printf("ContactsRepo.searchGeneration: failed to get search generation: %v", err)
+		return ""
+	}
+
+	generation = uuid.New().String()
+	if err := r.cache.Set(ctx, searchGenerationKey, generation, 0); err != nil {
+		log.Printf("ContactsRepo.searchGeneration: failed to set search generation: %v", err)
+		return ""
+	}
+
+	return generation
+}
+
+// cachedContacts reads a list of contacts cached under the search generation: a search page or
+// the owners of a phone number.
+func (r *ContactsRepo) cachedContacts(ctx context.Context, generation, key string) ([]contact.Contact, bool) {
+	if generation == "" {
+		return nil, false
+	}
+
+	data, err := r.cache.Get(ctx, key)
+	if err != nil {
+		return nil, false
+	}
+
+	var contacts []contact.Contact
+	if err := json.Unmarshal([]byte(data), &contacts); err != nil {
+		log.Printf("ContactsRepo.cachedContacts: dropping unreadable contacts under %s: %v", key, err)
+		return nil, false
+	}
+
+	return contacts, true
+}
+
+func (r *ContactsRepo) cacheContacts(ctx context.Context, generation, key string, contacts []contact.Contact) {
+	if generation == "" {
+		return
+	}
+
+	data, err := json.Marshal(contacts)
+	if err == nil {
+		err = r.cache.Set(ctx, key, string(data), r.ttl.Search)
+	}
+	if err != nil {
+		log.Printf("ContactsRepo.cacheContacts: failed to cache contacts under %s: %v", key, err)
+	}
+}
+
+func (r *ContactsRepo) cachedCount(ctx context.Context, generation string, f contact.Filters) (int, bool) {
+	if generation == "" {
+		return 0, false
+	}
+
+	data, err := r.cache.Get(ctx, countCacheKey(generation, f))
+	if err != nil {
+		return 0, false
+	}
+
+	count, err := strconv.Atoi(data)
+	if err != nil {
+		log.Printf("ContactsRepo.cachedCount: dropping unreadable count: %v", err)
+		return 0, false
+	}
+
+	return count, true
+}
+
+func (r *ContactsRepo) cacheCount(ctx context.Context, generation string, f contact.Filters, count int) {
+	if generation == "" {
+		return
+	}
+
+	if err := r.cache.Set(ctx, countCacheKey(generation, f), strconv.Itoa(count), r.ttl.Search); err != nil {
+		log.Printf("ContactsRepo.cacheCount: failed to cache count: %v", err)
+	}
+}
+
+// searchCacheKey hashes the whole filter so every parameter that shapes a page is part of its key.
+func searchCacheKey(generation string, f contact.Filters) string {
+	filters, _ := json.Marshal(f)
+	return fmt.Sprintf("contacts:search:v%d:%s:%s", searchCacheVersion, generation, hash(filters))
+}
+
+func phoneCacheKey(generation, e164 string) string {
+	return fmt.Sprintf("contacts:by-phone:v%d:%s:%s", searchCacheVersion, generation, e164)
+}
+
+func countCacheKey(generation string, f contact.Filters) string {
+	filters, _ := json.Marshal(f)
+	return fmt.Sprintf("contacts:count:v%d:%s:%s", searchCacheVersion, generation, hash(filters))
+}
+
+func hash(data []byte) string {
+	sum := sha1.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
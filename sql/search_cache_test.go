@@ -0,0 +1,64 @@
+package sql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ShaynaSegal45/phonebook-api/cache"
+	"github.com/ShaynaSegal45/phonebook-api/contact"
+	"github.com/ShaynaSegal45/phonebook-api/errors"
+)
+
+func TestContactsRepo_SearchCacheFollowsWrites(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t, SQLite)
+	repo := NewContactsRepo(db, SQLite, cache.NewLRU(100), DefaultCacheTTL)
+	filters := contact.Filters{FullText: "segal", Limit: 10}
+
+	require.Nil(t, repo.InsertContact(ctx, contact.Contact{ID: "1", FirstName: "Shayna", LastName: "Segal"}))
+	found, err := repo.SearchContacts(ctx, filters)
+	require.Nil(t, err)
+	require.Len(t, found, 1)
+	count, err := repo.CountContacts(ctx, contact.Filters{FullText: "segal"})
+	require.Nil(t, err)
+	require.Equal(t, 1, count)
+
+	// Written behind the repo's back, so only a cached page can still hide it.
+	_, dbErr := db.Exec(`INSERT INTO contacts (id, firstname, lastname, address, phone) VALUES ('2', 'Dana', 'Segal', '', '')`)
+	require.NoError(t, dbErr)
+	found, err = repo.SearchContacts(ctx, filters)
+	require.Nil(t, err)
+	assert.Len(t, found, 1, "search page should be served from cache")
+	count, err = repo.CountContacts(ctx, contact.Filters{FullText: "segal"})
+	require.Nil(t, err)
+	assert.Equal(t, 1, count, "count should be served from cache")
+
+	writes := []struct {
+		name  string
+		write func() *errors.Error
+	}{
+		{"insert", func() *errors.Error {
+			return repo.InsertContact(ctx, contact.Contact{ID: "3", FirstName: "Avi", LastName: "Segal"})
+		}},
+		{"update", func() *errors.Error {
+			_, err := repo.UpdateContact(ctx, contact.Contact{ID: "3", LastName: "Segev"})
+			return err
+		}},
+		{"delete", func() *errors.Error { return repo.DeleteContact(ctx, "2", 0) }},
+	}
+	for _, w := range writes {
+		require.Nil(t, w.write())
+		expected, dbErr := repo.countContacts(ctx, contact.Filters{FullText: "segal"})
+		require.Nil(t, dbErr)
+
+		count, err = repo.CountContacts(ctx, contact.Filters{FullText: "segal"})
+		require.Nil(t, err)
+		assert.Equal(t, expected, count, "count after %s", w.name)
+		found, err = repo.SearchContacts(ctx, filters)
+		require.Nil(t, err)
+		assert.Len(t, found, expected, "search page after %s", w.name)
+	}
+}
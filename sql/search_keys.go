@@ -0,0 +1,153 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	"github.com/ShaynaSegal45/phonebook-api/collation"
+	"github.com/ShaynaSegal45/phonebook-api/contact"
+	"github.com/ShaynaSegal45/phonebook-api/errors"
+	"github.com/ShaynaSegal45/phonebook-api/fuzzy"
+)
+
+// writeSearchKeys derives every search key of a contact from its stored name: the folded name the
+// fullText matches, the phonetic keys of the fuzzy search and a sort key per supported locale.
+func (r *ContactsRepo) writeSearchKeys(ctx context.Context, tx *sql.Tx, id string) error {
+	var firstName, lastName sql.NullString
+	query := `SELECT firstname, lastname FROM contacts WHERE id = ?`
+	if err := tx.QueryRowContext(ctx, r.dialect.rebind(query), id).Scan(&firstName, &lastName); err != nil {
+		return err
+	}
+
+	query = `UPDATE contacts SET firstname_folded = ?, lastname_folded = ?, firstname_key = ?, lastname_key = ? WHERE id = ?`
+	err := r.exec(ctx, tx, query, collation.Fold(firstName.String), collation.Fold(lastName.String),
+		fuzzy.Key(firstName.String), fuzzy.Key(lastName.String), id)
+	if err != nil {
+		return err
+	}
+
+	if err := r.exec(ctx, tx, `DELETE FROM contact_sort_keys WHERE contact_id = ?`, id); err != nil {
+		return err
+	}
+	for _, locale := range collation.Locales {
+		query := `INSERT INTO contact_sort_keys (contact_id, locale, sort_key) VALUES (?, ?, ?)`
+		if err := r.exec(ctx, tx, query, id, locale, collation.SortKey(locale, lastName.String, firstName.String)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sortKeyOrder joins the sort keys of the search's locale and orders by them. The names and id
+// break ties, and keep an order for contacts written before their keys were. Cursor pages join the
+// keys inner, so the (locale, sort_key) index can drive their scan: every contact has its keys once
+// UpgradeLegacySearchKeys ran, which the server does before it starts.
+func sortKeyOrder(f contact.Filters) (join string, args []interface{}, order string) {
+	locale := f.Locale
+	if locale == "" {
+		locale = collation.Root
+	}
+
+	join = ` LEFT JOIN contact_sort_keys k ON k.contact_id = contacts.id AND k.locale = ?`
+	if f.After != nil || f.Before != nil {
+		join = ` JOIN contact_sort_keys k ON k.contact_id = contacts.id AND k.locale = ?`
+	}
+	return join, []interface{}{locale}, `k.sort_key IS NULL, k.sort_key, lastname, firstname, id`
+}
+
+// UpgradeLegacySearchKeys computes the search keys of the contacts and addresses stored before they
+// were kept, the sort keys of locales supported since a contact was written, and the sort keys
+// stored before they were bounded to collation.SortKeyLength.
+func (r *ContactsRepo) UpgradeLegacySearchKeys(ctx context.Context) (int, *errors.Error) {
+	errMsg := "ContactsRepo.UpgradeLegacySearchKeys"
+
+	var legacy []string
+	query := `SELECT id FROM contacts
+		WHERE firstname_folded IS NULL OR firstname_key IS NULL
+			OR (SELECT count(*) FROM contact_sort_keys WHERE contact_id = contacts.id) < ?
+			OR id IN (SELECT contact_id FROM contact_sort_keys WHERE length(sort_key) > ?)`
+	args := []interface{}{len(collation.Locales), collation.SortKeyLength}
+	err := r.queryDetails(ctx, r.db, query, args, func(rows *sql.Rows) error {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		legacy = append(legacy, id)
+		return nil
+	})
+	if err != nil {
+		log.Printf("%s: failed to load legacy contacts: %v", errMsg, err)
+		return 0, errors.CreateError(operationName, errMsg, err, errors.InternalError)
+	}
+
+	for _, id := range legacy {
+		if err := r.inTx(ctx, func(tx *sql.Tx) error { return r.writeSearchKeys(ctx, tx, id) }); err != nil {
+			log.Printf("%s: failed to upgrade contact id %s: %v", errMsg, id, err)
+			return 0, errors.CreateError(operationName, errMsg, err, errors.InternalError)
+		}
+	}
+
+	type legacyAddress struct {
+		contactID string
+		position  int
+		address   string
+	}
+	var addresses []legacyAddress
+	err = r.queryDetails(ctx, r.db, `SELECT contact_id, position, address FROM contact_addresses WHERE address_folded IS NULL`, nil,
+		func(rows *sql.Rows) error {
+			var a legacyAddress
+			if err := rows.Scan(&a.contactID, &a.position, &a.address); err != nil {
+				return err
+			}
+			addresses = append(addresses, a)
+			return nil
+		})
+	if err != nil {
+		log.Printf("%s: failed to load legacy addresses: %v", errMsg, err)
+		return 0, errors.CreateError(operationName, errMsg, err, errors.InternalError)
+	}
+
+	for _, a := range addresses {
+		query := `UPDATE contact_addresses SET address_folded = ? WHERE contact_id = ? AND position = ?`
+		if err := r.exec(ctx, r.db, query, collation.Fold(a.address), a.contactID, a.position); err != nil {
+			log.Printf("%s: failed to upgrade address of contact id %s: %v", errMsg, a.contactID, err)
+			return 0, errors.CreateError(operationName, errMsg, err, errors.InternalError)
+		}
+	}
+
+	// Only searches see the keys, the cached contacts stay valid.
+	if len(legacy) > 0 || len(addresses) > 0 {
+		r.invalidateContacts(ctx, nil)
+	}
+
+	return len(legacy), nil
+}
+
+// keysetOrder is the order of the pages read through a cursor: the sort key, then the names and the id.
+const keysetOrder = `k.sort_key, lastname, firstname, id`
+
+// keyset pages from the cursor of a search: the contacts after f.After in keysetOrder, or the ones
+// before f.Before read backwards. The sort key of the cursor is computed again from its names, it
+// is the one stored for the contact unless it was renamed since, and then still a valid position.
+// The comparison on k.sort_key alone repeats the row's first column, so it bounds the index scan.
+func keyset(f contact.Filters) (condition string, args []interface{}, order string) {
+	locale := f.Locale
+	if locale == "" {
+		locale = collation.Root
+	}
+
+	position, op, order := f.After, `>`, keysetOrder
+	if f.Before != nil {
+		position, op, order = f.Before, `<`, `k.sort_key DESC, lastname DESC, firstname DESC, id DESC`
+	}
+	if position.ID == "" {
+		return ``, nil, order
+	}
+
+	key := collation.SortKey(locale, position.LastName, position.FirstName)
+	return `k.sort_key ` + op + `= ? AND (` + keysetOrder + `) ` + op + ` (?, ?, ?, ?)`,
+		[]interface{}{key, key, position.LastName, position.FirstName, position.ID},
+		order
+}
@@ -0,0 +1,193 @@
+package sql
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ShaynaSegal45/phonebook-api/cache"
+	"github.com/ShaynaSegal45/phonebook-api/collation"
+	"github.com/ShaynaSegal45/phonebook-api/contact"
+	"github.com/ShaynaSegal45/phonebook-api/query"
+)
+
+func TestContactsRepo_SearchKeys(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t, SQLite)
+	repo := NewContactsRepo(db, SQLite, cache.NewNoop(), DefaultCacheTTL)
+
+	for _, c := range []contact.Contact{
+		{ID: "1", FirstName: "José", LastName: "Zander", Addresses: []contact.Address{{Address: "Rue de l'Église 4, Genève", Street: "Rue de l'Église 4", City: "Genève"}}},
+		{ID: "2", FirstName: "Anna", LastName: "Öberg"},
+		{ID: "3", FirstName: "Lars", LastName: "olsson"},
+	} {
+		require.Nil(t, repo.InsertContact(ctx, c))
+	}
+
+	search := func(f contact.Filters) []string {
+		t.Helper()
+		f.Limit = 10
+		found, err := repo.SearchContacts(ctx, f)
+		require.Nil(t, err)
+
+		ids := []string{}
+		for _, c := range found {
+			ids = append(ids, c.ID)
+		}
+		return ids
+	}
+	parse := func(q string) *query.Node {
+		t.Helper()
+		n, err := query.Parse(q)
+		require.NoError(t, err)
+		return n
+	}
+
+	t.Run("matches names and addresses ignoring case and accents", func(t *testing.T) {
+		assert.Equal(t, []string{"1"}, search(contact.Filters{FullText: "jose"}))
+		assert.Equal(t, []string{"1"}, search(contact.Filters{FullText: "JOSÉ"}))
+		assert.Equal(t, []string{"2"}, search(contact.Filters{FullText: "oberg"}))
+		assert.Equal(t, []string{"1"}, search(contact.Filters{FullText: "eglise"}))
+		assert.Equal(t, []string{"1"}, search(contact.Filters{Query: parse("first:jose")}))
+		assert.Equal(t, []string{"2"}, search(contact.Filters{Query: parse("last:OBE*")}))
+		assert.Equal(t, []string{"1"}, search(contact.Filters{Query: parse(`address:"l'eglise"`)}))
+	})
+
+	t.Run("orders names by the locale's alphabet", func(t *testing.T) {
+		assert.Equal(t, []string{"2", "3", "1"}, search(contact.Filters{}))
+		assert.Equal(t, []string{"3", "1", "2"}, search(contact.Filters{Locale: "sv"}))
+	})
+
+	t.Run("computes the keys of legacy contacts", func(t *testing.T) {
+		_, err := db.Exec(`UPDATE contacts SET firstname_folded = NULL, lastname_folded = NULL WHERE id = '1'`)
+		require.NoError(t, err)
+		_, err = db.Exec(`DELETE FROM contact_sort_keys WHERE contact_id = '2'`)
+		require.NoError(t, err)
+
+		upgraded, upgradeErr := repo.UpgradeLegacySearchKeys(ctx)
+		require.Nil(t, upgradeErr)
+		assert.Equal(t, 2, upgraded)
+		assert.Equal(t, []string{"1"}, search(contact.Filters{FullText: "jose"}))
+		assert.Equal(t, []string{"3", "1", "2"}, search(contact.Filters{Locale: "sv"}))
+
+		upgraded, upgradeErr = repo.UpgradeLegacySearchKeys(ctx)
+		require.Nil(t, upgradeErr)
+		assert.Zero(t, upgraded)
+	})
+}
+
+func TestContactsRepo_LongNames(t *testing.T) {
+	for _, dialect := range []Dialect{SQLite, MySQL, Postgres} {
+		t.Run(dialect.Name(), func(t *testing.T) {
+			ctx := context.Background()
+			db := openTestDB(t, dialect)
+			repo := NewContactsRepo(db, dialect, cache.NewNoop(), DefaultCacheTTL)
+
+			// The keys of these names only differ past collation.SortKeyLength, the names break the tie.
+			long := strings.Repeat("Ab", 127)
+			require.Len(t, long+"c", 255)
+			for _, c := range []contact.Contact{
+				{ID: "1", FirstName: long + "c", LastName: long + "c"},
+				{ID: "2", FirstName: long + "b", LastName: long + "c"},
+				{ID: "3", FirstName: long + "a", LastName: long + "a"},
+				{ID: "4", FirstName: "Dan", LastName: "Cohen"},
+			} {
+				require.Nil(t, repo.InsertContact(ctx, c))
+			}
+
+			var longest int
+			require.NoError(t, db.QueryRow(`SELECT max(length(sort_key)) FROM contact_sort_keys`).Scan(&longest))
+			assert.Equal(t, collation.SortKeyLength, longest)
+
+			page := func(f contact.Filters) []string {
+				t.Helper()
+				f.Limit = 2
+				found, err := repo.SearchContacts(ctx, f)
+				require.Nil(t, err)
+
+				ids := []string{}
+				for _, c := range found {
+					ids = append(ids, c.ID)
+				}
+				return ids
+			}
+			assert.Equal(t, []string{"3", "2"}, page(contact.Filters{}))
+			assert.Equal(t, []string{"1", "4"}, page(contact.Filters{After: &contact.Cursor{LastName: long + "c", FirstName: long + "b", ID: "2"}}))
+			assert.Equal(t, []string{"3"}, page(contact.Filters{Before: &contact.Cursor{LastName: long + "c", FirstName: long + "b", ID: "2"}}))
+
+			// Keys stored before they were bounded are computed again.
+			query := `UPDATE contact_sort_keys SET sort_key = ? WHERE contact_id = ?`
+			require.NoError(t, repo.exec(ctx, db, query, strings.Repeat("ff", collation.SortKeyLength), "3"))
+			upgraded, upgradeErr := repo.UpgradeLegacySearchKeys(ctx)
+			require.Nil(t, upgradeErr)
+			assert.Equal(t, 1, upgraded)
+			require.NoError(t, db.QueryRow(`SELECT max(length(sort_key)) FROM contact_sort_keys`).Scan(&longest))
+			assert.Equal(t, collation.SortKeyLength, longest)
+		})
+	}
+}
+
+func TestContactsRepo_Keyset(t *testing.T) {
+	ctx := context.Background()
+	repo := NewContactsRepo(openTestDB(t, SQLite), SQLite, cache.NewNoop(), DefaultCacheTTL)
+
+	for _, c := range []contact.Contact{
+		{ID: "1", FirstName: "Dan", LastName: "Cohen"},
+		{ID: "2", FirstName: "Shai", LastName: "Levi"},
+		{ID: "3", FirstName: "Shayna", LastName: "Segal"},
+		{ID: "4", FirstName: "Anna", LastName: "Öberg"},
+	} {
+		require.Nil(t, repo.InsertContact(ctx, c))
+	}
+
+	page := func(f contact.Filters) []string {
+		t.Helper()
+		f.Limit = 2
+		found, err := repo.SearchContacts(ctx, f)
+		require.Nil(t, err)
+
+		ids := []string{}
+		for _, c := range found {
+			ids = append(ids, c.ID)
+		}
+		return ids
+	}
+	levi := &contact.Cursor{LastName: "Levi", FirstName: "Shai", ID: "2"}
+
+	assert.Equal(t, []string{"1", "2"}, page(contact.Filters{After: &contact.Cursor{}}))
+	assert.Equal(t, []string{"4", "3"}, page(contact.Filters{After: levi}))
+	assert.Equal(t, []string{"1"}, page(contact.Filters{Before: levi}))
+	assert.Equal(t, []string{"4", "3"}, page(contact.Filters{Before: &contact.Cursor{}}))
+	assert.Equal(t, []string{"3", "4"}, page(contact.Filters{After: levi, Locale: "sv"}))
+	assert.Equal(t, []string{"2", "3"}, page(contact.Filters{FullText: "sha", After: &contact.Cursor{LastName: "Cohen", FirstName: "Dan", ID: "1"}}))
+
+	t.Run("doesn't skip or repeat contacts inserted mid-scroll", func(t *testing.T) {
+		require.Nil(t, repo.InsertContact(ctx, contact.Contact{ID: "5", FirstName: "Avi", LastName: "Abramov"}))
+		assert.Equal(t, []string{"4", "3"}, page(contact.Filters{After: levi}))
+		assert.Equal(t, []string{"5", "1"}, page(contact.Filters{Before: levi}))
+	})
+
+	t.Run("scans the sort key index", func(t *testing.T) {
+		for _, f := range []contact.Filters{{After: levi}, {Before: levi}} {
+			join, args, _ := sortKeyOrder(f)
+			condition, conditionArgs, order := keyset(f)
+			query := `EXPLAIN QUERY PLAN SELECT ` + contactColumns + ` FROM contacts` + join + `
+				WHERE ` + condition + ` ORDER BY ` + order + ` LIMIT 2`
+			rows, err := repo.db.Query(query, append(args, conditionArgs...)...)
+			require.NoError(t, err)
+
+			var plan []string
+			for rows.Next() {
+				var id, parent, notUsed int
+				var detail string
+				require.NoError(t, rows.Scan(&id, &parent, &notUsed, &detail))
+				plan = append(plan, detail)
+			}
+			require.NoError(t, rows.Close())
+			assert.Contains(t, plan[0], "USING INDEX idx_contact_sort_keys_locale (locale=? AND sort_key")
+		}
+	})
+}
@@ -0,0 +1,18 @@
+package sql
+
+import "github.com/ShaynaSegal45/phonebook-api/config"
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string { return config.AdapterSQLite }
+
+func (sqliteDialect) rebind(query string) string { return query }
+
+// LIKE is case-insensitive for ASCII in SQLite.
+func (sqliteDialect) caseInsensitiveLike(column string) string {
+	return column + ` LIKE ? ESCAPE '!'`
+}
+
+func (sqliteDialect) fullTextCondition(query string) (string, []interface{}) {
+	return likeCondition(query)
+}
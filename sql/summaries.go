@@ -0,0 +1,55 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	"github.com/ShaynaSegal45/phonebook-api/contact"
+	"github.com/ShaynaSegal45/phonebook-api/errors"
+)
+
+// ListContactSummaries returns every contact with only its name and phones, in two queries,
+// for the suggestion index to be built from.
+func (r *ContactsRepo) ListContactSummaries(ctx context.Context) ([]contact.Contact, *errors.Error) {
+	errMsg := "ContactsRepo.ListContactSummaries"
+
+	var contacts []contact.Contact
+	byID := make(map[string]int)
+	err := r.queryDetails(ctx, r.db, `SELECT id, firstname, lastname, phone FROM contacts`, nil,
+		func(rows *sql.Rows) error {
+			var c contact.Contact
+			var firstName, lastName, phone sql.NullString
+			if err := rows.Scan(&c.ID, &firstName, &lastName, &phone); err != nil {
+				return err
+			}
+			c.FirstName, c.LastName, c.Phone = firstName.String, lastName.String, phone.String
+			byID[c.ID] = len(contacts)
+			contacts = append(contacts, c)
+			return nil
+		})
+	if err != nil {
+		log.Printf("%s: failed to load contacts: %v", errMsg, err)
+		return nil, errors.CreateError(operationName, errMsg, err, errors.InternalError)
+	}
+
+	err = r.queryDetails(ctx, r.db, `SELECT contact_id, label, number, COALESCE(e164, ''), is_primary
+		FROM contact_phones ORDER BY contact_id, position`, nil,
+		func(rows *sql.Rows) error {
+			var id string
+			var p contact.Phone
+			if err := rows.Scan(&id, &p.Label, &p.Number, &p.E164, &p.Primary); err != nil {
+				return err
+			}
+			if i, ok := byID[id]; ok {
+				contacts[i].Phones = append(contacts[i].Phones, p)
+			}
+			return nil
+		})
+	if err != nil {
+		log.Printf("%s: failed to load phones: %v", errMsg, err)
+		return nil, errors.CreateError(operationName, errMsg, err, errors.InternalError)
+	}
+
+	return contacts, nil
+}
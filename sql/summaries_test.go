@@ -0,0 +1,32 @@
+package sql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ShaynaSegal45/phonebook-api/cache"
+	"github.com/ShaynaSegal45/phonebook-api/contact"
+)
+
+func TestContactsRepo_ListContactSummaries(t *testing.T) {
+	ctx := context.Background()
+	repo := NewContactsRepo(openTestDB(t, SQLite), SQLite, cache.NewNoop(), DefaultCacheTTL)
+
+	phones := []contact.Phone{
+		{Label: contact.LabelMobile, Number: "050-1234567", E164: "+972501234567", Primary: true},
+		{Label: contact.LabelWork, Number: "03-1234567", E164: "+97231234567"},
+	}
+	require.Nil(t, repo.InsertContact(ctx, contact.Contact{ID: "1", FirstName: "Shayna", LastName: "Segal", Phone: "050-1234567", Phones: phones,
+		Emails: []contact.Email{{Email: "shayna@example.com", Primary: true}}}))
+	require.Nil(t, repo.InsertContact(ctx, contact.Contact{ID: "2", FirstName: "John"}))
+
+	summaries, err := repo.ListContactSummaries(ctx)
+	require.Nil(t, err)
+	assert.ElementsMatch(t, []contact.Contact{
+		{ID: "1", FirstName: "Shayna", LastName: "Segal", Phone: "050-1234567", Phones: phones},
+		{ID: "2", FirstName: "John"},
+	}, summaries)
+}
@@ -0,0 +1,246 @@
+// Package suggest answers typeahead queries from memory. Index keeps every contact's name words,
+// full name and phone digits in one sorted slice, so a prefix is a binary search away.
+package suggest
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/ShaynaSegal45/phonebook-api/collation"
+	"github.com/ShaynaSegal45/phonebook-api/contact"
+)
+
+// Suggestion is what a search box shows for a contact.
+type Suggestion struct {
+	ID           string `json:"id"`
+	DisplayName  string `json:"displayName"`
+	PrimaryPhone string `json:"primaryPhone"`
+}
+
+type entry struct {
+	key, id string
+}
+
+// write is a Put, or a Remove of id when contact is nil.
+type write struct {
+	id      string
+	contact *contact.Contact
+}
+
+// Index is safe for concurrent use.
+type Index struct {
+	mu          sync.RWMutex
+	entries     []entry
+	suggestions map[string]Suggestion
+	keys        map[string][]string
+	// refreshing counts the Refresh calls loading contacts, writes logs the Puts and Removes made
+	// meanwhile so each can replay those its load may have missed.
+	refreshing int
+	writes     []write
+}
+
+func NewIndex() *Index {
+	return &Index{suggestions: map[string]Suggestion{}, keys: map[string][]string{}}
+}
+
+// Refresh replaces the whole index with the contacts load returns. The Puts and Removes made while
+// load runs are applied again on top, whether or not the loaded contacts include them.
+func (x *Index) Refresh(load func() ([]contact.Contact, error)) error {
+	x.mu.Lock()
+	x.refreshing++
+	since := len(x.writes)
+	x.mu.Unlock()
+
+	contacts, err := load()
+	var entries []entry
+	var suggestions map[string]Suggestion
+	var keys map[string][]string
+	if err == nil {
+		entries, suggestions, keys = build(contacts)
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if err == nil {
+		x.entries, x.suggestions, x.keys = entries, suggestions, keys
+		for _, w := range x.writes[since:] {
+			if w.contact == nil {
+				x.remove(w.id)
+			} else {
+				x.put(*w.contact)
+			}
+		}
+	}
+	if x.refreshing--; x.refreshing == 0 {
+		x.writes = nil
+	}
+
+	return err
+}
+
+// Reset replaces the whole index with the given contacts.
+func (x *Index) Reset(contacts []contact.Contact) {
+	entries, suggestions, keys := build(contacts)
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.entries, x.suggestions, x.keys = entries, suggestions, keys
+}
+
+func build(contacts []contact.Contact) ([]entry, map[string]Suggestion, map[string][]string) {
+	entries := make([]entry, 0, len(contacts)*4)
+	suggestions := make(map[string]Suggestion, len(contacts))
+	keys := make(map[string][]string, len(contacts))
+	for _, c := range contacts {
+		suggestions[c.ID] = suggestionOf(c)
+		keys[c.ID] = keysOf(c)
+		for _, key := range keys[c.ID] {
+			entries = append(entries, entry{key, c.ID})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return less(entries[i], entries[j]) })
+
+	return entries, suggestions, keys
+}
+
+// Put adds a contact, or replaces what the index knew about it.
+func (x *Index) Put(c contact.Contact) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if x.refreshing > 0 {
+		x.writes = append(x.writes, write{id: c.ID, contact: &c})
+	}
+	x.put(c)
+}
+
+func (x *Index) put(c contact.Contact) {
+	x.remove(c.ID)
+	x.suggestions[c.ID] = suggestionOf(c)
+	x.keys[c.ID] = keysOf(c)
+	for _, key := range x.keys[c.ID] {
+		e := entry{key, c.ID}
+		i := sort.Search(len(x.entries), func(i int) bool { return !less(x.entries[i], e) })
+		x.entries = append(x.entries, entry{})
+		copy(x.entries[i+1:], x.entries[i:])
+		x.entries[i] = e
+	}
+}
+
+func (x *Index) Remove(id string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if x.refreshing > 0 {
+		x.writes = append(x.writes, write{id: id})
+	}
+	x.remove(id)
+}
+
+func (x *Index) remove(id string) {
+	for _, key := range x.keys[id] {
+		e := entry{key, id}
+		i := sort.Search(len(x.entries), func(i int) bool { return !less(x.entries[i], e) })
+		if i < len(x.entries) && x.entries[i] == e {
+			x.entries = append(x.entries[:i], x.entries[i+1:]...)
+		}
+	}
+	delete(x.keys, id)
+	delete(x.suggestions, id)
+}
+
+// Search returns up to limit contacts with a name word, full name or phone starting with the query,
+// in key order. A query that looks like a phone number is matched on its digits only.
+func (x *Index) Search(query string, limit int) []Suggestion {
+	prefix := normalize(query)
+	if digits := phoneDigits(query); digits != "" {
+		prefix = digits
+	}
+	suggestions := []Suggestion{}
+	if prefix == "" || limit <= 0 {
+		return suggestions
+	}
+
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	seen := make(map[string]bool, limit)
+	i := sort.Search(len(x.entries), func(i int) bool { return x.entries[i].key >= prefix })
+	for ; i < len(x.entries) && len(suggestions) < limit && strings.HasPrefix(x.entries[i].key, prefix); i++ {
+		if id := x.entries[i].id; !seen[id] {
+			seen[id] = true
+			suggestions = append(suggestions, x.suggestions[id])
+		}
+	}
+
+	return suggestions
+}
+
+func suggestionOf(c contact.Contact) Suggestion {
+	s := Suggestion{ID: c.ID, DisplayName: strings.TrimSpace(c.FirstName + " " + c.LastName), PrimaryPhone: c.Phone}
+	for _, p := range c.Phones {
+		if p.Primary {
+			s.PrimaryPhone = p.Number
+		}
+	}
+	return s
+}
+
+// keysOf lists every word of the name, the name in both orders, and the digits of every phone
+// as written and in E.164, so "050" and "97250" both find an Israeli mobile.
+func keysOf(c contact.Contact) []string {
+	first, last := normalize(c.FirstName), normalize(c.LastName)
+	keys := strings.Fields(first + " " + last)
+	if first != "" && last != "" {
+		keys = append(keys, first+" "+last, last+" "+first)
+	}
+	for _, p := range c.Phones {
+		keys = append(keys, digitsOf(p.Number), digitsOf(p.E164))
+	}
+	if len(c.Phones) == 0 {
+		keys = append(keys, digitsOf(c.Phone))
+	}
+
+	unique := keys[:0]
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if key != "" && !seen[key] {
+			seen[key] = true
+			unique = append(unique, key)
+		}
+	}
+	return unique
+}
+
+// normalize folds a name's case and accents and collapses its punctuation and spaces into single spaces.
+func normalize(name string) string {
+	return strings.Join(strings.FieldsFunc(collation.Fold(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// phoneDigits returns the digits of a query made only of digits and phone punctuation.
+func phoneDigits(query string) string {
+	if strings.TrimFunc(query, func(r rune) bool { return unicode.IsDigit(r) || strings.ContainsRune("+-() .", r) }) != "" {
+		return ""
+	}
+	return digitsOf(query)
+}
+
+func digitsOf(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+func less(a, b entry) bool {
+	if a.key != b.key {
+		return a.key < b.key
+	}
+	return a.id < b.id
+}
@@ -0,0 +1,104 @@
+package suggest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ShaynaSegal45/phonebook-api/contact"
+)
+
+func TestIndex(t *testing.T) {
+	index := NewIndex()
+	index.Reset([]contact.Contact{
+		{ID: "1", FirstName: "Shayna", LastName: "Segal",
+			Phones: []contact.Phone{{Number: "03-1234567", E164: "+97231234567"}, {Number: "050-1234567", E164: "+972501234567", Primary: true}}},
+		{ID: "2", FirstName: "Shai", LastName: "Levi"},
+		{ID: "3", FirstName: "Dan", LastName: "Shapiro", Phone: "054-7654321"},
+		{ID: "5", FirstName: "José", LastName: "Ñúñez"},
+	})
+
+	ids := func(query string, limit int) []string {
+		ids := []string{}
+		for _, s := range index.Search(query, limit) {
+			ids = append(ids, s.ID)
+		}
+		return ids
+	}
+
+	t.Run("matches any name word, the full name and phones", func(t *testing.T) {
+		assert.Equal(t, []string{"2", "3", "1"}, ids("SHA", 8))
+		assert.Equal(t, []string{"1"}, ids("shayna seg", 8))
+		assert.Equal(t, []string{"1"}, ids("segal sh", 8))
+		assert.Equal(t, []string{"1"}, ids("050-12", 8))
+		assert.Equal(t, []string{"1"}, ids("+97250", 8))
+		assert.Equal(t, []string{"3"}, ids("0547", 8))
+		assert.Equal(t, []string{"2", "3"}, ids("sha", 2))
+		assert.Equal(t, []string{"5"}, ids("jose nu", 8))
+		assert.Equal(t, []string{"5"}, ids("ÑUÑ", 8))
+		assert.Empty(t, ids("x", 8))
+		assert.Empty(t, ids("", 8))
+	})
+
+	t.Run("returns the display name and primary phone", func(t *testing.T) {
+		assert.Equal(t, []Suggestion{{ID: "1", DisplayName: "Shayna Segal", PrimaryPhone: "050-1234567"}}, index.Search("segal", 8))
+	})
+
+	t.Run("follows writes", func(t *testing.T) {
+		index.Put(contact.Contact{ID: "2", FirstName: "Shai", LastName: "Cohen"})
+		assert.Equal(t, []string{"2"}, ids("cohen", 8))
+		assert.Empty(t, ids("levi", 8))
+
+		index.Put(contact.Contact{ID: "4", FirstName: "Shalom", LastName: "Aleichem"})
+		assert.Equal(t, []string{"2", "4", "3", "1"}, ids("sha", 8))
+
+		index.Remove("1")
+		index.Remove("unknown")
+		assert.Equal(t, []string{"2", "4", "3"}, ids("sha", 8))
+		assert.Empty(t, ids("050", 8))
+	})
+}
+
+func TestIndex_RefreshKeepsWritesMadeWhileLoading(t *testing.T) {
+	index := NewIndex()
+	index.Reset([]contact.Contact{{ID: "1", FirstName: "Shai", LastName: "Levi"}, {ID: "2", FirstName: "Dan", LastName: "Cohen"}})
+
+	err := index.Refresh(func() ([]contact.Contact, error) {
+		// The snapshot was read before these writes committed.
+		index.Put(contact.Contact{ID: "1", FirstName: "Shai", LastName: "Peretz"})
+		index.Put(contact.Contact{ID: "3", FirstName: "Noa", LastName: "Kirel"})
+		index.Remove("2")
+		return []contact.Contact{{ID: "1", FirstName: "Shai", LastName: "Levi"}, {ID: "2", FirstName: "Dan", LastName: "Cohen"}}, nil
+	})
+	assert.NoError(t, err)
+
+	assert.Len(t, index.Search("peretz", 8), 1)
+	assert.Empty(t, index.Search("levi", 8))
+	assert.Len(t, index.Search("noa", 8), 1)
+	assert.Empty(t, index.Search("dan", 8))
+	assert.Empty(t, index.writes, "the log is dropped once no refresh runs")
+
+	err = index.Refresh(func() ([]contact.Contact, error) { return nil, fmt.Errorf("db down") })
+	assert.Error(t, err)
+	assert.Len(t, index.Search("noa", 8), 1, "a failed refresh keeps the index")
+}
+
+func BenchmarkIndex_Search(b *testing.B) {
+	index := NewIndex()
+	contacts := make([]contact.Contact, 100000)
+	for i := range contacts {
+		contacts[i] = contact.Contact{
+			ID:        fmt.Sprint(i),
+			FirstName: fmt.Sprintf("first%d", i),
+			LastName:  fmt.Sprintf("last%d", i),
+			Phone:     fmt.Sprintf("050%07d", i),
+		}
+	}
+	index.Reset(contacts)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		index.Search("last12", 8)
+	}
+}